@@ -26,6 +26,45 @@ type SecP256k1Output struct {
 	Creator     *btcec.PublicKey
 	Data        string
 	PrevCounter []byte
+	HashLock    *HashLock
+	TimeLock    *TimeLock
+}
+
+// HashLock restricts an output to two spends: Recipient can claim it before
+// Expiry by revealing the preimage of Hash, and the owners can refund it once
+// Expiry has passed. Expiry is in unix seconds.
+type HashLock struct {
+	Hash      []byte
+	Expiry    int64
+	Recipient *btcec.PublicKey
+}
+
+// TimeLock keeps Total units of an output from being spent until they vest.
+// Nothing vests before Start, everything has vested at End and in between the
+// units vest linearly, in steps of Period seconds when Period is set. A lock
+// with Start equal to End releases everything at once. Times are in unix
+// seconds.
+type TimeLock struct {
+	Start  int64
+	End    int64
+	Period int64
+	Total  int
+}
+
+// Unvested returns how many units are still locked at now.
+func (l *TimeLock) Unvested(now int64) int {
+	if now >= l.End {
+		return 0
+	}
+	if now < l.Start || l.End <= l.Start {
+		return l.Total
+	}
+	elapsed := now - l.Start
+	if l.Period > 0 {
+		elapsed -= elapsed % l.Period
+	}
+	vested := int64(l.Total) * elapsed / (l.End - l.Start)
+	return l.Total - int(vested)
 }
 
 func New(creator *btcec.PublicKey, amount int, assetType string, TxData string, counter []byte) *SecP256k1Output {
@@ -56,6 +95,19 @@ func (b *SecP256k1Output) ToProtoBuf() *TuxedoPopsStore.OTX {
 	buf.Data = b.Data
 	buf.PrevCounter = b.PrevCounter
 	buf.Threshold = int64(b.Threshold)
+	if b.HashLock != nil {
+		buf.HashLock = &TuxedoPopsStore.HashLock{}
+		buf.HashLock.Hash = b.HashLock.Hash
+		buf.HashLock.Expiry = b.HashLock.Expiry
+		buf.HashLock.Recipient = b.HashLock.Recipient.SerializeCompressed()
+	}
+	if b.TimeLock != nil {
+		buf.TimeLock = &TuxedoPopsStore.TimeLock{}
+		buf.TimeLock.Start = b.TimeLock.Start
+		buf.TimeLock.End = b.TimeLock.End
+		buf.TimeLock.Period = b.TimeLock.Period
+		buf.TimeLock.Total = int64(b.TimeLock.Total)
+	}
 	for _, owner := range b.Owners {
 		if owner.Curve != nil && owner.X != nil && owner.Y != nil {
 
@@ -76,6 +128,16 @@ func (b *SecP256k1Output) FromProtoBuf(buf TuxedoPopsStore.OTX) error {
 	b.Data = buf.Data
 	b.Threshold = int(buf.Threshold)
 	b.PrevCounter = buf.PrevCounter
+	if buf.HashLock != nil {
+		recipientKey, err := btcec.ParsePubKey(buf.HashLock.Recipient, btcec.S256())
+		if err != nil {
+			return err
+		}
+		b.HashLock = &HashLock{Hash: buf.HashLock.Hash, Expiry: buf.HashLock.Expiry, Recipient: recipientKey}
+	}
+	if buf.TimeLock != nil {
+		b.TimeLock = &TimeLock{Start: buf.TimeLock.Start, End: buf.TimeLock.End, Period: buf.TimeLock.Period, Total: int(buf.TimeLock.Total)}
+	}
 	for _, ownerBuf := range buf.Owners {
 		ownerKey, err := btcec.ParsePubKey(ownerBuf, btcec.S256())
 		if err != nil {
@@ -87,6 +149,13 @@ func (b *SecP256k1Output) FromProtoBuf(buf TuxedoPopsStore.OTX) error {
 	return nil
 }
 
+// JSONHashLock is the JSON form of a HashLock in balance queries.
+type JSONHashLock struct {
+	Hash      string
+	Expiry    int64
+	Recipient string
+}
+
 func (b *SecP256k1Output) ToJSON() []byte {
 	type JSONOTX struct {
 		Owners      []string
@@ -96,6 +165,8 @@ func (b *SecP256k1Output) ToJSON() []byte {
 		PrevCounter string
 		Creator     string
 		Amount      int64
+		HashLock    *JSONHashLock `json:",omitempty"`
+		TimeLock    *TimeLock     `json:",omitempty"`
 	}
 	jsonOTX := JSONOTX{}
 
@@ -108,6 +179,13 @@ func (b *SecP256k1Output) ToJSON() []byte {
 	jsonOTX.Amount = int64(b.Amount)
 	jsonOTX.Creator = hex.EncodeToString(b.Creator.SerializeCompressed())
 	jsonOTX.PrevCounter = hex.EncodeToString(b.PrevCounter)
+	if b.HashLock != nil {
+		jsonOTX.HashLock = &JSONHashLock{}
+		jsonOTX.HashLock.Hash = hex.EncodeToString(b.HashLock.Hash)
+		jsonOTX.HashLock.Expiry = b.HashLock.Expiry
+		jsonOTX.HashLock.Recipient = hex.EncodeToString(b.HashLock.Recipient.SerializeCompressed())
+	}
+	jsonOTX.TimeLock = b.TimeLock
 
 	jsonstring, err := json.Marshal(jsonOTX)
 	if err != nil {
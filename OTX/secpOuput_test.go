@@ -9,6 +9,12 @@ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLI
 */
 package OTX_test
 
+import (
+	"testing"
+
+	"github.com/skuchain/TuxedoPops/OTX"
+)
+
 // func TestInitialState(t *testing.T) {
 // 	// newProof := new(OTX.SecP256k1Output)
 // }
@@ -35,3 +41,31 @@ package OTX_test
 // 	for _ = range count {
 // 	}
 // }
+
+func TestTimeLockUnvested(t *testing.T) {
+	cases := []struct {
+		lock     OTX.TimeLock
+		now      int64
+		unvested int
+	}{
+		// a plain time lock releases everything at End
+		{OTX.TimeLock{Start: 100, End: 100, Total: 10}, 99, 10},
+		{OTX.TimeLock{Start: 100, End: 100, Total: 10}, 100, 0},
+		// linear vesting rounds the vested amount down
+		{OTX.TimeLock{Start: 100, End: 200, Total: 10}, 50, 10},
+		{OTX.TimeLock{Start: 100, End: 200, Total: 10}, 100, 10},
+		{OTX.TimeLock{Start: 100, End: 200, Total: 10}, 155, 5},
+		{OTX.TimeLock{Start: 100, End: 200, Total: 10}, 199, 1},
+		{OTX.TimeLock{Start: 100, End: 200, Total: 10}, 200, 0},
+		// quarterly steps
+		{OTX.TimeLock{Start: 0, End: 400, Period: 100, Total: 8}, 99, 8},
+		{OTX.TimeLock{Start: 0, End: 400, Period: 100, Total: 8}, 100, 6},
+		{OTX.TimeLock{Start: 0, End: 400, Period: 100, Total: 8}, 399, 2},
+		{OTX.TimeLock{Start: 0, End: 400, Period: 100, Total: 8}, 400, 0},
+	}
+	for _, c := range cases {
+		if unvested := c.lock.Unvested(c.now); unvested != c.unvested {
+			t.Errorf("%+v at %d: got %d unvested, want %d", c.lock, c.now, unvested, c.unvested)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package Pop
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"strconv"
@@ -21,9 +22,18 @@ type Pop struct {
 	Outputs []OTX.SecP256k1Output
 }
 
-func (p *Pop) verifyPopSigs(idx int, m string, ownerSigs [][]byte, PopSig []byte) error {
+func (p *Pop) verifyPopSigs(idx int, m []byte, ownerSigs [][]byte, PopSig []byte) error {
+	if idx >= 0 && idx < len(p.Outputs) && p.Outputs[idx].HashLock != nil {
+		return fmt.Errorf("Output %d is hash locked and can only be claimed or refunded", idx)
+	}
+	return p.verifySigs(idx, m, ownerSigs, PopSig)
+}
+
+// verifySigs checks the owner and popcode signatures of output idx without
+// regard to any lock on it.
+func (p *Pop) verifySigs(idx int, m []byte, ownerSigs [][]byte, PopSig []byte) error {
 
-	mDigest := sha256.Sum256([]byte(m))
+	mDigest := sha256.Sum256(m)
 
 	if idx < 0 || idx >= len(p.Outputs) {
 		return fmt.Errorf("Invalid Source index %d\n %s\n", idx, p.ToJSON())
@@ -50,7 +60,7 @@ func (p *Pop) verifyPopSigs(idx int, m string, ownerSigs [][]byte, PopSig []byte
 					break
 				}
 				if i == len(otx.Owners)-1 {
-					return fmt.Errorf("Invalid Signature %s on %s", hex.EncodeToString(signature.Serialize()), m)
+					return fmt.Errorf("Invalid Signature %s on %q", hex.EncodeToString(signature.Serialize()), m)
 				}
 			}
 		}
@@ -65,12 +75,23 @@ func (p *Pop) verifyPopSigs(idx int, m string, ownerSigs [][]byte, PopSig []byte
 	}
 	success := signature.Verify(mDigest[:], &p.PubKey)
 	if !success {
-		return fmt.Errorf("Invalid Pop Signature %+v Pubkey %s Message %s", signature, hex.EncodeToString(p.PubKey.SerializeCompressed()), m)
+		return fmt.Errorf("Invalid Pop Signature %+v Pubkey %s Message %q", signature, hex.EncodeToString(p.PubKey.SerializeCompressed()), m)
 	}
 	return nil
 }
 
-func (p *Pop) CreateOutput(amount int, assetType string, data string, creatorKeyBytes []byte, creatorSig []byte) error {
+// CreateOutput mints amount units of assetType. issuers are the keys
+// registered for assetType, if it is registered, and the creator must be one
+// of them.
+func (p *Pop) CreateOutput(amount int, assetType string, data string, creatorKeyBytes []byte, creatorSig []byte, issuers [][]byte, version int) error {
+
+	err := CheckVersion(version)
+	if err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Create amount must be positive")
+	}
 
 	//deserialize public key bytes into a public key object
 	creatorKey, err := btcec.ParsePubKey(creatorKeyBytes, btcec.S256())
@@ -89,15 +110,18 @@ func (p *Pop) CreateOutput(amount int, assetType string, data string, creatorKey
 	}
 
 	//FIXME add Value to the signature
-	message := hex.EncodeToString(p.Counter) + ":" + p.Address + ":" + strconv.FormatInt(int64(amount), 10) + ":" + assetType + ":" + data
+	message := CreateMessage(version, p.Counter, p.Address, amount, assetType, data)
 
-	messageBytes := sha256.Sum256([]byte(message))
+	messageBytes := sha256.Sum256(message)
 
 	//try to verify the signature (most likely failure is that the wrong thing has been signed (maybe the counterseed changed or the message you signed and the message you verified are not the same))
 	success := signature.Verify(messageBytes[:], creatorKey)
 	if !success {
-		fmt.Printf("Invalid Creator Signature %s \n Pubkey:%v \n ", message, creatorKey)
-		return fmt.Errorf("Invalid Creator Signature %s\n Pubkey:%v ", message, creatorKey)
+		fmt.Printf("Invalid Creator Signature %q \n Pubkey:%v \n ", message, creatorKey)
+		return fmt.Errorf("Invalid Creator Signature %q\n Pubkey:%v ", message, creatorKey)
+	}
+	if len(issuers) > 0 && !IsIssuer(issuers, creatorKey) {
+		return fmt.Errorf("Creator is not an issuer of type %s", assetType)
 	}
 	newCounter := sha256.Sum256(p.Counter)
 	p.Counter = newCounter[:]
@@ -140,7 +164,12 @@ func (p *Pop) CreateOutputFromSources(amount int, assetType string, data string,
 	return nil
 }
 
-func (p *Pop) UnitizeOutput(idx int, amounts []int, data string, dest *Pop, ownerSigs [][]byte, PopPubkey []byte, PopSig []byte) error {
+func (p *Pop) UnitizeOutput(idx int, amounts []int, data string, dest *Pop, ownerSigs [][]byte, PopPubkey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckVersion(version)
+	if err != nil {
+		return err
+	}
 
 	pubkey, err := btcec.ParsePubKey(PopPubkey, btcec.S256())
 
@@ -171,13 +200,13 @@ func (p *Pop) UnitizeOutput(idx int, amounts []int, data string, dest *Pop, owne
 	if otx.Amount < totalAmount {
 		return fmt.Errorf("Insufficient amount")
 	}
-
-	m := hex.EncodeToString(p.Counter) + ":" + dest.Address + ":" + data
-	m += ":" + strconv.FormatInt(int64(idx), 10)
-	for _, amount := range amounts {
-		m += ":" + strconv.FormatInt(int64(amount), 10)
+	err = p.checkTimeLock(idx, totalAmount, now)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("\n\nFROM POP.GO UnitizeOutput\nUnitize Message: %s\n\n", m)
+
+	m := UnitizeMessage(version, p.Counter, dest.Address, data, idx, amounts)
+	fmt.Printf("\n\nFROM POP.GO UnitizeOutput\nUnitize Message: %q\n\n", m)
 
 	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
 	if err != nil {
@@ -185,25 +214,131 @@ func (p *Pop) UnitizeOutput(idx int, amounts []int, data string, dest *Pop, owne
 	}
 
 	for _, amount := range amounts {
+		p.moveOutput(idx, amount, dest, data)
+	}
+	// the signatures are over the counter, so it moves on for them to be
+	// spent only once
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
+	return nil
+}
 
-		//I'm pretty sure this is a copy not a reference
-		destOut := p.Outputs[idx]
-		destOut.PrevCounter = make([]byte, len(dest.Counter))
-		copy(destOut.PrevCounter, dest.Counter)
-		newCounter := sha256.Sum256(dest.Counter)
-		dest.Counter = newCounter[:]
-		destOut.Data = data
-		destOut.Amount = amount
-		p.Outputs[idx].Amount -= amount
-		if p.Outputs[idx].Amount == 0 {
-			if idx != (len(p.Outputs) - 1) {
-				p.Outputs = append(p.Outputs[:idx], p.Outputs[idx+1:]...)
-			} else {
-				p.Outputs = p.Outputs[:idx]
+// moveOutput moves amount units of output idx to a new output on dest at
+// dest's next counter, removing the source output once it is empty.
+func (p *Pop) moveOutput(idx int, amount int, dest *Pop, data string) {
+	//I'm pretty sure this is a copy not a reference
+	destOut := p.Outputs[idx]
+	destOut.TimeLock = nil
+	destOut.PrevCounter = make([]byte, len(dest.Counter))
+	copy(destOut.PrevCounter, dest.Counter)
+	newCounter := sha256.Sum256(dest.Counter)
+	dest.Counter = newCounter[:]
+	destOut.Data = data
+	destOut.Amount = amount
+	p.Outputs[idx].Amount -= amount
+	if p.Outputs[idx].Amount == 0 {
+		if idx != (len(p.Outputs) - 1) {
+			p.Outputs = append(p.Outputs[:idx], p.Outputs[idx+1:]...)
+		} else {
+			p.Outputs = p.Outputs[:idx]
+		}
+	}
+	dest.Outputs = append(dest.Outputs, destOut)
+}
+
+// MultiUnitizeOutput splits one output across several distinct destination
+// popcodes. Unless keepChange is set the amounts must use up the whole output,
+// otherwise the remainder stays on p as a change output.
+func (p *Pop) MultiUnitizeOutput(idx int, dests []*Pop, amounts [][]int, keepChange bool, data string, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	pubkey, err := btcec.ParsePubKey(PopPubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Pop key")
+	}
+	p.PubKey = *pubkey
+
+	keyDigest := sha256.Sum256(PopPubKey)
+	PopAddress := hex.EncodeToString(keyDigest[:20])
+	if PopAddress != p.Address {
+		return fmt.Errorf("Invalid Pop Public Key")
+	}
+
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	if len(dests) == 0 || len(dests) != len(amounts) {
+		return fmt.Errorf("Each destination needs a list of amounts")
+	}
+
+	destAddresses := make([]string, len(dests))
+	seen := make(map[string]bool)
+	totalAmount := 0
+	for i, dest := range dests {
+		if dest.Address == p.Address {
+			return fmt.Errorf("The source address %s must be different from dest address %s", p.Address, dest.Address)
+		}
+		if seen[dest.Address] {
+			return fmt.Errorf("Duplicate destination address %s", dest.Address)
+		}
+		seen[dest.Address] = true
+		destAddresses[i] = dest.Address
+
+		if len(amounts[i]) == 0 {
+			return fmt.Errorf("No amounts for destination %s", dest.Address)
+		}
+		for _, value := range amounts[i] {
+			if value <= 0 {
+				return fmt.Errorf("Non positive outputs are prohibited")
 			}
+			totalAmount += value
 		}
-		dest.Outputs = append(dest.Outputs, destOut)
 	}
+
+	otx := p.Outputs[idx]
+	if otx.Amount < totalAmount {
+		return fmt.Errorf("Insufficient amount")
+	}
+	if otx.Amount > totalAmount && !keepChange {
+		return fmt.Errorf("Amounts (%d) do not use up output of %d and no change was requested", totalAmount, otx.Amount)
+	}
+	err = p.checkTimeLock(idx, totalAmount, now)
+	if err != nil {
+		return err
+	}
+
+	m := MultiUnitizeMessage(version, p.Counter, idx, destAddresses, amounts, keepChange, data)
+	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
+	if err != nil {
+		return err
+	}
+
+	for i, dest := range dests {
+		for _, amount := range amounts[i] {
+			destOut := otx
+			destOut.TimeLock = nil
+			destOut.PrevCounter = make([]byte, len(dest.Counter))
+			copy(destOut.PrevCounter, dest.Counter)
+			newCounter := sha256.Sum256(dest.Counter)
+			dest.Counter = newCounter[:]
+			destOut.Data = data
+			destOut.Amount = amount
+			dest.Outputs = append(dest.Outputs, destOut)
+		}
+	}
+
+	if otx.Amount == totalAmount {
+		p.Outputs = append(p.Outputs[:idx], p.Outputs[idx+1:]...)
+	} else {
+		p.Outputs[idx].Amount -= totalAmount
+		p.Outputs[idx].PrevCounter = make([]byte, len(p.Counter))
+		copy(p.Outputs[idx].PrevCounter, p.Counter)
+	}
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
 	return nil
 }
 
@@ -213,7 +348,19 @@ type SourceOutput interface {
 }
 
 func (p *Pop) CombineOutputs(sources []SourceOutput, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte,
-	createdAmount int, recipeName string, recipe TuxedoPopsStore.Recipe, data string, creatorPublicKeyBytes []byte, creatorSigBytes []byte) error {
+	createdAmount int, recipeName string, recipeVersion int, recipe TuxedoPopsStore.Recipe, data string,
+	creatorPublicKeyBytes []byte, creatorSigBytes []byte, approvalSigs [][]byte, returnChange bool, now int64, version int) error {
+
+	err := CheckVersion(version)
+	if err != nil {
+		return err
+	}
+	if recipeVersion != 0 || returnChange {
+		err = CheckCanonical(version)
+		if err != nil {
+			return err
+		}
+	}
 
 	// create public key object from PopPubKey
 	pubkey, err := btcec.ParsePubKey(PopPubKey, btcec.S256())
@@ -239,30 +386,163 @@ func (p *Pop) CombineOutputs(sources []SourceOutput, ownerSigs [][]byte, PopPubK
 	}
 
 	//creatorSigBytes should be the signature of the following message
-	m := hex.EncodeToString(p.Counter)
-	m += ":" + recipeName
-	for _, source := range sources {
-		m += ":" + strconv.FormatInt(int64(source.Idx()), 10)
-		m += ":" + strconv.FormatInt(int64(source.Amount()), 10)
+	m := CombineMessage(version, p.Counter, recipeName, recipeVersion, sources, createdAmount, data, returnChange)
+
+	sourceAmounts, err := p.spendSources(sources, m, ownerSigs, PopSig, now)
+	if err != nil {
+		return err
+	}
+	change, err := checkCreation(m, recipeName, recipe, sourceAmounts, createdAmount, creatorPublicKey, creatorSigBytes, approvalSigs, returnChange)
+	if err != nil {
+		return err
+	}
+	p.returnChange(sources, change)
+	p.dropSpent()
+	p.mintProducts(recipe, createdAmount, creatorPublicKey, data)
+	return nil
+}
+
+// CombineSigner is a popcode whose outputs are consumed by CombineAcross,
+// with the signatures of its owners and popcode.
+type CombineSigner struct {
+	Pop       *Pop
+	Sources   []SourceOutput
+	OwnerSigs [][]byte
+	PopPubKey []byte
+	PopSig    []byte
+}
+
+// CombineAcross is CombineOutputs with sources held on several popcodes. The
+// products are created on p, which may be one of the signers. Every signer
+// signs the same message covering all of the sources and counters, and the
+// counter of every signer other than p moves once.
+func (p *Pop) CombineAcross(signers []CombineSigner, createdAmount int, recipeName string, recipeVersion int,
+	recipe TuxedoPopsStore.Recipe, data string, creatorPublicKeyBytes []byte, creatorSigBytes []byte, approvalSigs [][]byte,
+	returnChange bool, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	if len(signers) == 0 {
+		return fmt.Errorf("Combine needs at least one source")
+	}
+	creatorPublicKey, err := btcec.ParsePubKey(creatorPublicKeyBytes, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Creator key")
 	}
-	m += ":" + strconv.FormatInt(int64(createdAmount), 10)
-	m += ":" + data
 
-	mDigest := sha256.Sum256([]byte(m))
+	addresses := make([]string, len(signers))
+	counters := make([][]byte, len(signers))
+	sources := make([][]SourceOutput, len(signers))
+	seen := make(map[string]bool)
+	for i, signer := range signers {
+		if seen[signer.Pop.Address] {
+			return fmt.Errorf("Popcode %s is listed twice", signer.Pop.Address)
+		}
+		seen[signer.Pop.Address] = true
+		err = signer.Pop.checkPubKey(signer.PopPubKey)
+		if err != nil {
+			return err
+		}
+		addresses[i] = signer.Pop.Address
+		counters[i] = signer.Pop.Counter
+		sources[i] = signer.Sources
+	}
+	m := MultiCombineMessage(version, addresses, counters, sources, p.Address, recipeName, recipeVersion, createdAmount, data, returnChange)
 
+	sourceAmounts := make(map[string]int)
+	for _, signer := range signers {
+		spent, err := signer.Pop.spendSources(signer.Sources, m, signer.OwnerSigs, signer.PopSig, now)
+		if err != nil {
+			return fmt.Errorf("Popcode %s: %s", signer.Pop.Address, err.Error())
+		}
+		for assetType, amount := range spent {
+			sourceAmounts[assetType] += amount
+		}
+		// p's counter moves as the products are created
+		if signer.Pop.Address != p.Address {
+			newCounter := sha256.Sum256(signer.Pop.Counter)
+			signer.Pop.Counter = newCounter[:]
+		}
+	}
+	change, err := checkCreation(m, recipeName, recipe, sourceAmounts, createdAmount, creatorPublicKey, creatorSigBytes, approvalSigs, returnChange)
+	if err != nil {
+		return err
+	}
+	// change goes back to the last sources first
+	for i := len(signers) - 1; i >= 0; i-- {
+		signers[i].Pop.returnChange(signers[i].Sources, change)
+		signers[i].Pop.dropSpent()
+	}
+	p.mintProducts(recipe, createdAmount, creatorPublicKey, data)
+	return nil
+}
+
+// spendSources takes sources out of p's outputs after checking their time
+// locks and the signatures of m, and returns the amount taken of each type.
+// Outputs left empty stay in place until dropSpent so that change can still
+// be returned to them. Without sources nothing would be signed, so they are
+// required.
+func (p *Pop) spendSources(sources []SourceOutput, m []byte, ownerSigs [][]byte, PopSig []byte, now int64) (map[string]int, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("Combine needs at least one source")
+	}
 	sourceAmounts := make(map[string]int)
 
+	spent := make(map[int]int)
 	for _, source := range sources {
+		if source.Amount() <= 0 {
+			return nil, fmt.Errorf("Source amount %d of index %d must be positive", source.Amount(), source.Idx())
+		}
+		spent[source.Idx()] += source.Amount()
+	}
+	for idx, amount := range spent {
+		err := p.checkTimeLock(idx, amount, now)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		err = p.verifyPopSigs(source.Idx(), m, ownerSigs, PopSig)
+	for _, source := range sources {
+
+		err := p.verifyPopSigs(source.Idx(), m, ownerSigs, PopSig)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		p.Outputs[source.Idx()].Amount -= source.Amount()
 		sourceAmounts[p.Outputs[source.Idx()].Type] += source.Amount()
 	}
 
+	for idx := range p.Outputs {
+		if p.Outputs[idx].Amount < 0 {
+			return nil, fmt.Errorf("Insufficient balance in index %d", idx)
+		}
+	}
+	return sourceAmounts, nil
+}
+
+// returnChange gives the units in change back to the outputs sources were
+// taken from, starting with the last source of each type, and takes them out
+// of change.
+func (p *Pop) returnChange(sources []SourceOutput, change map[string]int) {
+	for i := len(sources) - 1; i >= 0; i-- {
+		output := &p.Outputs[sources[i].Idx()]
+		amount := change[output.Type]
+		if amount == 0 {
+			continue
+		}
+		if amount > sources[i].Amount() {
+			amount = sources[i].Amount()
+		}
+		output.Amount += amount
+		change[output.Type] -= amount
+	}
+}
+
+// dropSpent removes the outputs that have been spent entirely.
+func (p *Pop) dropSpent() {
 	/*
 		copy nonzero values and assign p.outputs to the new array.
 		make array of ouputs. If outputs.amount is greater than
@@ -271,44 +551,62 @@ func (p *Pop) CombineOutputs(sources []SourceOutput, ownerSigs [][]byte, PopPubK
 	filteredArray := make([]OTX.SecP256k1Output, 0)
 
 	for idx := range p.Outputs {
-
-		if p.Outputs[idx].Amount < 0 {
-			return fmt.Errorf("Insufficient balance in index %d", idx)
-		}
 		if p.Outputs[idx].Amount != 0 {
 			filteredArray = append(filteredArray, p.Outputs[idx])
 		}
 	}
 
 	p.Outputs = filteredArray
+}
+
+// checkCreation checks the creator's signature of m, the manufacturers of
+// recipe and that sourceAmounts match the recipe's ratios for createdAmount,
+// returning the change left over with returnChange.
+func checkCreation(m []byte, recipeName string, recipe TuxedoPopsStore.Recipe, sourceAmounts map[string]int, createdAmount int,
+	creatorPublicKey *btcec.PublicKey, creatorSigBytes []byte, approvalSigs [][]byte, returnChange bool) (map[string]int, error) {
 
+	if createdAmount <= 0 {
+		return nil, fmt.Errorf("Created amount must be positive")
+	}
+	mDigest := sha256.Sum256(m)
 	signature, err := btcec.ParseDERSignature(creatorSigBytes, btcec.S256())
 	if err != nil {
 		fmt.Println("Bad signature encoding")
 
-		return fmt.Errorf("Bad signature encoding")
+		return nil, fmt.Errorf("Bad signature encoding")
 	}
 	success := signature.Verify(mDigest[:], creatorPublicKey)
 	if !success {
 		fmt.Println("Invalid creator signature")
-		return fmt.Errorf("Invalid creator signature")
+		return nil, fmt.Errorf("Invalid creator signature")
 	}
-	for _, ingredient := range recipe.Ingredients {
-		sourceAmt := sourceAmounts[ingredient.Type]
-		if (int64(sourceAmt) / ingredient.Numerator * ingredient.Denominator) != int64(createdAmount) {
-			return fmt.Errorf("Ratio invalid for %s\n\nsource amount (%d) divided by numerator for ingredient %s (%d) * denominator for ingredient %s (%d) should be equal to created amount of %d.\n\nGot (amount = %d)/(numerator = %d) * (denominator = %d) = %d\nWant (amount/numerator * denominator = %d)",
-				ingredient.Type, sourceAmt, ingredient.Type, ingredient.Numerator, ingredient.Type, ingredient.Denominator, createdAmount, sourceAmt, ingredient.Numerator, ingredient.Denominator, (int64(sourceAmt) / ingredient.Numerator * ingredient.Denominator), createdAmount)
-		}
+	err = checkManufacturers(recipeName, recipe, mDigest[:], creatorPublicKey, approvalSigs)
+	if err != nil {
+		return nil, err
+	}
+	err = checkProducts(recipe, createdAmount)
+	if err != nil {
+		return nil, err
 	}
+	return checkRatios(recipe, sourceAmounts, createdAmount, returnChange)
+}
 
-	output := OTX.New(creatorPublicKey, createdAmount, recipe.CreatedType, data, p.Counter)
-	p.Outputs = append(p.Outputs, *output)
-	newCounter := sha256.Sum256(p.Counter)
-	p.Counter = newCounter[:]
-	return nil
+// mintProducts appends the products of a combine to p's outputs.
+func (p *Pop) mintProducts(recipe TuxedoPopsStore.Recipe, createdAmount int, creator *btcec.PublicKey, data string) {
+	for _, product := range RecipeProducts(recipe, createdAmount) {
+		output := OTX.New(creator, product.Amount, product.Type, data, p.Counter)
+		p.Outputs = append(p.Outputs, *output)
+		newCounter := sha256.Sum256(p.Counter)
+		p.Counter = newCounter[:]
+	}
 }
 
-func (p *Pop) SetOwner(idx int, threshold int, data string, newOwnersBytes [][]byte, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte) error {
+func (p *Pop) SetOwner(idx int, threshold int, data string, newOwnersBytes [][]byte, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckVersion(version)
+	if err != nil {
+		return err
+	}
 
 	pubkey, err := btcec.ParsePubKey(PopPubKey, btcec.S256())
 
@@ -329,6 +627,10 @@ func (p *Pop) SetOwner(idx int, threshold int, data string, newOwnersBytes [][]b
 	if idx >= len(p.Outputs) {
 		return fmt.Errorf("Invalid index")
 	}
+	err = p.checkTimeLock(idx, p.Outputs[idx].Amount, now)
+	if err != nil {
+		return err
+	}
 
 	for i, newowns := range newOwnersBytes {
 		pubKey, err := btcec.ParsePubKey(newowns, btcec.S256())
@@ -339,18 +641,7 @@ func (p *Pop) SetOwner(idx int, threshold int, data string, newOwnersBytes [][]b
 	}
 	//Retrieve output
 
-	m := hex.EncodeToString(p.Counter)
-	m += ":" + strconv.FormatInt(int64(idx), 10)
-	if threshold > 0 {
-		m += ":" + strconv.FormatInt(int64(threshold), 10)
-	}
-	m += ":" + data
-	for _, newO := range newOwners {
-		if newO.Curve != nil && newO.X != nil && newO.Y != nil {
-			m += ":"
-			m += hex.EncodeToString(newO.SerializeCompressed())
-		}
-	}
+	m := SetOwnerMessage(version, p.Counter, idx, threshold, data, newOwners)
 	// fmt.Printf("Verify message %s \n", m)
 
 	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
@@ -372,6 +663,349 @@ func (p *Pop) SetOwner(idx int, threshold int, data string, newOwnersBytes [][]b
 	return nil
 }
 
+// BurnOutput destroys amount units of an output, removing the output once it
+// is empty. The redemption reference is recorded in the signed message.
+func (p *Pop) BurnOutput(idx int, amount int, redemption string, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	pubkey, err := btcec.ParsePubKey(PopPubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Pop key")
+	}
+	p.PubKey = *pubkey
+
+	keyDigest := sha256.Sum256(PopPubKey)
+	PopAddress := hex.EncodeToString(keyDigest[:20])
+	if PopAddress != p.Address {
+		return fmt.Errorf("Invalid Pop Public Key")
+	}
+
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Burn amount must be positive")
+	}
+	if p.Outputs[idx].Amount < amount {
+		return fmt.Errorf("Insufficient amount")
+	}
+	err = p.checkTimeLock(idx, amount, now)
+	if err != nil {
+		return err
+	}
+
+	m := BurnMessage(version, p.Counter, idx, amount, redemption)
+	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
+	if err != nil {
+		return err
+	}
+
+	p.Outputs[idx].Amount -= amount
+	if p.Outputs[idx].Amount == 0 {
+		p.Outputs = append(p.Outputs[:idx], p.Outputs[idx+1:]...)
+	} else {
+		p.Outputs[idx].PrevCounter = make([]byte, len(p.Counter))
+		copy(p.Outputs[idx].PrevCounter, p.Counter)
+	}
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
+	return nil
+}
+
+// SwapOutputs atomically moves amount units of output idx on p to other and
+// otherAmount units of output otherIdx on other to p. Both sides sign the same
+// SwapMessage so that neither move can happen without the other.
+func (p *Pop) SwapOutputs(idx int, amount int, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte,
+	other *Pop, otherIdx int, otherAmount int, otherOwnerSigs [][]byte, otherPopPubKey []byte, otherPopSig []byte,
+	data string, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	if p.Address == other.Address {
+		return fmt.Errorf("The popcodes of a swap must be different, got %s twice", p.Address)
+	}
+	err = p.checkPubKey(PopPubKey)
+	if err != nil {
+		return err
+	}
+	err = other.checkPubKey(otherPopPubKey)
+	if err != nil {
+		return err
+	}
+
+	if idx < 0 || idx >= len(p.Outputs) || otherIdx < 0 || otherIdx >= len(other.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	if amount <= 0 || otherAmount <= 0 {
+		return fmt.Errorf("Swap amounts must be positive")
+	}
+	if p.Outputs[idx].Amount < amount || other.Outputs[otherIdx].Amount < otherAmount {
+		return fmt.Errorf("Insufficient amount")
+	}
+	err = p.checkTimeLock(idx, amount, now)
+	if err != nil {
+		return err
+	}
+	err = other.checkTimeLock(otherIdx, otherAmount, now)
+	if err != nil {
+		return err
+	}
+
+	m := SwapMessage(version, p.Counter, p.Address, idx, amount, other.Counter, other.Address, otherIdx, otherAmount, data)
+	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
+	if err != nil {
+		return err
+	}
+	err = other.verifyPopSigs(otherIdx, m, otherOwnerSigs, otherPopSig)
+	if err != nil {
+		return err
+	}
+
+	// both counters move so the signatures can not be replayed
+	p.moveOutput(idx, amount, other, data)
+	other.moveOutput(otherIdx, otherAmount, p, data)
+	return nil
+}
+
+// LockOutput moves amount units of output idx into a new output locked to
+// hash until expiry, or locks the whole output in place. now is the
+// transaction time in unix seconds.
+func (p *Pop) LockOutput(idx int, amount int, hash []byte, expiry int64, recipientBytes []byte,
+	ownerSigs [][]byte, PopPubKey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	err = p.checkPubKey(PopPubKey)
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Locked amount must be positive")
+	}
+	if p.Outputs[idx].Amount < amount {
+		return fmt.Errorf("Insufficient amount")
+	}
+	err = p.checkTimeLock(idx, amount, now)
+	if err != nil {
+		return err
+	}
+	if len(hash) != sha256.Size {
+		return fmt.Errorf("Hash lock must be a %d byte sha256 hash", sha256.Size)
+	}
+	if expiry <= now {
+		return fmt.Errorf("Hash lock expiry %d is not after the transaction time %d", expiry, now)
+	}
+	recipient, err := btcec.ParsePubKey(recipientBytes, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Recipient key")
+	}
+
+	m := HashLockMessage(version, p.Counter, idx, amount, hash, expiry, recipientBytes)
+	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
+	if err != nil {
+		return err
+	}
+
+	lock := OTX.HashLock{Hash: hash, Expiry: expiry, Recipient: recipient}
+	if p.Outputs[idx].Amount == amount {
+		p.Outputs[idx].HashLock = &lock
+		p.Outputs[idx].PrevCounter = make([]byte, len(p.Counter))
+		copy(p.Outputs[idx].PrevCounter, p.Counter)
+	} else {
+		locked := p.Outputs[idx]
+		locked.Amount = amount
+		locked.TimeLock = nil
+		locked.HashLock = &lock
+		locked.PrevCounter = make([]byte, len(p.Counter))
+		copy(locked.PrevCounter, p.Counter)
+		p.Outputs[idx].Amount -= amount
+		p.Outputs = append(p.Outputs, locked)
+	}
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
+	return nil
+}
+
+// ClaimOutput hands a hash locked output to its recipient, who signs
+// ClaimMessage and reveals the preimage of the hash before the lock expires.
+func (p *Pop) ClaimOutput(idx int, preimage []byte, recipientSig []byte, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	lock := p.Outputs[idx].HashLock
+	if lock == nil {
+		return fmt.Errorf("Output %d is not hash locked", idx)
+	}
+	if now >= lock.Expiry {
+		return fmt.Errorf("Hash lock expired at %d", lock.Expiry)
+	}
+	preimageHash := sha256.Sum256(preimage)
+	if !bytes.Equal(preimageHash[:], lock.Hash) {
+		return fmt.Errorf("Preimage does not match the hash lock")
+	}
+
+	m := ClaimMessage(version, p.Counter, idx, preimage)
+	mDigest := sha256.Sum256(m)
+	signature, err := btcec.ParseDERSignature(recipientSig, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Bad Recipient signature encoding %v", recipientSig)
+	}
+	if !signature.Verify(mDigest[:], lock.Recipient) {
+		return fmt.Errorf("Invalid Recipient Signature on %q", m)
+	}
+
+	p.Outputs[idx].Owners = []btcec.PublicKey{*lock.Recipient}
+	p.Outputs[idx].Threshold = 1
+	p.Outputs[idx].HashLock = nil
+	p.Outputs[idx].PrevCounter = make([]byte, len(p.Counter))
+	copy(p.Outputs[idx].PrevCounter, p.Counter)
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
+	return nil
+}
+
+// RefundOutput removes an expired hash lock, returning the output to the
+// owners it had when it was locked.
+func (p *Pop) RefundOutput(idx int, ownerSigs [][]byte, PopPubKey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	err = p.checkPubKey(PopPubKey)
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	lock := p.Outputs[idx].HashLock
+	if lock == nil {
+		return fmt.Errorf("Output %d is not hash locked", idx)
+	}
+	if now < lock.Expiry {
+		return fmt.Errorf("Hash lock does not expire until %d", lock.Expiry)
+	}
+
+	m := RefundMessage(version, p.Counter, idx)
+	err = p.verifySigs(idx, m, ownerSigs, PopSig)
+	if err != nil {
+		return err
+	}
+
+	p.Outputs[idx].HashLock = nil
+	p.Outputs[idx].PrevCounter = make([]byte, len(p.Counter))
+	copy(p.Outputs[idx].PrevCounter, p.Counter)
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
+	return nil
+}
+
+// TimeLockOutput moves amount units of output idx into a new output that
+// vests between start and end as described by OTX.TimeLock, or locks the
+// whole output in place.
+func (p *Pop) TimeLockOutput(idx int, amount int, start int64, end int64, period int64,
+	ownerSigs [][]byte, PopPubKey []byte, PopSig []byte, now int64, version int) error {
+
+	err := CheckCanonical(version)
+	if err != nil {
+		return err
+	}
+	err = p.checkPubKey(PopPubKey)
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Locked amount must be positive")
+	}
+	if p.Outputs[idx].Amount < amount {
+		return fmt.Errorf("Insufficient amount")
+	}
+	if p.Outputs[idx].TimeLock != nil {
+		return fmt.Errorf("Output %d is already time locked", idx)
+	}
+	if end < start || period < 0 {
+		return fmt.Errorf("Invalid vesting schedule start %d end %d period %d", start, end, period)
+	}
+	if end <= now {
+		return fmt.Errorf("Time lock end %d is not after the transaction time %d", end, now)
+	}
+
+	m := TimeLockMessage(version, p.Counter, idx, amount, start, end, period)
+	err = p.verifyPopSigs(idx, m, ownerSigs, PopSig)
+	if err != nil {
+		return err
+	}
+
+	lock := OTX.TimeLock{Start: start, End: end, Period: period, Total: amount}
+	if p.Outputs[idx].Amount == amount {
+		p.Outputs[idx].TimeLock = &lock
+		p.Outputs[idx].PrevCounter = make([]byte, len(p.Counter))
+		copy(p.Outputs[idx].PrevCounter, p.Counter)
+	} else {
+		locked := p.Outputs[idx]
+		locked.Amount = amount
+		locked.TimeLock = &lock
+		locked.PrevCounter = make([]byte, len(p.Counter))
+		copy(locked.PrevCounter, p.Counter)
+		p.Outputs[idx].Amount -= amount
+		p.Outputs = append(p.Outputs, locked)
+	}
+	digest := sha256.Sum256(p.Counter)
+	p.Counter = digest[:]
+	return nil
+}
+
+// checkTimeLock returns an error if spending amount units of output idx at
+// now would spend units that have not vested.
+func (p *Pop) checkTimeLock(idx int, amount int, now int64) error {
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("Invalid index")
+	}
+	lock := p.Outputs[idx].TimeLock
+	if lock == nil {
+		return nil
+	}
+	unvested := lock.Unvested(now)
+	if p.Outputs[idx].Amount-amount < unvested {
+		return fmt.Errorf("Output %d has %d unvested units, fully vested at %d", idx, unvested, lock.End)
+	}
+	return nil
+}
+
+// checkPubKey sets the public key of p after checking it derives p's address.
+func (p *Pop) checkPubKey(PopPubKey []byte) error {
+	pubkey, err := btcec.ParsePubKey(PopPubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Pop key")
+	}
+	keyDigest := sha256.Sum256(PopPubKey)
+	PopAddress := hex.EncodeToString(keyDigest[:20])
+	if PopAddress != p.Address {
+		return fmt.Errorf("Invalid Pop Public Key for address %v", p.Address)
+	}
+	p.PubKey = *pubkey
+	return nil
+}
+
 func (p *Pop) ToBytes() []byte {
 	store := TuxedoPopsStore.TuxedoPops{}
 	store.Address = p.Address
@@ -0,0 +1,17 @@
+package Pop
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// IsIssuer reports whether key is one of the serialized issuer keys of a
+// registered asset type.
+func IsIssuer(issuers [][]byte, key *btcec.PublicKey) bool {
+	for _, issuerBytes := range issuers {
+		issuer, err := btcec.ParsePubKey(issuerBytes, btcec.S256())
+		if err == nil && issuer.IsEqual(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,387 @@
+package Pop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+)
+
+// The functions in this file build the exact messages that are hashed and
+// signed for each operation. They are shared by the Pop methods that verify
+// signatures and by clients that produce them.
+//
+// LegacyEncoding joins the fields with ":" which is ambiguous when a string
+// field contains ":". CanonicalEncoding starts with a domain separation tag
+// naming the operation and a version byte, followed by every field length
+// prefixed. The version is chosen by the Version field of each transaction.
+// Operations added after the canonical encoding only have that encoding.
+const (
+	LegacyEncoding    = 0
+	CanonicalEncoding = 1
+)
+
+const domainTag = "TuxedoPops"
+
+func CheckVersion(version int) error {
+	if version != LegacyEncoding && version != CanonicalEncoding {
+		return fmt.Errorf("Unsupported signing version %d", version)
+	}
+	return nil
+}
+
+// CheckCanonical rejects any version but CanonicalEncoding, for the operations
+// that have no legacy encoding.
+func CheckCanonical(version int) error {
+	if version != CanonicalEncoding {
+		return fmt.Errorf("Unsupported signing version %d, only %d is accepted", version, CanonicalEncoding)
+	}
+	return nil
+}
+
+// encoder writes the canonical encoding of a message.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func newEncoder(op string, version int) *encoder {
+	e := encoder{}
+	e.string(domainTag + ":" + op)
+	e.buf.WriteByte(byte(version))
+	return &e
+}
+
+func (e *encoder) bytes(b []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+	e.buf.Write(length[:])
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) int(i int) {
+	e.int64(int64(i))
+}
+
+func (e *encoder) int64(i int64) {
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(i))
+	e.buf.Write(value[:])
+}
+
+func (e *encoder) ints(is []int) {
+	e.int(len(is))
+	for _, i := range is {
+		e.int(i)
+	}
+}
+
+func (e *encoder) message() []byte {
+	return e.buf.Bytes()
+}
+
+// CreateMessage is signed by the creator of a new output.
+func CreateMessage(version int, counter []byte, address string, amount int, assetType string, data string) []byte {
+	if version == LegacyEncoding {
+		return []byte(hex.EncodeToString(counter) + ":" + address + ":" + strconv.FormatInt(int64(amount), 10) + ":" + assetType + ":" + data)
+	}
+	e := newEncoder("create", version)
+	e.bytes(counter)
+	e.string(address)
+	e.int(amount)
+	e.string(assetType)
+	e.string(data)
+	return e.message()
+}
+
+// UnitizeMessage is signed by the owners and popcode of the source output.
+func UnitizeMessage(version int, counter []byte, destAddress string, data string, idx int, amounts []int) []byte {
+	if version == LegacyEncoding {
+		m := hex.EncodeToString(counter) + ":" + destAddress + ":" + data
+		m += ":" + strconv.FormatInt(int64(idx), 10)
+		for _, amount := range amounts {
+			m += ":" + strconv.FormatInt(int64(amount), 10)
+		}
+		return []byte(m)
+	}
+	e := newEncoder("unitize", version)
+	e.bytes(counter)
+	e.string(destAddress)
+	e.string(data)
+	e.int(idx)
+	e.ints(amounts)
+	return e.message()
+}
+
+// CombineMessage is signed by the creator, the owners and the popcode.
+// recipeVersion is 0 unless the combine pins a version of the recipe and
+// returnChange is only covered when set, so older combines sign the same bytes.
+// The legacy encoding has no room for a pinned version or the change flag.
+func CombineMessage(version int, counter []byte, recipeName string, recipeVersion int, sources []SourceOutput, createdAmount int, data string, returnChange bool) []byte {
+	if version == LegacyEncoding {
+		m := hex.EncodeToString(counter)
+		m += ":" + recipeName
+		for _, source := range sources {
+			m += ":" + strconv.FormatInt(int64(source.Idx()), 10)
+			m += ":" + strconv.FormatInt(int64(source.Amount()), 10)
+		}
+		m += ":" + strconv.FormatInt(int64(createdAmount), 10)
+		m += ":" + data
+		return []byte(m)
+	}
+	e := newEncoder("combine", version)
+	if recipeVersion > 0 {
+		e = newEncoder("combinePinned", version)
+	}
+	e.bytes(counter)
+	e.string(recipeName)
+	if recipeVersion > 0 {
+		e.int(recipeVersion)
+	}
+	e.int(len(sources))
+	for _, source := range sources {
+		e.int(source.Idx())
+		e.int(source.Amount())
+	}
+	e.int(createdAmount)
+	e.string(data)
+	if returnChange {
+		e.string("change")
+	}
+	return e.message()
+}
+
+// MultiCombineMessage is signed by the creator and by the owners and popcode of
+// every source popcode of a combine across popcodes. sources[i] are the
+// sources taken from addresses[i] while its counter is counters[i].
+func MultiCombineMessage(version int, addresses []string, counters [][]byte, sources [][]SourceOutput, destAddress string,
+	recipeName string, recipeVersion int, createdAmount int, data string, returnChange bool) []byte {
+	e := newEncoder("multiCombine", version)
+	e.string(destAddress)
+	e.string(recipeName)
+	e.int(recipeVersion)
+	e.int(len(addresses))
+	for i, address := range addresses {
+		e.string(address)
+		e.bytes(counters[i])
+		e.int(len(sources[i]))
+		for _, source := range sources[i] {
+			e.int(source.Idx())
+			e.int(source.Amount())
+		}
+	}
+	e.int(createdAmount)
+	e.string(data)
+	if returnChange {
+		e.string("change")
+	}
+	return e.message()
+}
+
+// SetOwnerMessage is signed by the current owners and the popcode.
+func SetOwnerMessage(version int, counter []byte, idx int, threshold int, data string, newOwners []btcec.PublicKey) []byte {
+	if version == LegacyEncoding {
+		m := hex.EncodeToString(counter)
+		m += ":" + strconv.FormatInt(int64(idx), 10)
+		if threshold > 0 {
+			m += ":" + strconv.FormatInt(int64(threshold), 10)
+		}
+		m += ":" + data
+		for _, newO := range newOwners {
+			if newO.Curve != nil && newO.X != nil && newO.Y != nil {
+				m += ":"
+				m += hex.EncodeToString(newO.SerializeCompressed())
+			}
+		}
+		return []byte(m)
+	}
+	e := newEncoder("transfer", version)
+	e.bytes(counter)
+	e.int(idx)
+	e.int(threshold)
+	e.string(data)
+	e.int(len(newOwners))
+	for _, newO := range newOwners {
+		e.bytes(newO.SerializeCompressed())
+	}
+	return e.message()
+}
+
+// RecipeMessage is signed by the creator registering a version of a recipe.
+// The first version, an empty list of byproducts and an empty manufacturer
+// policy are left out so that registrations from before recipes had them stay
+// valid. The legacy encoding has no room for a version, byproducts or
+// manufacturers.
+func RecipeMessage(version int, recipeName string, recipeVersion int, createdType string, ingredients []*TuxedoPopsTX.Ingredient,
+	byproducts []*TuxedoPopsTX.Product, manufacturers [][]byte, threshold int) []byte {
+	if version == LegacyEncoding {
+		m := recipeName + ":" + createdType
+		for _, ingredient := range ingredients {
+			m += ":" + strconv.FormatInt(int64(ingredient.Numerator), 10) + ":" +
+				strconv.FormatInt(int64(ingredient.Denominator), 10) + ":" + ingredient.Type
+		}
+		return []byte(m)
+	}
+	e := newEncoder("recipe", version)
+	if recipeVersion > 1 {
+		e = newEncoder("recipeVersion", version)
+	}
+	e.string(recipeName)
+	if recipeVersion > 1 {
+		e.int(recipeVersion)
+	}
+	e.string(createdType)
+	e.int(len(ingredients))
+	for _, ingredient := range ingredients {
+		e.int(int(ingredient.Numerator))
+		e.int(int(ingredient.Denominator))
+		e.string(ingredient.Type)
+	}
+	if len(byproducts) > 0 || len(manufacturers) > 0 {
+		e.int(len(byproducts))
+		for _, byproduct := range byproducts {
+			e.int(int(byproduct.Numerator))
+			e.int(int(byproduct.Denominator))
+			e.string(byproduct.Type)
+		}
+	}
+	if len(manufacturers) > 0 {
+		e.int(threshold)
+		e.int(len(manufacturers))
+		for _, manufacturer := range manufacturers {
+			e.bytes(manufacturer)
+		}
+	}
+	return e.message()
+}
+
+// RecipeStatusMessage is signed by the creator of a recipe to deprecate or
+// revoke one of its versions.
+func RecipeStatusMessage(version int, recipeName string, recipeVersion int, status int) []byte {
+	e := newEncoder("recipeStatus", version)
+	e.string(recipeName)
+	e.int(recipeVersion)
+	e.int(status)
+	return e.message()
+}
+
+// RegisterTypeMessage is signed by an issuer of the asset type, one of the new
+// issuers when the type is first registered and one of the current issuers
+// when revision replaces an earlier registration. supplyCap is only covered
+// when the type has an issuance cap.
+func RegisterTypeMessage(version int, name string, revision int, issuers [][]byte, displayName string, unit string,
+	decimals int, metadataHash []byte, supplyCap int64) []byte {
+	e := newEncoder("registerType", version)
+	e.string(name)
+	e.int(revision)
+	e.int(len(issuers))
+	for _, issuer := range issuers {
+		e.bytes(issuer)
+	}
+	e.string(displayName)
+	e.string(unit)
+	e.int(decimals)
+	e.bytes(metadataHash)
+	if supplyCap > 0 {
+		e.int64(supplyCap)
+	}
+	return e.message()
+}
+
+// BurnMessage is signed by the owners and popcode of the burned output.
+func BurnMessage(version int, counter []byte, idx int, amount int, redemption string) []byte {
+	e := newEncoder("burn", version)
+	e.bytes(counter)
+	e.int(idx)
+	e.int(amount)
+	e.string(redemption)
+	return e.message()
+}
+
+// MultiUnitizeMessage is signed by the owners and popcode of the source output
+// and covers every destination of the distribution.
+func MultiUnitizeMessage(version int, counter []byte, idx int, destAddresses []string, amounts [][]int, keepChange bool, data string) []byte {
+	change := 0
+	if keepChange {
+		change = 1
+	}
+	e := newEncoder("multiUnitize", version)
+	e.bytes(counter)
+	e.int(idx)
+	e.int(change)
+	e.int(len(destAddresses))
+	for i, destAddress := range destAddresses {
+		e.string(destAddress)
+		e.ints(amounts[i])
+	}
+	e.string(data)
+	return e.message()
+}
+
+// SwapMessage is signed by the owners and popcodes of both sides of a swap so
+// that neither side can be executed on its own.
+func SwapMessage(version int, counterA []byte, addressA string, idxA int, amountA int,
+	counterB []byte, addressB string, idxB int, amountB int, data string) []byte {
+	e := newEncoder("swap", version)
+	e.bytes(counterA)
+	e.string(addressA)
+	e.int(idxA)
+	e.int(amountA)
+	e.bytes(counterB)
+	e.string(addressB)
+	e.int(idxB)
+	e.int(amountB)
+	e.string(data)
+	return e.message()
+}
+
+// HashLockMessage is signed by the owners and popcode of an output to lock
+// amount units of it to hash, expiry and the recipient's public key.
+func HashLockMessage(version int, counter []byte, idx int, amount int, hash []byte, expiry int64, recipient []byte) []byte {
+	e := newEncoder("hashLock", version)
+	e.bytes(counter)
+	e.int(idx)
+	e.int(amount)
+	e.bytes(hash)
+	e.int64(expiry)
+	e.bytes(recipient)
+	return e.message()
+}
+
+// ClaimMessage is signed by the recipient of a hash locked output.
+func ClaimMessage(version int, counter []byte, idx int, preimage []byte) []byte {
+	e := newEncoder("claim", version)
+	e.bytes(counter)
+	e.int(idx)
+	e.bytes(preimage)
+	return e.message()
+}
+
+// RefundMessage is signed by the owners and popcode of an expired hash
+// locked output.
+func RefundMessage(version int, counter []byte, idx int) []byte {
+	e := newEncoder("refund", version)
+	e.bytes(counter)
+	e.int(idx)
+	return e.message()
+}
+
+// TimeLockMessage is signed by the owners and popcode of an output to lock
+// amount units of it to a vesting schedule.
+func TimeLockMessage(version int, counter []byte, idx int, amount int, start int64, end int64, period int64) []byte {
+	e := newEncoder("timeLock", version)
+	e.bytes(counter)
+	e.int(idx)
+	e.int(amount)
+	e.int64(start)
+	e.int64(end)
+	e.int64(period)
+	return e.message()
+}
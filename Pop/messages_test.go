@@ -0,0 +1,67 @@
+package Pop_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skuchain/TuxedoPops/Pop"
+)
+
+func TestCanonicalEncodingSeparatesFields(t *testing.T) {
+	counter := []byte{1, 2, 3}
+	a := Pop.CreateMessage(Pop.LegacyEncoding, counter, "addr", 1, "Coffee:Beans", "data")
+	b := Pop.CreateMessage(Pop.LegacyEncoding, counter, "addr", 1, "Coffee", "Beans:data")
+	if !bytes.Equal(a, b) {
+		t.Fatalf("expected legacy messages to collide, got %q and %q", a, b)
+	}
+
+	a = Pop.CreateMessage(Pop.CanonicalEncoding, counter, "addr", 1, "Coffee:Beans", "data")
+	b = Pop.CreateMessage(Pop.CanonicalEncoding, counter, "addr", 1, "Coffee", "Beans:data")
+	if bytes.Equal(a, b) {
+		t.Fatalf("canonical messages collide: %q", a)
+	}
+}
+
+func TestCanonicalEncodingSeparatesOperations(t *testing.T) {
+	counter := []byte{1, 2, 3}
+	unitize := Pop.UnitizeMessage(Pop.CanonicalEncoding, counter, "addr", "data", 0, nil)
+	transfer := Pop.SetOwnerMessage(Pop.CanonicalEncoding, counter, 0, 0, "data", nil)
+	if bytes.Equal(unitize, transfer) || bytes.HasPrefix(unitize, transfer) || bytes.HasPrefix(transfer, unitize) {
+		t.Fatalf("unitize and transfer messages are not domain separated")
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	if err := Pop.CheckVersion(Pop.LegacyEncoding); err != nil {
+		t.Error(err)
+	}
+	if err := Pop.CheckVersion(Pop.CanonicalEncoding); err != nil {
+		t.Error(err)
+	}
+	if err := Pop.CheckVersion(2); err == nil {
+		t.Error("expected unknown version to be rejected")
+	}
+}
+
+func TestCheckCanonical(t *testing.T) {
+	if err := Pop.CheckCanonical(Pop.CanonicalEncoding); err != nil {
+		t.Error(err)
+	}
+	for _, version := range []int{Pop.LegacyEncoding, 2} {
+		if err := Pop.CheckCanonical(version); err == nil {
+			t.Errorf("expected version %d to be rejected", version)
+		}
+	}
+}
+
+func TestRecipeMessageFirstVersion(t *testing.T) {
+	unversioned := Pop.RecipeMessage(Pop.CanonicalEncoding, "Brew", 0, "Coffee", nil, nil, nil, 0)
+	first := Pop.RecipeMessage(Pop.CanonicalEncoding, "Brew", 1, "Coffee", nil, nil, nil, 0)
+	second := Pop.RecipeMessage(Pop.CanonicalEncoding, "Brew", 2, "Coffee", nil, nil, nil, 0)
+	if !bytes.Equal(unversioned, first) {
+		t.Errorf("version 1 of a recipe is not signed like an unversioned recipe")
+	}
+	if bytes.Equal(first, second) {
+		t.Errorf("recipe versions are not distinguished")
+	}
+}
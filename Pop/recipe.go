@@ -0,0 +1,192 @@
+package Pop
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+)
+
+// Every version of a recipe starts out active. Its creator can deprecate it,
+// which keeps it usable by combines that pin it but skips it otherwise, or
+// revoke it, which stops any further combines against it. Outputs already
+// created under a revoked version stay valid.
+const (
+	RecipeActive     = 0
+	RecipeDeprecated = 1
+	RecipeRevoked    = 2
+)
+
+var recipeStatusNames = []string{"active", "deprecated", "revoked"}
+
+// RecipeStatusName returns the name of a recipe status.
+func RecipeStatusName(status int) string {
+	if status < 0 || status >= len(recipeStatusNames) {
+		return fmt.Sprintf("unknown(%d)", status)
+	}
+	return recipeStatusNames[status]
+}
+
+// ParseRecipeStatus is the inverse of RecipeStatusName.
+func ParseRecipeStatus(name string) (int, error) {
+	for status, statusName := range recipeStatusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown recipe status %s", name)
+}
+
+// Product is an output minted by a combine.
+type Product struct {
+	Type   string
+	Amount int
+}
+
+// RecipeProducts returns the outputs minted by a combine creating
+// createdAmount units under recipe: the created type first, then every
+// byproduct at its ratio to createdAmount rounded down. Byproducts that round
+// down to nothing are left out.
+func RecipeProducts(recipe TuxedoPopsStore.Recipe, createdAmount int) []Product {
+	products := []Product{{Type: recipe.CreatedType, Amount: createdAmount}}
+	for _, byproduct := range recipe.Byproducts {
+		amount := int64(createdAmount) * byproduct.Numerator / byproduct.Denominator
+		if amount > 0 {
+			products = append(products, Product{Type: byproduct.Type, Amount: int(amount)})
+		}
+	}
+	return products
+}
+
+// checkProducts checks that every product RecipeProducts returns for
+// createdAmount fits the int32 amounts of transactions and events.
+func checkProducts(recipe TuxedoPopsStore.Recipe, createdAmount int) error {
+	if createdAmount > math.MaxInt32 {
+		return fmt.Errorf("Created amount %d is too large", createdAmount)
+	}
+	for _, byproduct := range recipe.Byproducts {
+		if byproduct.Denominator <= 0 {
+			return fmt.Errorf("Invalid ratio %d/%d for byproduct %s", byproduct.Numerator, byproduct.Denominator, byproduct.Type)
+		}
+		amount, err := mulInt64(int64(createdAmount), byproduct.Numerator)
+		if err != nil {
+			return err
+		}
+		if amount/byproduct.Denominator > math.MaxInt32 {
+			return fmt.Errorf("Byproduct %s of %d units is too large", byproduct.Type, amount/byproduct.Denominator)
+		}
+	}
+	return nil
+}
+
+// checkManufacturers enforces the policy of a recipe listing Manufacturers:
+// the creator of a combine must be one of them and, with a Threshold above
+// one, other listed manufacturers must approve the combine by signing its
+// message until Threshold of them have signed. Recipes without manufacturers
+// can be used by anyone.
+func checkManufacturers(recipeName string, recipe TuxedoPopsStore.Recipe, mDigest []byte, creator *btcec.PublicKey, approvalSigs [][]byte) error {
+	if len(recipe.Manufacturers) == 0 {
+		return nil
+	}
+	manufacturers := make([]*btcec.PublicKey, len(recipe.Manufacturers))
+	signed := make([]bool, len(recipe.Manufacturers))
+	approvals := 0
+	for i, keyBytes := range recipe.Manufacturers {
+		key, err := btcec.ParsePubKey(keyBytes, btcec.S256())
+		if err != nil {
+			return fmt.Errorf("Invalid manufacturer key %x in recipe %s", keyBytes, recipeName)
+		}
+		manufacturers[i] = key
+		if approvals == 0 && key.IsEqual(creator) {
+			signed[i] = true
+			approvals++
+		}
+	}
+	if approvals == 0 {
+		return fmt.Errorf("Creator is not a manufacturer of recipe %s", recipeName)
+	}
+
+	for _, sigBytes := range approvalSigs {
+		signature, err := btcec.ParseDERSignature(sigBytes, btcec.S256())
+		if err != nil {
+			return fmt.Errorf("Bad approval signature encoding %v", sigBytes)
+		}
+		for i, key := range manufacturers {
+			if !signed[i] && signature.Verify(mDigest, key) {
+				signed[i] = true
+				approvals++
+				break
+			}
+		}
+	}
+	if approvals < int(recipe.Threshold) {
+		return fmt.Errorf("Recipe %s requires %d manufacturers, combine has %d", recipeName, recipe.Threshold, approvals)
+	}
+	return nil
+}
+
+// checkRatios checks that the amounts of each type consumed by a combine
+// match the recipe's ingredient ratios for createdAmount exactly, i.e. that
+// amount * Denominator == createdAmount * Numerator. With returnChange the
+// sources may hold more than needed and checkRatios returns, by type, what is
+// left once every ingredient has been taken rounded up to whole units.
+func checkRatios(recipe TuxedoPopsStore.Recipe, sourceAmounts map[string]int, createdAmount int, returnChange bool) (map[string]int, error) {
+	ingredients := make(map[string]bool)
+	for _, ingredient := range recipe.Ingredients {
+		ingredients[ingredient.Type] = true
+	}
+	for assetType := range sourceAmounts {
+		if !ingredients[assetType] {
+			return nil, fmt.Errorf("%s is not an ingredient of the recipe", assetType)
+		}
+	}
+	change := make(map[string]int)
+	for _, ingredient := range recipe.Ingredients {
+		if ingredient.Numerator <= 0 || ingredient.Denominator <= 0 {
+			return nil, fmt.Errorf("Invalid ratio %d/%d for %s", ingredient.Numerator, ingredient.Denominator, ingredient.Type)
+		}
+		sourceAmt := int64(sourceAmounts[ingredient.Type])
+		needed, err := mulInt64(int64(createdAmount), ingredient.Numerator)
+		if err != nil {
+			return nil, err
+		}
+		if !returnChange {
+			supplied, err := mulInt64(sourceAmt, ingredient.Denominator)
+			if err != nil {
+				return nil, err
+			}
+			if supplied != needed {
+				return nil, fmt.Errorf("Ratio invalid for %s: %d units at %d/%d per unit do not make exactly %d",
+					ingredient.Type, sourceAmt, ingredient.Numerator, ingredient.Denominator, createdAmount)
+			}
+			continue
+		}
+		used := needed / ingredient.Denominator
+		if needed%ingredient.Denominator != 0 {
+			used++
+		}
+		if sourceAmt < used {
+			return nil, fmt.Errorf("Ratio invalid for %s: %d units at %d/%d per unit make less than %d",
+				ingredient.Type, sourceAmt, ingredient.Numerator, ingredient.Denominator, createdAmount)
+		}
+		if sourceAmt > used {
+			change[ingredient.Type] = int(sourceAmt - used)
+		}
+	}
+	if !returnChange {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// mulInt64 multiplies two non-negative amounts, failing instead of wrapping.
+func mulInt64(a int64, b int64) (int64, error) {
+	if a < 0 || b < 0 {
+		return 0, fmt.Errorf("Negative amount in ratio")
+	}
+	if a != 0 && b > math.MaxInt64/a {
+		return 0, fmt.Errorf("Ratio overflows: %d * %d", a, b)
+	}
+	return a * b, nil
+}
@@ -11,8 +11,16 @@ It is generated from these files:
 It has these top-level messages:
 	TuxedoPops
 	OTX
+	HashLock
+	TimeLock
 	Ingredient
 	Recipe
+	Product
+	AssetType
+	Supply
+	Lineage
+	ProofPopcode
+	ProofStep
 */
 package TuxedoPopsStore
 
@@ -43,20 +51,57 @@ func (m *TuxedoPops) GetOutputs() []*OTX {
 }
 
 type OTX struct {
-	Owners      [][]byte `protobuf:"bytes,1,rep,name=Owners,proto3" json:"Owners,omitempty"`
-	Threshold   int64    `protobuf:"varint,2,opt,name=Threshold" json:"Threshold,omitempty"`
-	Amount      int64    `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
-	Type        string   `protobuf:"bytes,4,opt,name=Type" json:"Type,omitempty"`
-	Data        string   `protobuf:"bytes,5,opt,name=Data" json:"Data,omitempty"`
-	Recipe      string   `protobuf:"bytes,6,opt,name=Recipe" json:"Recipe,omitempty"`
-	Creator     []byte   `protobuf:"bytes,7,opt,name=Creator,proto3" json:"Creator,omitempty"`
-	PrevCounter []byte   `protobuf:"bytes,8,opt,name=PrevCounter,proto3" json:"PrevCounter,omitempty"`
+	Owners      [][]byte  `protobuf:"bytes,1,rep,name=Owners,proto3" json:"Owners,omitempty"`
+	Threshold   int64     `protobuf:"varint,2,opt,name=Threshold" json:"Threshold,omitempty"`
+	Amount      int64     `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+	Type        string    `protobuf:"bytes,4,opt,name=Type" json:"Type,omitempty"`
+	Data        string    `protobuf:"bytes,5,opt,name=Data" json:"Data,omitempty"`
+	Recipe      string    `protobuf:"bytes,6,opt,name=Recipe" json:"Recipe,omitempty"`
+	Creator     []byte    `protobuf:"bytes,7,opt,name=Creator,proto3" json:"Creator,omitempty"`
+	PrevCounter []byte    `protobuf:"bytes,8,opt,name=PrevCounter,proto3" json:"PrevCounter,omitempty"`
+	HashLock    *HashLock `protobuf:"bytes,9,opt,name=HashLock" json:"HashLock,omitempty"`
+	TimeLock    *TimeLock `protobuf:"bytes,10,opt,name=TimeLock" json:"TimeLock,omitempty"`
 }
 
 func (m *OTX) Reset()         { *m = OTX{} }
 func (m *OTX) String() string { return proto.CompactTextString(m) }
 func (*OTX) ProtoMessage()    {}
 
+func (m *OTX) GetHashLock() *HashLock {
+	if m != nil {
+		return m.HashLock
+	}
+	return nil
+}
+
+func (m *OTX) GetTimeLock() *TimeLock {
+	if m != nil {
+		return m.TimeLock
+	}
+	return nil
+}
+
+type HashLock struct {
+	Hash      []byte `protobuf:"bytes,1,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	Expiry    int64  `protobuf:"varint,2,opt,name=Expiry" json:"Expiry,omitempty"`
+	Recipient []byte `protobuf:"bytes,3,opt,name=Recipient,proto3" json:"Recipient,omitempty"`
+}
+
+func (m *HashLock) Reset()         { *m = HashLock{} }
+func (m *HashLock) String() string { return proto.CompactTextString(m) }
+func (*HashLock) ProtoMessage()    {}
+
+type TimeLock struct {
+	Start  int64 `protobuf:"varint,1,opt,name=Start" json:"Start,omitempty"`
+	End    int64 `protobuf:"varint,2,opt,name=End" json:"End,omitempty"`
+	Period int64 `protobuf:"varint,3,opt,name=Period" json:"Period,omitempty"`
+	Total  int64 `protobuf:"varint,4,opt,name=Total" json:"Total,omitempty"`
+}
+
+func (m *TimeLock) Reset()         { *m = TimeLock{} }
+func (m *TimeLock) String() string { return proto.CompactTextString(m) }
+func (*TimeLock) ProtoMessage()    {}
+
 type Ingredient struct {
 	Numerator   int64  `protobuf:"varint,1,opt,name=Numerator" json:"Numerator,omitempty"`
 	Denominator int64  `protobuf:"varint,2,opt,name=Denominator" json:"Denominator,omitempty"`
@@ -68,9 +113,15 @@ func (m *Ingredient) String() string { return proto.CompactTextString(m) }
 func (*Ingredient) ProtoMessage()    {}
 
 type Recipe struct {
-	CreatedType string        `protobuf:"bytes,1,opt,name=CreatedType" json:"CreatedType,omitempty"`
-	Ingredients []*Ingredient `protobuf:"bytes,2,rep,name=Ingredients" json:"Ingredients,omitempty"`
-	Creator     []byte        `protobuf:"bytes,3,opt,name=Creator,proto3" json:"Creator,omitempty"`
+	CreatedType   string        `protobuf:"bytes,1,opt,name=CreatedType" json:"CreatedType,omitempty"`
+	Ingredients   []*Ingredient `protobuf:"bytes,2,rep,name=Ingredients" json:"Ingredients,omitempty"`
+	Creator       []byte        `protobuf:"bytes,3,opt,name=Creator,proto3" json:"Creator,omitempty"`
+	Version       int32         `protobuf:"varint,4,opt,name=Version" json:"Version,omitempty"`
+	Status        int32         `protobuf:"varint,5,opt,name=Status" json:"Status,omitempty"`
+	Latest        int32         `protobuf:"varint,6,opt,name=Latest" json:"Latest,omitempty"`
+	Byproducts    []*Product    `protobuf:"bytes,7,rep,name=Byproducts" json:"Byproducts,omitempty"`
+	Manufacturers [][]byte      `protobuf:"bytes,8,rep,name=Manufacturers,proto3" json:"Manufacturers,omitempty"`
+	Threshold     int32         `protobuf:"varint,9,opt,name=Threshold" json:"Threshold,omitempty"`
 }
 
 func (m *Recipe) Reset()         { *m = Recipe{} }
@@ -83,3 +134,102 @@ func (m *Recipe) GetIngredients() []*Ingredient {
 	}
 	return nil
 }
+
+func (m *Recipe) GetByproducts() []*Product {
+	if m != nil {
+		return m.Byproducts
+	}
+	return nil
+}
+
+type Product struct {
+	Numerator   int64  `protobuf:"varint,1,opt,name=Numerator" json:"Numerator,omitempty"`
+	Denominator int64  `protobuf:"varint,2,opt,name=Denominator" json:"Denominator,omitempty"`
+	Type        string `protobuf:"bytes,3,opt,name=Type" json:"Type,omitempty"`
+}
+
+func (m *Product) Reset()         { *m = Product{} }
+func (m *Product) String() string { return proto.CompactTextString(m) }
+func (*Product) ProtoMessage()    {}
+
+type AssetType struct {
+	Issuers      [][]byte `protobuf:"bytes,1,rep,name=Issuers,proto3" json:"Issuers,omitempty"`
+	DisplayName  string   `protobuf:"bytes,2,opt,name=DisplayName,proto3" json:"DisplayName,omitempty"`
+	Unit         string   `protobuf:"bytes,3,opt,name=Unit,proto3" json:"Unit,omitempty"`
+	Decimals     int32    `protobuf:"varint,4,opt,name=Decimals,proto3" json:"Decimals,omitempty"`
+	MetadataHash []byte   `protobuf:"bytes,5,opt,name=MetadataHash,proto3" json:"MetadataHash,omitempty"`
+	Revision     int32    `protobuf:"varint,6,opt,name=Revision,proto3" json:"Revision,omitempty"`
+	Cap          int64    `protobuf:"varint,7,opt,name=Cap,proto3" json:"Cap,omitempty"`
+}
+
+func (m *AssetType) Reset()         { *m = AssetType{} }
+func (m *AssetType) String() string { return proto.CompactTextString(m) }
+func (*AssetType) ProtoMessage()    {}
+
+type Supply struct {
+	Minted int64 `protobuf:"varint,1,opt,name=Minted,proto3" json:"Minted,omitempty"`
+	Burned int64 `protobuf:"varint,2,opt,name=Burned,proto3" json:"Burned,omitempty"`
+}
+
+func (m *Supply) Reset()         { *m = Supply{} }
+func (m *Supply) String() string { return proto.CompactTextString(m) }
+func (*Supply) ProtoMessage()    {}
+
+type Lineage struct {
+	Parents       [][]byte `protobuf:"bytes,1,rep,name=Parents,proto3" json:"Parents,omitempty"`
+	Operation     string   `protobuf:"bytes,2,opt,name=Operation,proto3" json:"Operation,omitempty"`
+	Address       string   `protobuf:"bytes,3,opt,name=Address,proto3" json:"Address,omitempty"`
+	Type          string   `protobuf:"bytes,4,opt,name=Type,proto3" json:"Type,omitempty"`
+	Recipe        string   `protobuf:"bytes,5,opt,name=Recipe,proto3" json:"Recipe,omitempty"`
+	RecipeVersion int32    `protobuf:"varint,6,opt,name=RecipeVersion,proto3" json:"RecipeVersion,omitempty"`
+	Step          []byte   `protobuf:"bytes,7,opt,name=Step,proto3" json:"Step,omitempty"`
+}
+
+func (m *Lineage) Reset()         { *m = Lineage{} }
+func (m *Lineage) String() string { return proto.CompactTextString(m) }
+func (*Lineage) ProtoMessage()    {}
+
+type ProofPopcode struct {
+	Address string  `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	Counter []byte  `protobuf:"bytes,2,opt,name=Counter,proto3" json:"Counter,omitempty"`
+	Indices []int32 `protobuf:"varint,3,rep,packed,name=Indices" json:"Indices,omitempty"`
+	Outputs []*OTX  `protobuf:"bytes,4,rep,name=Outputs" json:"Outputs,omitempty"`
+}
+
+func (m *ProofPopcode) Reset()         { *m = ProofPopcode{} }
+func (m *ProofPopcode) String() string { return proto.CompactTextString(m) }
+func (*ProofPopcode) ProtoMessage()    {}
+
+func (m *ProofPopcode) GetOutputs() []*OTX {
+	if m != nil {
+		return m.Outputs
+	}
+	return nil
+}
+
+type ProofStep struct {
+	Function string          `protobuf:"bytes,1,opt,name=Function,proto3" json:"Function,omitempty"`
+	Tx       []byte          `protobuf:"bytes,2,opt,name=Tx,proto3" json:"Tx,omitempty"`
+	Time     int64           `protobuf:"varint,3,opt,name=Time,proto3" json:"Time,omitempty"`
+	Popcodes []*ProofPopcode `protobuf:"bytes,4,rep,name=Popcodes" json:"Popcodes,omitempty"`
+	Recipe   *Recipe         `protobuf:"bytes,5,opt,name=Recipe" json:"Recipe,omitempty"`
+	Event    []byte          `protobuf:"bytes,6,opt,name=Event,proto3" json:"Event,omitempty"`
+}
+
+func (m *ProofStep) Reset()         { *m = ProofStep{} }
+func (m *ProofStep) String() string { return proto.CompactTextString(m) }
+func (*ProofStep) ProtoMessage()    {}
+
+func (m *ProofStep) GetPopcodes() []*ProofPopcode {
+	if m != nil {
+		return m.Popcodes
+	}
+	return nil
+}
+
+func (m *ProofStep) GetRecipe() *Recipe {
+	if m != nil {
+		return m.Recipe
+	}
+	return nil
+}
@@ -16,6 +16,23 @@ It has these top-level messages:
 	CombineSources
 	Ingredient
 	Recipe
+	Burn
+	UnitizeDestination
+	MultiUnitize
+	BatchStep
+	Batch
+	SwapSide
+	Swap
+	HashLock
+	Claim
+	Refund
+	TimeLock
+	RecipeStatus
+	Product
+	MultiCombineSource
+	CombineSigner
+	MultiCombine
+	RegisterType
 */
 package TuxedoPopsTX
 
@@ -35,6 +52,7 @@ type CreateTX struct {
 	Type          string `protobuf:"bytes,4,opt,name=Type" json:"Type,omitempty"`
 	CreatorPubKey []byte `protobuf:"bytes,5,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
 	CreatorSig    []byte `protobuf:"bytes,6,opt,name=CreatorSig,proto3" json:"CreatorSig,omitempty"`
+	Version       int32  `protobuf:"varint,7,opt,name=Version" json:"Version,omitempty"`
 }
 
 func (m *CreateTX) Reset()         { *m = CreateTX{} }
@@ -50,6 +68,7 @@ type TransferOwners struct {
 	PopcodePubKey []byte   `protobuf:"bytes,6,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
 	PopcodeSig    []byte   `protobuf:"bytes,7,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
 	Data          string   `protobuf:"bytes,8,opt,name=Data" json:"Data,omitempty"`
+	Version       int32    `protobuf:"varint,9,opt,name=Version" json:"Version,omitempty"`
 }
 
 func (m *TransferOwners) Reset()         { *m = TransferOwners{} }
@@ -65,6 +84,7 @@ type Unitize struct {
 	PopcodePubKey []byte   `protobuf:"bytes,6,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
 	PopcodeSig    []byte   `protobuf:"bytes,7,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
 	Data          string   `protobuf:"bytes,8,opt,name=Data" json:"Data,omitempty"`
+	Version       int32    `protobuf:"varint,9,opt,name=Version" json:"Version,omitempty"`
 }
 
 func (m *Unitize) Reset()         { *m = Unitize{} }
@@ -82,6 +102,10 @@ type Combine struct {
 	PopcodePubKey []byte            `protobuf:"bytes,8,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
 	PopcodeSig    []byte            `protobuf:"bytes,9,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
 	Data          string            `protobuf:"bytes,10,opt,name=Data" json:"Data,omitempty"`
+	Version       int32             `protobuf:"varint,11,opt,name=Version" json:"Version,omitempty"`
+	RecipeVersion int32             `protobuf:"varint,12,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	ApprovalSigs  [][]byte          `protobuf:"bytes,13,rep,name=ApprovalSigs,proto3" json:"ApprovalSigs,omitempty"`
+	ReturnChange  bool              `protobuf:"varint,14,opt,name=ReturnChange,proto3" json:"ReturnChange,omitempty"`
 }
 
 func (m *Combine) Reset()         { *m = Combine{} }
@@ -122,6 +146,11 @@ type Recipe struct {
 	CreatorPubKey []byte        `protobuf:"bytes,3,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
 	CreatorSig    []byte        `protobuf:"bytes,4,opt,name=CreatorSig,proto3" json:"CreatorSig,omitempty"`
 	Ingredients   []*Ingredient `protobuf:"bytes,5,rep,name=Ingredients" json:"Ingredients,omitempty"`
+	Version       int32         `protobuf:"varint,6,opt,name=Version" json:"Version,omitempty"`
+	RecipeVersion int32         `protobuf:"varint,7,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	Byproducts    []*Product    `protobuf:"bytes,8,rep,name=Byproducts" json:"Byproducts,omitempty"`
+	Manufacturers [][]byte      `protobuf:"bytes,9,rep,name=Manufacturers,proto3" json:"Manufacturers,omitempty"`
+	Threshold     int32         `protobuf:"varint,10,opt,name=Threshold" json:"Threshold,omitempty"`
 }
 
 func (m *Recipe) Reset()         { *m = Recipe{} }
@@ -134,3 +163,273 @@ func (m *Recipe) GetIngredients() []*Ingredient {
 	}
 	return nil
 }
+
+func (m *Recipe) GetByproducts() []*Product {
+	if m != nil {
+		return m.Byproducts
+	}
+	return nil
+}
+
+type Burn struct {
+	Address       string   `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output        int32    `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32    `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+	Redemption    string   `protobuf:"bytes,4,opt,name=Redemption" json:"Redemption,omitempty"`
+	OwnerSigs     [][]byte `protobuf:"bytes,5,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte   `protobuf:"bytes,6,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte   `protobuf:"bytes,7,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+	Version       int32    `protobuf:"varint,8,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *Burn) Reset()         { *m = Burn{} }
+func (m *Burn) String() string { return proto.CompactTextString(m) }
+func (*Burn) ProtoMessage()    {}
+
+type UnitizeDestination struct {
+	DestAddress string  `protobuf:"bytes,1,opt,name=DestAddress" json:"DestAddress,omitempty"`
+	DestAmounts []int32 `protobuf:"varint,2,rep,name=DestAmounts" json:"DestAmounts,omitempty"`
+}
+
+func (m *UnitizeDestination) Reset()         { *m = UnitizeDestination{} }
+func (m *UnitizeDestination) String() string { return proto.CompactTextString(m) }
+func (*UnitizeDestination) ProtoMessage()    {}
+
+type MultiUnitize struct {
+	SourceOutput  int32                 `protobuf:"varint,1,opt,name=SourceOutput" json:"SourceOutput,omitempty"`
+	SourceAddress string                `protobuf:"bytes,2,opt,name=SourceAddress" json:"SourceAddress,omitempty"`
+	Destinations  []*UnitizeDestination `protobuf:"bytes,3,rep,name=Destinations" json:"Destinations,omitempty"`
+	KeepChange    bool                  `protobuf:"varint,4,opt,name=KeepChange" json:"KeepChange,omitempty"`
+	OwnerSigs     [][]byte              `protobuf:"bytes,5,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte                `protobuf:"bytes,6,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte                `protobuf:"bytes,7,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+	Data          string                `protobuf:"bytes,8,opt,name=Data" json:"Data,omitempty"`
+	Version       int32                 `protobuf:"varint,9,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *MultiUnitize) Reset()         { *m = MultiUnitize{} }
+func (m *MultiUnitize) String() string { return proto.CompactTextString(m) }
+func (*MultiUnitize) ProtoMessage()    {}
+
+func (m *MultiUnitize) GetDestinations() []*UnitizeDestination {
+	if m != nil {
+		return m.Destinations
+	}
+	return nil
+}
+
+type BatchStep struct {
+	Function string `protobuf:"bytes,1,opt,name=Function" json:"Function,omitempty"`
+	Args     []byte `protobuf:"bytes,2,opt,name=Args,proto3" json:"Args,omitempty"`
+}
+
+func (m *BatchStep) Reset()         { *m = BatchStep{} }
+func (m *BatchStep) String() string { return proto.CompactTextString(m) }
+func (*BatchStep) ProtoMessage()    {}
+
+type Batch struct {
+	Steps []*BatchStep `protobuf:"bytes,1,rep,name=Steps" json:"Steps,omitempty"`
+}
+
+func (m *Batch) Reset()         { *m = Batch{} }
+func (m *Batch) String() string { return proto.CompactTextString(m) }
+func (*Batch) ProtoMessage()    {}
+
+func (m *Batch) GetSteps() []*BatchStep {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+type SwapSide struct {
+	Address       string   `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output        int32    `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32    `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+	OwnerSigs     [][]byte `protobuf:"bytes,4,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte   `protobuf:"bytes,5,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte   `protobuf:"bytes,6,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+}
+
+func (m *SwapSide) Reset()         { *m = SwapSide{} }
+func (m *SwapSide) String() string { return proto.CompactTextString(m) }
+func (*SwapSide) ProtoMessage()    {}
+
+type Swap struct {
+	A       *SwapSide `protobuf:"bytes,1,opt,name=A" json:"A,omitempty"`
+	B       *SwapSide `protobuf:"bytes,2,opt,name=B" json:"B,omitempty"`
+	Data    string    `protobuf:"bytes,3,opt,name=Data" json:"Data,omitempty"`
+	Version int32     `protobuf:"varint,4,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *Swap) Reset()         { *m = Swap{} }
+func (m *Swap) String() string { return proto.CompactTextString(m) }
+func (*Swap) ProtoMessage()    {}
+
+func (m *Swap) GetA() *SwapSide {
+	if m != nil {
+		return m.A
+	}
+	return nil
+}
+
+func (m *Swap) GetB() *SwapSide {
+	if m != nil {
+		return m.B
+	}
+	return nil
+}
+
+type HashLock struct {
+	Address       string   `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output        int32    `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32    `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+	Hash          []byte   `protobuf:"bytes,4,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	Expiry        int64    `protobuf:"varint,5,opt,name=Expiry" json:"Expiry,omitempty"`
+	Recipient     []byte   `protobuf:"bytes,6,opt,name=Recipient,proto3" json:"Recipient,omitempty"`
+	OwnerSigs     [][]byte `protobuf:"bytes,7,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte   `protobuf:"bytes,8,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte   `protobuf:"bytes,9,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+	Version       int32    `protobuf:"varint,10,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *HashLock) Reset()         { *m = HashLock{} }
+func (m *HashLock) String() string { return proto.CompactTextString(m) }
+func (*HashLock) ProtoMessage()    {}
+
+type Claim struct {
+	Address      string `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output       int32  `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	Preimage     []byte `protobuf:"bytes,3,opt,name=Preimage,proto3" json:"Preimage,omitempty"`
+	RecipientSig []byte `protobuf:"bytes,4,opt,name=RecipientSig,proto3" json:"RecipientSig,omitempty"`
+	Version      int32  `protobuf:"varint,5,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *Claim) Reset()         { *m = Claim{} }
+func (m *Claim) String() string { return proto.CompactTextString(m) }
+func (*Claim) ProtoMessage()    {}
+
+type Refund struct {
+	Address       string   `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output        int32    `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	OwnerSigs     [][]byte `protobuf:"bytes,3,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte   `protobuf:"bytes,4,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte   `protobuf:"bytes,5,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+	Version       int32    `protobuf:"varint,6,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *Refund) Reset()         { *m = Refund{} }
+func (m *Refund) String() string { return proto.CompactTextString(m) }
+func (*Refund) ProtoMessage()    {}
+
+type TimeLock struct {
+	Address       string   `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output        int32    `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32    `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+	Start         int64    `protobuf:"varint,4,opt,name=Start" json:"Start,omitempty"`
+	End           int64    `protobuf:"varint,5,opt,name=End" json:"End,omitempty"`
+	Period        int64    `protobuf:"varint,6,opt,name=Period" json:"Period,omitempty"`
+	OwnerSigs     [][]byte `protobuf:"bytes,7,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte   `protobuf:"bytes,8,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte   `protobuf:"bytes,9,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+	Version       int32    `protobuf:"varint,10,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *TimeLock) Reset()         { *m = TimeLock{} }
+func (m *TimeLock) String() string { return proto.CompactTextString(m) }
+func (*TimeLock) ProtoMessage()    {}
+
+type RecipeStatus struct {
+	RecipeName    string `protobuf:"bytes,1,opt,name=RecipeName" json:"RecipeName,omitempty"`
+	RecipeVersion int32  `protobuf:"varint,2,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	Status        int32  `protobuf:"varint,3,opt,name=Status" json:"Status,omitempty"`
+	CreatorPubKey []byte `protobuf:"bytes,4,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
+	CreatorSig    []byte `protobuf:"bytes,5,opt,name=CreatorSig,proto3" json:"CreatorSig,omitempty"`
+	Version       int32  `protobuf:"varint,6,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *RecipeStatus) Reset()         { *m = RecipeStatus{} }
+func (m *RecipeStatus) String() string { return proto.CompactTextString(m) }
+func (*RecipeStatus) ProtoMessage()    {}
+
+type Product struct {
+	Numerator   int32  `protobuf:"varint,1,opt,name=Numerator" json:"Numerator,omitempty"`
+	Denominator int32  `protobuf:"varint,2,opt,name=Denominator" json:"Denominator,omitempty"`
+	Type        string `protobuf:"bytes,3,opt,name=Type" json:"Type,omitempty"`
+}
+
+func (m *Product) Reset()         { *m = Product{} }
+func (m *Product) String() string { return proto.CompactTextString(m) }
+func (*Product) ProtoMessage()    {}
+
+type MultiCombineSource struct {
+	Address string `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	Output  int32  `protobuf:"varint,2,opt,name=Output" json:"Output,omitempty"`
+	Amount  int32  `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+}
+
+func (m *MultiCombineSource) Reset()         { *m = MultiCombineSource{} }
+func (m *MultiCombineSource) String() string { return proto.CompactTextString(m) }
+func (*MultiCombineSource) ProtoMessage()    {}
+
+type CombineSigner struct {
+	Address       string   `protobuf:"bytes,1,opt,name=Address" json:"Address,omitempty"`
+	OwnerSigs     [][]byte `protobuf:"bytes,2,rep,name=OwnerSigs,proto3" json:"OwnerSigs,omitempty"`
+	PopcodePubKey []byte   `protobuf:"bytes,3,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	PopcodeSig    []byte   `protobuf:"bytes,4,opt,name=PopcodeSig,proto3" json:"PopcodeSig,omitempty"`
+}
+
+func (m *CombineSigner) Reset()         { *m = CombineSigner{} }
+func (m *CombineSigner) String() string { return proto.CompactTextString(m) }
+func (*CombineSigner) ProtoMessage()    {}
+
+type MultiCombine struct {
+	Sources       []*MultiCombineSource `protobuf:"bytes,1,rep,name=Sources" json:"Sources,omitempty"`
+	Signers       []*CombineSigner      `protobuf:"bytes,2,rep,name=Signers" json:"Signers,omitempty"`
+	Destination   string                `protobuf:"bytes,3,opt,name=Destination" json:"Destination,omitempty"`
+	Amount        int32                 `protobuf:"varint,4,opt,name=Amount" json:"Amount,omitempty"`
+	Recipe        string                `protobuf:"bytes,5,opt,name=Recipe" json:"Recipe,omitempty"`
+	RecipeVersion int32                 `protobuf:"varint,6,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	CreatorPubKey []byte                `protobuf:"bytes,7,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
+	CreatorSig    []byte                `protobuf:"bytes,8,opt,name=CreatorSig,proto3" json:"CreatorSig,omitempty"`
+	ApprovalSigs  [][]byte              `protobuf:"bytes,9,rep,name=ApprovalSigs,proto3" json:"ApprovalSigs,omitempty"`
+	Data          string                `protobuf:"bytes,10,opt,name=Data" json:"Data,omitempty"`
+	Version       int32                 `protobuf:"varint,11,opt,name=Version" json:"Version,omitempty"`
+	ReturnChange  bool                  `protobuf:"varint,12,opt,name=ReturnChange,proto3" json:"ReturnChange,omitempty"`
+}
+
+func (m *MultiCombine) Reset()         { *m = MultiCombine{} }
+func (m *MultiCombine) String() string { return proto.CompactTextString(m) }
+func (*MultiCombine) ProtoMessage()    {}
+
+func (m *MultiCombine) GetSources() []*MultiCombineSource {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
+func (m *MultiCombine) GetSigners() []*CombineSigner {
+	if m != nil {
+		return m.Signers
+	}
+	return nil
+}
+
+type RegisterType struct {
+	Name         string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Issuers      [][]byte `protobuf:"bytes,2,rep,name=Issuers,proto3" json:"Issuers,omitempty"`
+	DisplayName  string   `protobuf:"bytes,3,opt,name=DisplayName,proto3" json:"DisplayName,omitempty"`
+	Unit         string   `protobuf:"bytes,4,opt,name=Unit,proto3" json:"Unit,omitempty"`
+	Decimals     int32    `protobuf:"varint,5,opt,name=Decimals,proto3" json:"Decimals,omitempty"`
+	MetadataHash []byte   `protobuf:"bytes,6,opt,name=MetadataHash,proto3" json:"MetadataHash,omitempty"`
+	Revision     int32    `protobuf:"varint,7,opt,name=Revision,proto3" json:"Revision,omitempty"`
+	IssuerPubKey []byte   `protobuf:"bytes,8,opt,name=IssuerPubKey,proto3" json:"IssuerPubKey,omitempty"`
+	IssuerSig    []byte   `protobuf:"bytes,9,opt,name=IssuerSig,proto3" json:"IssuerSig,omitempty"`
+	Version      int32    `protobuf:"varint,10,opt,name=Version,proto3" json:"Version,omitempty"`
+	Cap          int64    `protobuf:"varint,11,opt,name=Cap,proto3" json:"Cap,omitempty"`
+}
+
+func (m *RegisterType) Reset()         { *m = RegisterType{} }
+func (m *RegisterType) String() string { return proto.CompactTextString(m) }
+func (*RegisterType) ProtoMessage()    {}
@@ -14,6 +14,26 @@ It has these top-level messages:
 	UnitizeEvent
 	CombineEvent
 	CombineSources
+	BurnEvent
+	UnitizeDestination
+	MultiUnitizeEvent
+	BatchStepEvent
+	BatchEvent
+	SwapSide
+	SwapEvent
+	HashLockEvent
+	ClaimEvent
+	RefundEvent
+	TimeLockEvent
+	CombineProduct
+	MultiCombineSource
+	MultiCombineEvent
+	ReturnedChange
+	RecipeComponent
+	RecipeEvent
+	RecipeStatusEvent
+	RegisterTypeEvent
+	Envelope
 */
 package TxEvents
 
@@ -83,6 +103,11 @@ type CombineEvent struct {
 	CreatorPubKey  []byte            `protobuf:"bytes,7,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
 	PopcodePubKey  []byte            `protobuf:"bytes,8,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
 	Data           string            `protobuf:"bytes,9,opt,name=Data" json:"Data,omitempty"`
+	RecipeVersion  int32             `protobuf:"varint,10,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	Products       []*CombineProduct `protobuf:"bytes,11,rep,name=Products" json:"Products,omitempty"`
+	Waste          int64             `protobuf:"varint,12,opt,name=Waste" json:"Waste,omitempty"`
+	Change         int64             `protobuf:"varint,13,opt,name=Change,proto3" json:"Change,omitempty"`
+	Returned       []*ReturnedChange `protobuf:"bytes,14,rep,name=Returned" json:"Returned,omitempty"`
 }
 
 func (m *CombineEvent) Reset()         { *m = CombineEvent{} }
@@ -96,6 +121,20 @@ func (m *CombineEvent) GetSources() []*CombineSources {
 	return nil
 }
 
+func (m *CombineEvent) GetProducts() []*CombineProduct {
+	if m != nil {
+		return m.Products
+	}
+	return nil
+}
+
+func (m *CombineEvent) GetReturned() []*ReturnedChange {
+	if m != nil {
+		return m.Returned
+	}
+	return nil
+}
+
 type CombineSources struct {
 	SourceOutput int32 `protobuf:"varint,1,opt,name=SourceOutput" json:"SourceOutput,omitempty"`
 	SourceAmount int32 `protobuf:"varint,2,opt,name=SourceAmount" json:"SourceAmount,omitempty"`
@@ -104,3 +143,328 @@ type CombineSources struct {
 func (m *CombineSources) Reset()         { *m = CombineSources{} }
 func (m *CombineSources) String() string { return proto.CompactTextString(m) }
 func (*CombineSources) ProtoMessage()    {}
+
+type BurnEvent struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	DestCounter   []byte `protobuf:"bytes,2,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+	Address       string `protobuf:"bytes,3,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,4,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32  `protobuf:"varint,5,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,6,opt,name=Type" json:"Type,omitempty"`
+	Redemption    string `protobuf:"bytes,7,opt,name=Redemption" json:"Redemption,omitempty"`
+	PopcodePubKey []byte `protobuf:"bytes,8,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+}
+
+func (m *BurnEvent) Reset()         { *m = BurnEvent{} }
+func (m *BurnEvent) String() string { return proto.CompactTextString(m) }
+func (*BurnEvent) ProtoMessage()    {}
+
+type UnitizeDestination struct {
+	DestAddress  string   `protobuf:"bytes,1,opt,name=DestAddress" json:"DestAddress,omitempty"`
+	DestCounters [][]byte `protobuf:"bytes,2,rep,name=DestCounters,proto3" json:"DestCounters,omitempty"`
+	DestAmounts  []int32  `protobuf:"varint,3,rep,name=DestAmounts" json:"DestAmounts,omitempty"`
+}
+
+func (m *UnitizeDestination) Reset()         { *m = UnitizeDestination{} }
+func (m *UnitizeDestination) String() string { return proto.CompactTextString(m) }
+func (*UnitizeDestination) ProtoMessage()    {}
+
+type MultiUnitizeEvent struct {
+	SourceCounter []byte                `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	SourceOutput  int32                 `protobuf:"varint,2,opt,name=SourceOutput" json:"SourceOutput,omitempty"`
+	SourceAddress string                `protobuf:"bytes,3,opt,name=SourceAddress" json:"SourceAddress,omitempty"`
+	Destinations  []*UnitizeDestination `protobuf:"bytes,4,rep,name=Destinations" json:"Destinations,omitempty"`
+	ChangeCounter []byte                `protobuf:"bytes,5,opt,name=ChangeCounter,proto3" json:"ChangeCounter,omitempty"`
+	ChangeAmount  int32                 `protobuf:"varint,6,opt,name=ChangeAmount" json:"ChangeAmount,omitempty"`
+	PopcodePubKey []byte                `protobuf:"bytes,7,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+	Data          string                `protobuf:"bytes,8,opt,name=Data" json:"Data,omitempty"`
+	Type          string                `protobuf:"bytes,9,opt,name=Type" json:"Type,omitempty"`
+}
+
+func (m *MultiUnitizeEvent) Reset()         { *m = MultiUnitizeEvent{} }
+func (m *MultiUnitizeEvent) String() string { return proto.CompactTextString(m) }
+func (*MultiUnitizeEvent) ProtoMessage()    {}
+
+func (m *MultiUnitizeEvent) GetDestinations() []*UnitizeDestination {
+	if m != nil {
+		return m.Destinations
+	}
+	return nil
+}
+
+type BatchStepEvent struct {
+	Name    string `protobuf:"bytes,1,opt,name=Name" json:"Name,omitempty"`
+	Payload []byte `protobuf:"bytes,2,opt,name=Payload,proto3" json:"Payload,omitempty"`
+}
+
+func (m *BatchStepEvent) Reset()         { *m = BatchStepEvent{} }
+func (m *BatchStepEvent) String() string { return proto.CompactTextString(m) }
+func (*BatchStepEvent) ProtoMessage()    {}
+
+type BatchEvent struct {
+	Steps []*BatchStepEvent `protobuf:"bytes,1,rep,name=Steps" json:"Steps,omitempty"`
+}
+
+func (m *BatchEvent) Reset()         { *m = BatchEvent{} }
+func (m *BatchEvent) String() string { return proto.CompactTextString(m) }
+func (*BatchEvent) ProtoMessage()    {}
+
+func (m *BatchEvent) GetSteps() []*BatchStepEvent {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+type SwapSide struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	DestCounter   []byte `protobuf:"bytes,2,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+	Address       string `protobuf:"bytes,3,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,4,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32  `protobuf:"varint,5,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,6,opt,name=Type" json:"Type,omitempty"`
+	PopcodePubKey []byte `protobuf:"bytes,7,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+}
+
+func (m *SwapSide) Reset()         { *m = SwapSide{} }
+func (m *SwapSide) String() string { return proto.CompactTextString(m) }
+func (*SwapSide) ProtoMessage()    {}
+
+type SwapEvent struct {
+	A    *SwapSide `protobuf:"bytes,1,opt,name=A" json:"A,omitempty"`
+	B    *SwapSide `protobuf:"bytes,2,opt,name=B" json:"B,omitempty"`
+	Data string    `protobuf:"bytes,3,opt,name=Data" json:"Data,omitempty"`
+}
+
+func (m *SwapEvent) Reset()         { *m = SwapEvent{} }
+func (m *SwapEvent) String() string { return proto.CompactTextString(m) }
+func (*SwapEvent) ProtoMessage()    {}
+
+func (m *SwapEvent) GetA() *SwapSide {
+	if m != nil {
+		return m.A
+	}
+	return nil
+}
+
+func (m *SwapEvent) GetB() *SwapSide {
+	if m != nil {
+		return m.B
+	}
+	return nil
+}
+
+type HashLockEvent struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	DestCounter   []byte `protobuf:"bytes,2,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+	Address       string `protobuf:"bytes,3,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,4,opt,name=Output" json:"Output,omitempty"`
+	LockedOutput  int32  `protobuf:"varint,5,opt,name=LockedOutput" json:"LockedOutput,omitempty"`
+	Amount        int32  `protobuf:"varint,6,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,7,opt,name=Type" json:"Type,omitempty"`
+	Hash          []byte `protobuf:"bytes,8,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	Expiry        int64  `protobuf:"varint,9,opt,name=Expiry" json:"Expiry,omitempty"`
+	Recipient     []byte `protobuf:"bytes,10,opt,name=Recipient,proto3" json:"Recipient,omitempty"`
+	PopcodePubKey []byte `protobuf:"bytes,11,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+}
+
+func (m *HashLockEvent) Reset()         { *m = HashLockEvent{} }
+func (m *HashLockEvent) String() string { return proto.CompactTextString(m) }
+func (*HashLockEvent) ProtoMessage()    {}
+
+type ClaimEvent struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	DestCounter   []byte `protobuf:"bytes,2,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+	Address       string `protobuf:"bytes,3,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,4,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32  `protobuf:"varint,5,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,6,opt,name=Type" json:"Type,omitempty"`
+	Preimage      []byte `protobuf:"bytes,7,opt,name=Preimage,proto3" json:"Preimage,omitempty"`
+	Recipient     []byte `protobuf:"bytes,8,opt,name=Recipient,proto3" json:"Recipient,omitempty"`
+}
+
+func (m *ClaimEvent) Reset()         { *m = ClaimEvent{} }
+func (m *ClaimEvent) String() string { return proto.CompactTextString(m) }
+func (*ClaimEvent) ProtoMessage()    {}
+
+type RefundEvent struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	DestCounter   []byte `protobuf:"bytes,2,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+	Address       string `protobuf:"bytes,3,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,4,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32  `protobuf:"varint,5,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,6,opt,name=Type" json:"Type,omitempty"`
+	PopcodePubKey []byte `protobuf:"bytes,7,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+}
+
+func (m *RefundEvent) Reset()         { *m = RefundEvent{} }
+func (m *RefundEvent) String() string { return proto.CompactTextString(m) }
+func (*RefundEvent) ProtoMessage()    {}
+
+type TimeLockEvent struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	DestCounter   []byte `protobuf:"bytes,2,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+	Address       string `protobuf:"bytes,3,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,4,opt,name=Output" json:"Output,omitempty"`
+	LockedOutput  int32  `protobuf:"varint,5,opt,name=LockedOutput" json:"LockedOutput,omitempty"`
+	Amount        int32  `protobuf:"varint,6,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,7,opt,name=Type" json:"Type,omitempty"`
+	Start         int64  `protobuf:"varint,8,opt,name=Start" json:"Start,omitempty"`
+	End           int64  `protobuf:"varint,9,opt,name=End" json:"End,omitempty"`
+	Period        int64  `protobuf:"varint,10,opt,name=Period" json:"Period,omitempty"`
+	PopcodePubKey []byte `protobuf:"bytes,11,opt,name=PopcodePubKey,proto3" json:"PopcodePubKey,omitempty"`
+}
+
+func (m *TimeLockEvent) Reset()         { *m = TimeLockEvent{} }
+func (m *TimeLockEvent) String() string { return proto.CompactTextString(m) }
+func (*TimeLockEvent) ProtoMessage()    {}
+
+type CombineProduct struct {
+	Type        string `protobuf:"bytes,1,opt,name=Type" json:"Type,omitempty"`
+	Amount      int32  `protobuf:"varint,2,opt,name=Amount" json:"Amount,omitempty"`
+	DestCounter []byte `protobuf:"bytes,3,opt,name=DestCounter,proto3" json:"DestCounter,omitempty"`
+}
+
+func (m *CombineProduct) Reset()         { *m = CombineProduct{} }
+func (m *CombineProduct) String() string { return proto.CompactTextString(m) }
+func (*CombineProduct) ProtoMessage()    {}
+
+type MultiCombineSource struct {
+	SourceCounter []byte `protobuf:"bytes,1,opt,name=SourceCounter,proto3" json:"SourceCounter,omitempty"`
+	Address       string `protobuf:"bytes,2,opt,name=Address" json:"Address,omitempty"`
+	Output        int32  `protobuf:"varint,3,opt,name=Output" json:"Output,omitempty"`
+	Amount        int32  `protobuf:"varint,4,opt,name=Amount" json:"Amount,omitempty"`
+	Type          string `protobuf:"bytes,5,opt,name=Type" json:"Type,omitempty"`
+}
+
+func (m *MultiCombineSource) Reset()         { *m = MultiCombineSource{} }
+func (m *MultiCombineSource) String() string { return proto.CompactTextString(m) }
+func (*MultiCombineSource) ProtoMessage()    {}
+
+type MultiCombineEvent struct {
+	Sources       []*MultiCombineSource `protobuf:"bytes,1,rep,name=Sources" json:"Sources,omitempty"`
+	Destination   string                `protobuf:"bytes,2,opt,name=Destination" json:"Destination,omitempty"`
+	Amount        int32                 `protobuf:"varint,3,opt,name=Amount" json:"Amount,omitempty"`
+	Recipe        string                `protobuf:"bytes,4,opt,name=Recipe" json:"Recipe,omitempty"`
+	RecipeVersion int32                 `protobuf:"varint,5,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	CreatorPubKey []byte                `protobuf:"bytes,6,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
+	Data          string                `protobuf:"bytes,7,opt,name=Data" json:"Data,omitempty"`
+	Products      []*CombineProduct     `protobuf:"bytes,8,rep,name=Products" json:"Products,omitempty"`
+	Waste         int64                 `protobuf:"varint,9,opt,name=Waste" json:"Waste,omitempty"`
+	Change        int64                 `protobuf:"varint,10,opt,name=Change,proto3" json:"Change,omitempty"`
+	Returned      []*ReturnedChange     `protobuf:"bytes,11,rep,name=Returned" json:"Returned,omitempty"`
+}
+
+func (m *MultiCombineEvent) Reset()         { *m = MultiCombineEvent{} }
+func (m *MultiCombineEvent) String() string { return proto.CompactTextString(m) }
+func (*MultiCombineEvent) ProtoMessage()    {}
+
+func (m *MultiCombineEvent) GetSources() []*MultiCombineSource {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
+func (m *MultiCombineEvent) GetProducts() []*CombineProduct {
+	if m != nil {
+		return m.Products
+	}
+	return nil
+}
+
+func (m *MultiCombineEvent) GetReturned() []*ReturnedChange {
+	if m != nil {
+		return m.Returned
+	}
+	return nil
+}
+
+type ReturnedChange struct {
+	Type   string `protobuf:"bytes,1,opt,name=Type" json:"Type,omitempty"`
+	Amount int32  `protobuf:"varint,2,opt,name=Amount" json:"Amount,omitempty"`
+}
+
+func (m *ReturnedChange) Reset()         { *m = ReturnedChange{} }
+func (m *ReturnedChange) String() string { return proto.CompactTextString(m) }
+func (*ReturnedChange) ProtoMessage()    {}
+
+type RecipeComponent struct {
+	Type        string `protobuf:"bytes,1,opt,name=Type" json:"Type,omitempty"`
+	Numerator   int32  `protobuf:"varint,2,opt,name=Numerator" json:"Numerator,omitempty"`
+	Denominator int32  `protobuf:"varint,3,opt,name=Denominator" json:"Denominator,omitempty"`
+}
+
+func (m *RecipeComponent) Reset()         { *m = RecipeComponent{} }
+func (m *RecipeComponent) String() string { return proto.CompactTextString(m) }
+func (*RecipeComponent) ProtoMessage()    {}
+
+type RecipeEvent struct {
+	RecipeName    string             `protobuf:"bytes,1,opt,name=RecipeName" json:"RecipeName,omitempty"`
+	RecipeVersion int32              `protobuf:"varint,2,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	CreatedType   string             `protobuf:"bytes,3,opt,name=CreatedType" json:"CreatedType,omitempty"`
+	Ingredients   []*RecipeComponent `protobuf:"bytes,4,rep,name=Ingredients" json:"Ingredients,omitempty"`
+	Byproducts    []*RecipeComponent `protobuf:"bytes,5,rep,name=Byproducts" json:"Byproducts,omitempty"`
+	Manufacturers [][]byte           `protobuf:"bytes,6,rep,name=Manufacturers,proto3" json:"Manufacturers,omitempty"`
+	Threshold     int32              `protobuf:"varint,7,opt,name=Threshold" json:"Threshold,omitempty"`
+	CreatorPubKey []byte             `protobuf:"bytes,8,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
+}
+
+func (m *RecipeEvent) Reset()         { *m = RecipeEvent{} }
+func (m *RecipeEvent) String() string { return proto.CompactTextString(m) }
+func (*RecipeEvent) ProtoMessage()    {}
+
+func (m *RecipeEvent) GetIngredients() []*RecipeComponent {
+	if m != nil {
+		return m.Ingredients
+	}
+	return nil
+}
+
+func (m *RecipeEvent) GetByproducts() []*RecipeComponent {
+	if m != nil {
+		return m.Byproducts
+	}
+	return nil
+}
+
+type RecipeStatusEvent struct {
+	RecipeName    string `protobuf:"bytes,1,opt,name=RecipeName" json:"RecipeName,omitempty"`
+	RecipeVersion int32  `protobuf:"varint,2,opt,name=RecipeVersion" json:"RecipeVersion,omitempty"`
+	Status        int32  `protobuf:"varint,3,opt,name=Status" json:"Status,omitempty"`
+	CreatorPubKey []byte `protobuf:"bytes,4,opt,name=CreatorPubKey,proto3" json:"CreatorPubKey,omitempty"`
+}
+
+func (m *RecipeStatusEvent) Reset()         { *m = RecipeStatusEvent{} }
+func (m *RecipeStatusEvent) String() string { return proto.CompactTextString(m) }
+func (*RecipeStatusEvent) ProtoMessage()    {}
+
+type RegisterTypeEvent struct {
+	Name         string   `protobuf:"bytes,1,opt,name=Name" json:"Name,omitempty"`
+	Revision     int32    `protobuf:"varint,2,opt,name=Revision" json:"Revision,omitempty"`
+	Issuers      [][]byte `protobuf:"bytes,3,rep,name=Issuers,proto3" json:"Issuers,omitempty"`
+	DisplayName  string   `protobuf:"bytes,4,opt,name=DisplayName" json:"DisplayName,omitempty"`
+	Unit         string   `protobuf:"bytes,5,opt,name=Unit" json:"Unit,omitempty"`
+	Decimals     int32    `protobuf:"varint,6,opt,name=Decimals" json:"Decimals,omitempty"`
+	MetadataHash []byte   `protobuf:"bytes,7,opt,name=MetadataHash,proto3" json:"MetadataHash,omitempty"`
+	Cap          int64    `protobuf:"varint,8,opt,name=Cap" json:"Cap,omitempty"`
+	IssuerPubKey []byte   `protobuf:"bytes,9,opt,name=IssuerPubKey,proto3" json:"IssuerPubKey,omitempty"`
+}
+
+func (m *RegisterTypeEvent) Reset()         { *m = RegisterTypeEvent{} }
+func (m *RegisterTypeEvent) String() string { return proto.CompactTextString(m) }
+func (*RegisterTypeEvent) ProtoMessage()    {}
+
+type Envelope struct {
+	Version   int32  `protobuf:"varint,1,opt,name=Version" json:"Version,omitempty"`
+	TxID      string `protobuf:"bytes,2,opt,name=TxID" json:"TxID,omitempty"`
+	Timestamp int64  `protobuf:"varint,3,opt,name=Timestamp" json:"Timestamp,omitempty"`
+	Function  string `protobuf:"bytes,4,opt,name=Function" json:"Function,omitempty"`
+	Sequence  uint64 `protobuf:"varint,5,opt,name=Sequence" json:"Sequence,omitempty"`
+	Type      string `protobuf:"bytes,6,opt,name=Type" json:"Type,omitempty"`
+	Payload   []byte `protobuf:"bytes,7,opt,name=Payload,proto3" json:"Payload,omitempty"`
+}
+
+func (m *Envelope) Reset()         { *m = Envelope{} }
+func (m *Envelope) String() string { return proto.CompactTextString(m) }
+func (*Envelope) ProtoMessage()    {}
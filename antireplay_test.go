@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+// checkReplay invokes function with argHex twice. The first must succeed, the
+// second must be refused and leave the popcodes at addresses as they were.
+func checkReplay(t *testing.T, stub *shim.MockStub, function string, argHex string, addresses ...string) {
+	if _, err := stub.MockInvoke("1", function, []string{argHex}); err != nil {
+		HandleError(t, fmt.Errorf("%s failed: %s", function, err.Error()))
+		t.FailNow()
+	}
+	before := [][]byte{}
+	for _, address := range addresses {
+		balance, _ := stub.MockQuery("balance", []string{address})
+		before = append(before, balance)
+	}
+	if _, err := stub.MockInvoke("1", function, []string{argHex}); err == nil {
+		HandleError(t, fmt.Errorf("replayed %s was accepted", function))
+	}
+	for i, address := range addresses {
+		balance, _ := stub.MockQuery("balance", []string{address})
+		if !bytes.Equal(balance, before[i]) {
+			HandleError(t, fmt.Errorf("replayed %s changed popcode %s", function, address))
+		}
+	}
+}
+
+// lastOutput returns the index of the newest output of the popcode at
+// address.
+func lastOutput(t *testing.T, stub *shim.MockStub, address string) int {
+	balance := getBalance(t, stub, &keyInfo{address: address})
+	return len(balance.Outputs) - 1
+}
+
+func TestReplayProtection(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+	now := int64(1000)
+	defer setTxTime(&now)()
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	recipient, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeC, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+	addressC := client.Address(popcodeC.PubKey())
+
+	// mint creates a fresh output at address and returns its index
+	mint := func(address string, amount int) int {
+		createHex, _ := c.Create(client.Create{Address: address, Amount: amount, Type: "Gold"}, creator)
+		if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+		return lastOutput(t, stub, address)
+	}
+
+	typeHex, _ := c.RegisterType(client.RegisterType{Name: "Gold", Issuers: []*btcec.PublicKey{creator.PubKey()}, Revision: 1}, creator)
+	checkReplay(t, stub, "registerType", typeHex)
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Bar", CreatedType: "Bar", Ingredients: []client.Ingredient{
+		{Numerator: 1, Denominator: 1, Type: "Gold"}}}, creator)
+	checkReplay(t, stub, "recipe", recipeHex)
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Coin", CreatedType: "Coin", Ingredients: []client.Ingredient{
+		{Numerator: 1, Denominator: 1, Type: "Gold"}}}, creator)
+	checkReplay(t, stub, "recipe", recipeHex)
+	statusHex, _ := c.RecipeStatus(client.RecipeStatus{Name: "Coin", RecipeVersion: 1, Status: Pop.RecipeDeprecated}, creator)
+	checkReplay(t, stub, "recipeStatus", statusHex)
+
+	// a create into a new popcode and one into an existing popcode
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 10, Type: "Gold"}, creator)
+	checkReplay(t, stub, "create", createHex, addressA)
+	createHex, _ = c.Create(client.Create{Address: addressA, Amount: 10, Type: "Gold"}, creator)
+	checkReplay(t, stub, "create", createHex, addressA)
+
+	output := mint(addressA, 10)
+	transferHex, _ := c.Transfer(client.Transfer{Output: output, Owners: []*btcec.PublicKey{owner.PubKey()}}, nil, popcodeA)
+	checkReplay(t, stub, "transfer", transferHex, addressA)
+
+	// a unitize that leaves units on its source could be replayed until the
+	// source was drained
+	output = mint(addressA, 10)
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: output, DestAddress: addressB, DestAmounts: []int{4}}, nil, popcodeA)
+	checkReplay(t, stub, "unitize", unitizeHex, addressA, addressB)
+
+	output = mint(addressA, 10)
+	multiUnitizeHex, _ := c.MultiUnitize(client.MultiUnitize{SourceOutput: output, KeepChange: true,
+		Destinations: []client.Destination{{Address: addressB, Amounts: []int{3}}}}, nil, popcodeA)
+	checkReplay(t, stub, "multiUnitize", multiUnitizeHex, addressA, addressB)
+
+	output = mint(addressA, 10)
+	burnHex, _ := c.Burn(client.Burn{Output: output, Amount: 1}, nil, popcodeA)
+	checkReplay(t, stub, "burn", burnHex, addressA)
+
+	output = mint(addressA, 10)
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: output, Amount: 2}}, Amount: 2, Recipe: "Bar",
+		ReturnChange: true}, creator, nil, popcodeA)
+	checkReplay(t, stub, "combine", combineHex, addressA)
+
+	outputA := mint(addressA, 10)
+	outputB := mint(addressB, 10)
+	multiCombineHex, _ := c.MultiCombine(client.MultiCombine{Sources: []client.CombineSource{
+		{Address: addressA, Output: outputA, Amount: 3}, {Address: addressB, Output: outputB, Amount: 3}},
+		Destination: addressC, Amount: 6, Recipe: "Bar", ReturnChange: true}, creator,
+		[][]*btcec.PrivateKey{nil, nil}, []*btcec.PrivateKey{popcodeA, popcodeB})
+	checkReplay(t, stub, "multiCombine", multiCombineHex, addressA, addressB, addressC)
+
+	outputA = mint(addressA, 10)
+	outputB = mint(addressB, 5)
+	swapHex, _ := c.Swap(client.Swap{A: client.SwapSide{Address: addressA, Output: outputA, Amount: 10},
+		B: client.SwapSide{Address: addressB, Output: outputB, Amount: 5}}, nil, popcodeA, nil, popcodeB)
+	checkReplay(t, stub, "swap", swapHex, addressA, addressB)
+
+	secret := []byte("settled elsewhere")
+	hash := sha256.Sum256(secret)
+	output = mint(addressA, 10)
+	lockHex, _ := c.HashLock(client.HashLock{Output: output, Amount: 4, Hash: hash[:], Expiry: 2000,
+		Recipient: recipient.PubKey()}, nil, popcodeA)
+	checkReplay(t, stub, "hashLock", lockHex, addressA)
+	claimHex, _ := c.Claim(client.Claim{Address: addressA, Output: lastOutput(t, stub, addressA), Preimage: secret}, recipient)
+	checkReplay(t, stub, "claim", claimHex, addressA)
+
+	output = mint(addressA, 10)
+	lockHex, _ = c.HashLock(client.HashLock{Output: output, Amount: 4, Hash: hash[:], Expiry: 1500,
+		Recipient: recipient.PubKey()}, nil, popcodeA)
+	checkReplay(t, stub, "hashLock", lockHex, addressA)
+	now = 1600
+	refundHex, _ := c.Refund(client.Refund{Output: lastOutput(t, stub, addressA)}, nil, popcodeA)
+	checkReplay(t, stub, "refund", refundHex, addressA)
+
+	output = mint(addressA, 10)
+	timeLockHex, _ := c.TimeLock(client.TimeLock{Output: output, Amount: 5, Start: 1600, End: 1700}, nil, popcodeA)
+	checkReplay(t, stub, "timeLock", timeLockHex, addressA)
+
+	output = mint(addressA, 10)
+	counter, _ := c.Counter(addressA)
+	transfer, _ := client.Transfer{Output: output, Owners: []*btcec.PublicKey{owner.PubKey()}}.Sign(counter, nil, popcodeA)
+	burn, _ := client.Burn{Output: output, Amount: 1}.Sign(client.NextCounter(counter), []*btcec.PrivateKey{owner}, popcodeA)
+	batch := client.Batch{}
+	batch.Add("transfer", transfer)
+	batch.Add("burn", burn)
+	batchHex, _ := batch.Encode()
+	checkReplay(t, stub, "batch", batchHex, addressA)
+
+	// a popcode is never stored with the counter it was signed over
+	p, err := loadPopcode(stub, addressA)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	stub.MockTransactionStart("1")
+	err = putPopcode(stub, addressA, p)
+	stub.MockTransactionEnd("1")
+	if err == nil {
+		HandleError(t, fmt.Errorf("popcode %s was stored with an unchanged counter", addressA))
+	}
+}
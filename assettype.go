@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+)
+
+// getAssetType returns the registration of an asset type or nil if it is not
+// registered, in which case any key can create it.
+func getAssetType(stub shim.ChaincodeStubInterface, name string) (*TuxedoPopsStore.AssetType, error) {
+	typeBytes, err := stub.GetState("Type:" + name)
+	if err != nil {
+		fmt.Println("Could not get Type State")
+		return nil, errors.New("Could not get Type State")
+	}
+	if len(typeBytes) == 0 {
+		return nil, nil
+	}
+	assetType := TuxedoPopsStore.AssetType{}
+	err = proto.Unmarshal(typeBytes, &assetType)
+	if err != nil {
+		return nil, fmt.Errorf("Could not deserialize Type %s", name)
+	}
+	return &assetType, nil
+}
+
+// checkProductIssuers checks that creator is an issuer of every registered
+// type recipe makes, as create does for a single mint.
+func checkProductIssuers(stub shim.ChaincodeStubInterface, recipe *TuxedoPopsStore.Recipe, creatorBytes []byte) error {
+	creator, err := btcec.ParsePubKey(creatorBytes, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Creator key")
+	}
+	types := []string{recipe.CreatedType}
+	for _, byproduct := range recipe.Byproducts {
+		types = append(types, byproduct.Type)
+	}
+	for _, name := range types {
+		assetType, err := getAssetType(stub, name)
+		if err != nil {
+			return err
+		}
+		if assetType != nil && !Pop.IsIssuer(assetType.Issuers, creator) {
+			return fmt.Errorf("Creator is not an issuer of type %s", name)
+		}
+	}
+	return nil
+}
+
+func (t *tuxedoPopsChaincode) registerType(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	typeArgs := TuxedoPopsTX.RegisterType{}
+	err := proto.Unmarshal(argsBytes, &typeArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected RegisterType protocol buffer")
+		return fmt.Errorf("Invalid argument expected RegisterType protocol buffer %s", err.Error())
+	}
+	err = Pop.CheckCanonical(int(typeArgs.Version))
+	if err != nil {
+		return err
+	}
+	if typeArgs.Name == "" {
+		return fmt.Errorf("Type name is required")
+	}
+	if len(typeArgs.Issuers) == 0 {
+		return fmt.Errorf("Type (%s) needs at least one issuer", typeArgs.Name)
+	}
+	for _, issuer := range typeArgs.Issuers {
+		_, err := btcec.ParsePubKey(issuer, btcec.S256())
+		if err != nil {
+			return fmt.Errorf("Invalid issuer key %x", issuer)
+		}
+	}
+	if typeArgs.Decimals < 0 {
+		return fmt.Errorf("Invalid decimals %d", typeArgs.Decimals)
+	}
+	if typeArgs.Cap < 0 {
+		return fmt.Errorf("Invalid cap %d", typeArgs.Cap)
+	}
+
+	existing, err := getAssetType(stub, typeArgs.Name)
+	if err != nil {
+		return err
+	}
+	// the first registration is signed by one of its issuers and later
+	// revisions by one of the issuers they replace
+	issuers := typeArgs.Issuers
+	revision := int32(1)
+	if existing != nil {
+		issuers = existing.Issuers
+		revision = existing.Revision + 1
+	}
+	if typeArgs.Revision != revision {
+		return fmt.Errorf("Type (%s) expects revision %d, got %d", typeArgs.Name, revision, typeArgs.Revision)
+	}
+	message := Pop.RegisterTypeMessage(int(typeArgs.Version), typeArgs.Name, int(typeArgs.Revision), typeArgs.Issuers,
+		typeArgs.DisplayName, typeArgs.Unit, int(typeArgs.Decimals), typeArgs.MetadataHash, typeArgs.Cap)
+	issuerPubKey, err := verifyCreatorSig(typeArgs.IssuerPubKey, typeArgs.IssuerSig, message)
+	if err != nil {
+		return err
+	}
+	if !Pop.IsIssuer(issuers, issuerPubKey) {
+		return fmt.Errorf("Only an issuer can register type (%s)", typeArgs.Name)
+	}
+	if typeArgs.Cap > 0 {
+		supply, err := getSupply(stub, typeArgs.Name)
+		if err != nil {
+			return err
+		}
+		if supply.Minted > typeArgs.Cap {
+			return fmt.Errorf("Cap %d of type (%s) is below the %d units already minted", typeArgs.Cap, typeArgs.Name, supply.Minted)
+		}
+	}
+
+	assetType := TuxedoPopsStore.AssetType{}
+	assetType.Issuers = typeArgs.Issuers
+	assetType.DisplayName = typeArgs.DisplayName
+	assetType.Unit = typeArgs.Unit
+	assetType.Decimals = typeArgs.Decimals
+	assetType.MetadataHash = typeArgs.MetadataHash
+	assetType.Revision = typeArgs.Revision
+	assetType.Cap = typeArgs.Cap
+	typeBytes, err := proto.Marshal(&assetType)
+	if err != nil {
+		fmt.Printf("Type Store Serialization error\n")
+		return fmt.Errorf("Type Store Serialization Error\n")
+	}
+	err = stub.PutState("Type:"+typeArgs.Name, typeBytes)
+	if err != nil {
+		fmt.Printf("error putting type state to ledger: (%s)\n", err.Error())
+		return fmt.Errorf("error putting type state to ledger: (%s)\n", err.Error())
+	}
+
+	typeEvent := TxEvents.RegisterTypeEvent{}
+	typeEvent.Name = typeArgs.Name
+	typeEvent.Revision = typeArgs.Revision
+	typeEvent.Issuers = typeArgs.Issuers
+	typeEvent.DisplayName = typeArgs.DisplayName
+	typeEvent.Unit = typeArgs.Unit
+	typeEvent.Decimals = typeArgs.Decimals
+	typeEvent.MetadataHash = typeArgs.MetadataHash
+	typeEvent.Cap = typeArgs.Cap
+	typeEvent.IssuerPubKey = typeArgs.IssuerPubKey
+	typeEventBytes, err := proto.Marshal(&typeEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("registerType", typeEventBytes)
+	return nil
+}
+
+func assetTypeToJSON(name string, assetType *TuxedoPopsStore.AssetType) ([]byte, error) {
+	type JSONAssetType struct {
+		Name         string
+		Issuers      []string
+		DisplayName  string
+		Unit         string
+		Decimals     int
+		MetadataHash string
+		Revision     int
+		Cap          int64
+	}
+	jsonType := JSONAssetType{}
+	jsonType.Name = name
+	for _, issuer := range assetType.Issuers {
+		jsonType.Issuers = append(jsonType.Issuers, hex.EncodeToString(issuer))
+	}
+	jsonType.DisplayName = assetType.DisplayName
+	jsonType.Unit = assetType.Unit
+	jsonType.Decimals = int(assetType.Decimals)
+	jsonType.MetadataHash = hex.EncodeToString(assetType.MetadataHash)
+	jsonType.Revision = int(assetType.Revision)
+	jsonType.Cap = assetType.Cap
+	return json.Marshal(jsonType)
+}
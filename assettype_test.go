@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestRegisterType(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	mint, _ := btcec.NewPrivateKey(btcec.S256())
+	treasury, _ := btcec.NewPrivateKey(btcec.S256())
+	forger, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	// anyone can create a type nobody has registered
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 1, Type: "Coin"}, forger)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	tx := client.RegisterType{Name: "Coin", Issuers: []*btcec.PublicKey{mint.PubKey()}, DisplayName: "Gold Coin", Unit: "oz",
+		Decimals: 2, MetadataHash: []byte{0xca, 0xfe}, Revision: 1}
+	registerHex, _ := c.RegisterType(tx, forger)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err == nil {
+		HandleError(t, fmt.Errorf("registration signed by a key that is not an issuer was accepted"))
+	}
+	// a legacy registration could move the ":" between display name and unit
+	legacy := tx
+	legacy.Version = client.Legacy
+	registerHex, _ = c.RegisterType(legacy, mint)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy registration was accepted"))
+	}
+	registerHex, _ = c.RegisterType(tx, mint)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err == nil {
+		HandleError(t, fmt.Errorf("replayed registration was accepted"))
+	}
+
+	typeBytes, err := stub.MockQuery("type", []string{"Coin"})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	registration := struct {
+		Issuers      []string
+		DisplayName  string
+		Unit         string
+		Decimals     int
+		MetadataHash string
+		Revision     int
+	}{}
+	json.Unmarshal(typeBytes, &registration)
+	if len(registration.Issuers) != 1 || registration.DisplayName != "Gold Coin" || registration.Unit != "oz" ||
+		registration.Decimals != 2 || registration.MetadataHash != "cafe" || registration.Revision != 1 {
+		HandleError(t, fmt.Errorf("unexpected type registration %s", typeBytes))
+	}
+	if _, err := stub.MockQuery("type", []string{"Silver"}); err == nil {
+		HandleError(t, fmt.Errorf("query of an unregistered type succeeded"))
+	}
+
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 1, Type: "Coin"}, forger)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create by a key that is not an issuer was accepted"))
+	}
+	// creating at a new popcode is checked the same way
+	createHex, _ = c.Create(client.Create{Address: client.Address(forger.PubKey()), Amount: 1, Type: "Coin"}, forger)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create at a new popcode by a key that is not an issuer was accepted"))
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 1, Type: "Coin"}, mint)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+	}
+
+	// the mint hands issuance over to the treasury
+	tx = client.RegisterType{Name: "Coin", Issuers: []*btcec.PublicKey{treasury.PubKey()}, DisplayName: "Gold Coin", Unit: "oz",
+		Decimals: 2, Revision: 2}
+	registerHex, _ = c.RegisterType(tx, treasury)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err == nil {
+		HandleError(t, fmt.Errorf("revision signed by a new issuer only was accepted"))
+	}
+	registerHex, _ = c.RegisterType(tx, mint)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 1, Type: "Coin"}, mint)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create by a replaced issuer was accepted"))
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 1, Type: "Coin"}, treasury)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+	}
+}
+
+func TestRecipeIssuers(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	mint, _ := btcec.NewPrivateKey(btcec.S256())
+	forger, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Lead"}, forger)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	// a recipe registered before its created type is checked when it is used
+	lead := []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Lead"}}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Smelt", CreatedType: "Gold", Ingredients: lead}, forger)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	registerHex, _ := c.RegisterType(client.RegisterType{Name: "Gold", Issuers: []*btcec.PublicKey{mint.PubKey()}, Revision: 1}, mint)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	for _, recipe := range []client.Recipe{
+		{Name: "Alchemy", CreatedType: "Gold", Ingredients: lead},
+		{Name: "Refine", CreatedType: "Slag", Ingredients: lead, Byproducts: []client.Byproduct{{Numerator: 1, Denominator: 2, Type: "Gold"}}},
+	} {
+		recipeHex, _ := c.Recipe(recipe, forger)
+		if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+			HandleError(t, fmt.Errorf("recipe %s making Gold was registered by a key that is not an issuer", recipe.Name))
+		}
+	}
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 2}}, Amount: 2, Recipe: "Smelt"}, forger, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine by a key that is not an issuer minted Gold"))
+	}
+	multiCombineHex, _ := c.MultiCombine(client.MultiCombine{Sources: []client.CombineSource{{Address: address, Output: 0, Amount: 2}},
+		Destination: address, Amount: 2, Recipe: "Smelt"}, forger, [][]*btcec.PrivateKey{nil}, []*btcec.PrivateKey{popcode})
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{multiCombineHex}); err == nil {
+		HandleError(t, fmt.Errorf("multiCombine by a key that is not an issuer minted Gold"))
+	}
+
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 2}}, Amount: 2, Recipe: "Smelt"}, mint, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+	}
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Alchemy", CreatedType: "Gold", Ingredients: lead}, mint)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+	}
+}
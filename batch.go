@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+)
+
+// batchStub buffers the writes of a batch so that every step sees the state
+// left by the previous steps while the ledger itself is only updated once all
+// of the steps have succeeded. Range queries are passed through to the ledger.
+type batchStub struct {
+	shim.ChaincodeStubInterface
+	keys    []string
+	writes  map[string][]byte
+	deletes map[string]bool
+	events  []*TxEvents.BatchStepEvent
+}
+
+func newBatchStub(stub shim.ChaincodeStubInterface) *batchStub {
+	return &batchStub{
+		ChaincodeStubInterface: stub,
+		writes:                 make(map[string][]byte),
+		deletes:                make(map[string]bool),
+	}
+}
+
+func (b *batchStub) GetState(key string) ([]byte, error) {
+	if b.deletes[key] {
+		return nil, nil
+	}
+	if value, ok := b.writes[key]; ok {
+		return value, nil
+	}
+	return b.ChaincodeStubInterface.GetState(key)
+}
+
+func (b *batchStub) PutState(key string, value []byte) error {
+	b.touch(key)
+	delete(b.deletes, key)
+	b.writes[key] = value
+	return nil
+}
+
+func (b *batchStub) DelState(key string) error {
+	b.touch(key)
+	delete(b.writes, key)
+	b.deletes[key] = true
+	return nil
+}
+
+func (b *batchStub) SetEvent(name string, payload []byte) error {
+	b.events = append(b.events, &TxEvents.BatchStepEvent{Name: name, Payload: payload})
+	return nil
+}
+
+// touch records the order in which keys are first written.
+func (b *batchStub) touch(key string) {
+	if _, ok := b.writes[key]; ok {
+		return
+	}
+	if b.deletes[key] {
+		return
+	}
+	b.keys = append(b.keys, key)
+}
+
+// commit writes the buffered state to the ledger.
+func (b *batchStub) commit() error {
+	for _, key := range b.keys {
+		var err error
+		if b.deletes[key] {
+			err = b.ChaincodeStubInterface.DelState(key)
+		} else {
+			err = b.ChaincodeStubInterface.PutState(key, b.writes[key])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batch runs each step against the buffered state and commits them together.
+// If any step fails nothing is written and no events are emitted.
+func (t *tuxedoPopsChaincode) batch(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	batchArgs := TuxedoPopsTX.Batch{}
+	err := proto.Unmarshal(argsBytes, &batchArgs)
+	if err != nil {
+		fmt.Printf("Invalid argument expected Batch protocol buffer ERR:(%s)\n", err.Error())
+		return fmt.Errorf("Invalid argument expected Batch protocol buffer ERR:(%s)\n", err.Error())
+	}
+	if len(batchArgs.Steps) == 0 {
+		fmt.Printf("Batch has no steps\n")
+		return fmt.Errorf("Batch has no steps")
+	}
+
+	batchStub := newBatchStub(stub)
+	for i, step := range batchArgs.Steps {
+		if step.Function == "batch" {
+			fmt.Printf("Batch step %d: batches can not be nested\n", i)
+			return fmt.Errorf("Batch step %d: batches can not be nested", i)
+		}
+		err = t.dispatch(batchStub, step.Function, step.Args, st)
+		if err != nil {
+			fmt.Printf("Batch step %d (%s) failed: %s\n", i, step.Function, err.Error())
+			return fmt.Errorf("Batch step %d (%s) failed: %s", i, step.Function, err.Error())
+		}
+	}
+
+	err = batchStub.commit()
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	batchEventBytes, err := proto.Marshal(&TxEvents.BatchEvent{Steps: batchStub.events})
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("batch", batchEventBytes)
+	return nil
+}
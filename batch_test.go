@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestBatch(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	counter, err := c.Counter(address)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	create, _ := client.Create{Address: address, Amount: 10, Type: "Widget"}.Sign(counter, creator)
+	// create advances the counter twice
+	transferCounter := client.NextCounter(client.NextCounter(counter))
+	transfer := client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}}
+
+	// the second step is signed with a stale counter so the whole batch fails
+	badTransfer, _ := transfer.Sign(counter, nil, popcode)
+	batch := client.Batch{}
+	batch.Add("create", create)
+	batch.Add("transfer", badTransfer)
+	batchHex, _ := batch.Encode()
+	if _, err := stub.MockInvoke("1", "batch", []string{batchHex}); err == nil {
+		HandleError(t, fmt.Errorf("batch with a failing step was accepted"))
+	}
+	balance := getBalance(t, stub, &keyInfo{address: address})
+	if len(balance.Outputs) != 0 || balance.Counter != fmt.Sprintf("%x", counter) {
+		HandleError(t, fmt.Errorf("failed batch changed the popcode %v", balance))
+	}
+
+	goodTransfer, _ := transfer.Sign(transferCounter, nil, popcode)
+	batch = client.Batch{}
+	batch.Add("create", create)
+	batch.Add("transfer", goodTransfer)
+	batchHex, _ = batch.Encode()
+	events, err := invokeWithEvents(stub, "batch", []string{batchHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	balance = getBalance(t, stub, &keyInfo{address: address})
+	if len(balance.Outputs) != 1 || balance.Outputs[0].Amount != 10 ||
+		balance.Counter != fmt.Sprintf("%x", client.NextCounter(transferCounter)) {
+		HandleError(t, fmt.Errorf("unexpected balance after batch %v", balance))
+	}
+	if len(balance.Outputs) == 1 && balance.Outputs[0].Owners[0] != fmt.Sprintf("%x", owner.PubKey().SerializeCompressed()) {
+		HandleError(t, fmt.Errorf("batch transfer did not set the owner %v", balance.Outputs[0]))
+	}
+
+	batchEvent := TxEvents.BatchEvent{}
+	if err := proto.Unmarshal(events["batch"], &batchEvent); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if len(batchEvent.Steps) != 2 || batchEvent.Steps[0].Name != "create" || batchEvent.Steps[1].Name != "transfer" {
+		HandleError(t, fmt.Errorf("unexpected batch event %v", batchEvent))
+	}
+	if _, ok := events["create"]; ok {
+		HandleError(t, fmt.Errorf("batch step emitted its own event"))
+	}
+
+	// batches can not contain batches
+	nested := client.Batch{}
+	nested.Steps = append(nested.Steps, batch.Steps[0])
+	nested.Add("batch", &TuxedoPopsTX.Batch{})
+	nestedHex, _ := nested.Encode()
+	if _, err := stub.MockInvoke("1", "batch", []string{nestedHex}); err == nil {
+		HandleError(t, fmt.Errorf("nested batch was accepted"))
+	}
+}
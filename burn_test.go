@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestBurn(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Voucher"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	before := getBalance(t, stub, &keyInfo{address: address})
+
+	// the owner must sign
+	burnHex, _ := c.Burn(client.Burn{Output: 0, Amount: 4, Redemption: "voucher-1"}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err == nil {
+		HandleError(t, fmt.Errorf("burn without owner signature was accepted"))
+	}
+
+	burnHex, _ = c.Burn(client.Burn{Output: 0, Amount: 4, Redemption: "voucher-1"}, []*btcec.PrivateKey{owner}, popcode)
+	events, err := invokeWithEvents(stub, "burn", []string{burnHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	burnEvent := TxEvents.BurnEvent{}
+	if err := proto.Unmarshal(events["burn"], &burnEvent); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if burnEvent.Amount != 4 || burnEvent.Type != "Voucher" || burnEvent.Redemption != "voucher-1" {
+		HandleError(t, fmt.Errorf("unexpected burn event %v", burnEvent))
+	}
+	if fmt.Sprintf("%x", burnEvent.SourceCounter) != before.Outputs[0].PrevCounter ||
+		fmt.Sprintf("%x", burnEvent.DestCounter) != before.Counter {
+		HandleError(t, fmt.Errorf("burn event counters %x %x do not match balance %v", burnEvent.SourceCounter, burnEvent.DestCounter, before))
+	}
+
+	after := getBalance(t, stub, &keyInfo{address: address})
+	if len(after.Outputs) != 1 || after.Outputs[0].Amount != 6 || after.Counter == before.Counter {
+		HandleError(t, fmt.Errorf("unexpected balance after partial burn %v", after))
+	}
+	if after.Outputs[0].PrevCounter != before.Counter {
+		HandleError(t, fmt.Errorf("remaining output was not moved to counter %s", before.Counter))
+	}
+
+	// replaying the same burn fails because the counter moved
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err == nil {
+		HandleError(t, fmt.Errorf("replayed burn was accepted"))
+	}
+
+	burnHex, _ = c.Burn(client.Burn{Output: 0, Amount: 7, Redemption: "too much"}, []*btcec.PrivateKey{owner}, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err == nil {
+		HandleError(t, fmt.Errorf("burn larger than the output was accepted"))
+	}
+
+	burnHex, _ = c.Burn(client.Burn{Output: 0, Amount: 6, Redemption: "end of life", Version: 1}, []*btcec.PrivateKey{owner}, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	after = getBalance(t, stub, &keyInfo{address: address})
+	if len(after.Outputs) != 0 {
+		HandleError(t, fmt.Errorf("output was not removed after full burn %v", after))
+	}
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"crypto/sha256"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+)
+
+// Batch collects signed transactions that Invoke's batch function runs in
+// order and commits together.
+//
+// Every step is checked against the state left by the steps before it, so a
+// step touching a popcode changed earlier in the batch must be signed with the
+// counter that popcode will have by then. NextCounter computes it: create
+// advances a popcode's counter twice, unitize advances the destination once
+// per amount, swap advances each popcode once per output it receives, combine
+// and multiCombine advance the destination once per product they mint and
+// transfer, unitize, multiUnitize, burn, hashLock, claim, refund and timeLock
+// advance the source once, as does multiCombine for sources other than the
+// destination.
+type Batch struct {
+	Steps []*TuxedoPopsTX.BatchStep
+}
+
+// Add appends a signed transaction, e.g. the result of Create.Sign, to be
+// run by function.
+func (b *Batch) Add(function string, msg proto.Message) error {
+	args, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	b.Steps = append(b.Steps, &TuxedoPopsTX.BatchStep{Function: function, Args: args})
+	return nil
+}
+
+// Encode returns the batch as the hex argument expected by Invoke.
+func (b *Batch) Encode() (string, error) {
+	return Encode(&TuxedoPopsTX.Batch{Steps: b.Steps})
+}
+
+// NextCounter returns the counter following counter in a popcode's chain.
+func NextCounter(counter []byte) []byte {
+	next := sha256.Sum256(counter)
+	return next[:]
+}
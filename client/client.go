@@ -0,0 +1,336 @@
+/*
+Copyright (c) 2016 Skuchain,Inc
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Package client builds and signs the TuxedoPopsTX messages accepted by the
+// chaincode's Invoke. The signed messages come from the Pop package so that
+// clients and the chaincode always agree on their format.
+//
+// Every transaction has a Version field selecting the signing encoding. The
+// zero Version signs with Pop.CanonicalEncoding and Legacy with
+// Pop.LegacyEncoding.
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+)
+
+// Legacy is the Version of a transaction signed with Pop.LegacyEncoding.
+const Legacy = -1
+
+// signingVersion returns the Pop encoding a transaction of version v is signed
+// with.
+func signingVersion(v int) int {
+	switch v {
+	case 0:
+		return Pop.CanonicalEncoding
+	case Legacy:
+		return Pop.LegacyEncoding
+	}
+	return v
+}
+
+// QueryFunc runs a chaincode query, e.g. shim.MockStub.MockQuery or a
+// wrapper around a peer's REST or gRPC API.
+type QueryFunc func(function string, args []string) ([]byte, error)
+
+// Client fetches popcode counters through a QueryFunc and uses them to sign
+// transactions.
+type Client struct {
+	query QueryFunc
+}
+
+func New(query QueryFunc) *Client {
+	return &Client{query: query}
+}
+
+// Counter returns the current counter of the popcode at address as reported
+// by the balance query.
+func (c *Client) Counter(address string) ([]byte, error) {
+	balanceBytes, err := c.query("balance", []string{address})
+	if err != nil {
+		return nil, fmt.Errorf("balance query failed for address (%s): %v", address, err)
+	}
+	balance := struct {
+		Counter string
+	}{}
+	err = json.Unmarshal(balanceBytes, &balance)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode balance for address (%s): %v", address, err)
+	}
+	counter, err := hex.DecodeString(balance.Counter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid counter (%s) for address (%s)", balance.Counter, address)
+	}
+	return counter, nil
+}
+
+// Create fetches the counter of tx.Address and returns the signed CreateTX
+// as the hex argument expected by Invoke.
+func (c *Client) Create(tx Create, creator *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(tx.Address)
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, creator)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Transfer fetches the counter of the popcode and returns the signed
+// TransferOwners as the hex argument expected by Invoke.
+func (c *Client) Transfer(tx Transfer, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Unitize fetches the counter of the source popcode and returns the signed
+// Unitize as the hex argument expected by Invoke.
+func (c *Client) Unitize(tx Unitize, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// MultiUnitize fetches the counter of the source popcode and returns the
+// signed MultiUnitize as the hex argument expected by Invoke.
+func (c *Client) MultiUnitize(tx MultiUnitize, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Combine fetches the counter of the popcode and returns the signed Combine
+// as the hex argument expected by Invoke.
+func (c *Client) Combine(tx Combine, creator *btcec.PrivateKey, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, creator, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// MultiCombine fetches the counters of the source popcodes and returns the
+// combine signed by all of them. owners[i] and popcodes[i] sign for the i-th
+// of tx.Addresses().
+func (c *Client) MultiCombine(tx MultiCombine, creator *btcec.PrivateKey, owners [][]*btcec.PrivateKey, popcodes []*btcec.PrivateKey) (string, error) {
+	addresses := tx.Addresses()
+	if len(owners) != len(addresses) || len(popcodes) != len(addresses) {
+		return "", fmt.Errorf("expected owners and popcode keys for %d source popcodes", len(addresses))
+	}
+	counters := make([][]byte, len(addresses))
+	for i, address := range addresses {
+		counter, err := c.Counter(address)
+		if err != nil {
+			return "", err
+		}
+		counters[i] = counter
+	}
+	signers := []*TuxedoPopsTX.CombineSigner{}
+	for i := range addresses {
+		signer, err := tx.SignSource(counters, owners[i], popcodes[i])
+		if err != nil {
+			return "", err
+		}
+		signers = append(signers, signer)
+	}
+	msg, err := tx.Sign(counters, creator, signers)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Burn fetches the counter of the popcode and returns the signed Burn as the
+// hex argument expected by Invoke.
+func (c *Client) Burn(tx Burn, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Swap fetches the counters of both popcodes and returns the swap signed by
+// both sides.
+func (c *Client) Swap(tx Swap, ownersA []*btcec.PrivateKey, popcodeA *btcec.PrivateKey,
+	ownersB []*btcec.PrivateKey, popcodeB *btcec.PrivateKey) (string, error) {
+	counterA, err := c.Counter(tx.A.Address)
+	if err != nil {
+		return "", err
+	}
+	counterB, err := c.Counter(tx.B.Address)
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counterA, counterB, ownersA, popcodeA, ownersB, popcodeB)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// HashLock fetches the counter of the popcode and returns the signed
+// HashLock as the hex argument expected by Invoke.
+func (c *Client) HashLock(tx HashLock, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Claim fetches the counter of tx.Address and returns the Claim signed by
+// the recipient.
+func (c *Client) Claim(tx Claim, recipient *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(tx.Address)
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, recipient)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Refund fetches the counter of the popcode and returns the signed Refund.
+func (c *Client) Refund(tx Refund, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// TimeLock fetches the counter of the popcode and returns the signed
+// TimeLock as the hex argument expected by Invoke.
+func (c *Client) TimeLock(tx TimeLock, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (string, error) {
+	counter, err := c.Counter(Address(popcode.PubKey()))
+	if err != nil {
+		return "", err
+	}
+	msg, err := tx.Sign(counter, owners, popcode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Recipe returns the signed Recipe as the hex argument expected by Invoke.
+// Recipes are not bound to a popcode so no counter is needed.
+func (c *Client) Recipe(tx Recipe, creator *btcec.PrivateKey) (string, error) {
+	msg, err := tx.Sign(creator)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// RecipeStatus returns the signed RecipeStatus as the hex argument expected by
+// Invoke.
+func (c *Client) RecipeStatus(tx RecipeStatus, creator *btcec.PrivateKey) (string, error) {
+	msg, err := tx.Sign(creator)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// RegisterType returns the signed RegisterType as the hex argument expected by
+// Invoke.
+func (c *Client) RegisterType(tx RegisterType, issuer *btcec.PrivateKey) (string, error) {
+	msg, err := tx.Sign(issuer)
+	if err != nil {
+		return "", err
+	}
+	return Encode(msg)
+}
+
+// Encode serializes a transaction into the hex argument expected by Invoke.
+func Encode(msg proto.Message) (string, error) {
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(msgBytes), nil
+}
+
+// Address derives a popcode address from its public key the same way Invoke
+// does: the first 20 bytes of the sha256 of the compressed key, hex encoded.
+func Address(pubKey *btcec.PublicKey) string {
+	keyDigest := sha256.Sum256(pubKey.SerializeCompressed())
+	return hex.EncodeToString(keyDigest[:20])
+}
+
+func sign(key *btcec.PrivateKey, m []byte) ([]byte, error) {
+	mDigest := sha256.Sum256(m)
+	sig, err := key.Sign(mDigest[:])
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+func signAll(keys []*btcec.PrivateKey, m []byte) ([][]byte, error) {
+	sigs := [][]byte{}
+	for _, key := range keys {
+		sig, err := sign(key, m)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
@@ -0,0 +1,160 @@
+package client_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func newKey(t *testing.T) *btcec.PrivateKey {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// ledger serves balance queries from a set of in memory popcodes.
+type ledger map[string]*Pop.Pop
+
+func (l ledger) query(function string, args []string) ([]byte, error) {
+	return l[args[0]].ToJSON(), nil
+}
+
+func decode(t *testing.T, hexArg string, msg proto.Message) {
+	argBytes, err := hex.DecodeString(hexArg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.Unmarshal(argBytes, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientMessagesVerify(t *testing.T) {
+	// the zero Version signs with the canonical encoding
+	for _, version := range []int{client.Legacy, 0, Pop.CanonicalEncoding} {
+		testClientMessagesVerify(t, version)
+	}
+}
+
+func testClientMessagesVerify(t *testing.T, version int) {
+	creator := newKey(t)
+	owner := newKey(t)
+	popcodeKey := newKey(t)
+	destKey := newKey(t)
+
+	counter := sha256.Sum256([]byte("counter"))
+	popcode := &Pop.Pop{Address: client.Address(popcodeKey.PubKey()), Counter: counter[:]}
+	destCounter := sha256.Sum256([]byte("dest counter"))
+	dest := &Pop.Pop{Address: client.Address(destKey.PubKey()), Counter: destCounter[:]}
+	l := ledger{popcode.Address: popcode, dest.Address: dest}
+	c := client.New(l.query)
+
+	// create
+	createHex, err := c.Create(client.Create{Address: popcode.Address, Amount: 10, Type: "Water", Data: "a:b", Version: version}, creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createArgs := TuxedoPopsTX.CreateTX{}
+	decode(t, createHex, &createArgs)
+	err = popcode.CreateOutput(int(createArgs.Amount), createArgs.Type, createArgs.Data, createArgs.CreatorPubKey, createArgs.CreatorSig, nil, int(createArgs.Version))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// transfer
+	transferHex, err := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}, Data: "owned", Version: version}, nil, popcodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transferArgs := TuxedoPopsTX.TransferOwners{}
+	decode(t, transferHex, &transferArgs)
+	err = popcode.SetOwner(int(transferArgs.Output), int(transferArgs.Threshold), transferArgs.Data, transferArgs.Owners,
+		transferArgs.PrevOwnerSigs, transferArgs.PopcodePubKey, transferArgs.PopcodeSig, 0, int(transferArgs.Version))
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+
+	// recipe
+	recipeHex, err := c.Recipe(client.Recipe{Name: "Steam", CreatedType: "Steam",
+		Ingredients: []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Water"}}, Version: version}, creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipeArgs := TuxedoPopsTX.Recipe{}
+	decode(t, recipeHex, &recipeArgs)
+	recipeSig, err := btcec.ParseDERSignature(recipeArgs.CreatorSig, btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipeDigest := sha256.Sum256(Pop.RecipeMessage(int(recipeArgs.Version), recipeArgs.RecipeName, int(recipeArgs.RecipeVersion), recipeArgs.CreatedType, recipeArgs.Ingredients, recipeArgs.Byproducts,
+		recipeArgs.Manufacturers, int(recipeArgs.Threshold)))
+	if !recipeSig.Verify(recipeDigest[:], creator.PubKey()) {
+		t.Fatal("recipe: invalid creator signature")
+	}
+
+	// combine
+	combineHex, err := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 4}}, Amount: 4, Recipe: "Steam", Data: "boil", Version: version},
+		creator, []*btcec.PrivateKey{owner}, popcodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combineArgs := TuxedoPopsTX.Combine{}
+	decode(t, combineHex, &combineArgs)
+	recipe := TuxedoPopsStore.Recipe{CreatedType: "Steam", Ingredients: []*TuxedoPopsStore.Ingredient{{Numerator: 1, Denominator: 1, Type: "Water"}}}
+	sources := make([]Pop.SourceOutput, len(combineArgs.Sources))
+	for i, source := range combineArgs.Sources {
+		sources[i] = source
+	}
+	err = popcode.CombineOutputs(sources, combineArgs.OwnerSigs, combineArgs.PopcodePubKey, combineArgs.PopcodeSig,
+		int(combineArgs.Amount), combineArgs.Recipe, int(combineArgs.RecipeVersion), recipe, combineArgs.Data, combineArgs.CreatorPubKey, combineArgs.CreatorSig, combineArgs.ApprovalSigs, combineArgs.ReturnChange, 0, int(combineArgs.Version))
+	if err != nil {
+		t.Fatalf("combine: %v", err)
+	}
+
+	// unitize
+	unitizeHex, err := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: dest.Address, DestAmounts: []int{2, 4}, Data: "ship", Version: version},
+		[]*btcec.PrivateKey{owner}, popcodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unitizeArgs := TuxedoPopsTX.Unitize{}
+	decode(t, unitizeHex, &unitizeArgs)
+	amounts := make([]int, len(unitizeArgs.DestAmounts))
+	for i, amount := range unitizeArgs.DestAmounts {
+		amounts[i] = int(amount)
+	}
+	err = popcode.UnitizeOutput(int(unitizeArgs.SourceOutput), amounts, unitizeArgs.Data, dest,
+		unitizeArgs.OwnerSigs, unitizeArgs.PopcodePubKey, unitizeArgs.PopcodeSig, 0, int(unitizeArgs.Version))
+	if err != nil {
+		t.Fatalf("unitize: %v", err)
+	}
+	if len(dest.Outputs) != 2 || len(popcode.Outputs) != 1 {
+		t.Fatalf("unexpected outputs after unitize: source %s dest %s", popcode.ToJSON(), dest.ToJSON())
+	}
+}
+
+func TestClientRejectsWrongCounter(t *testing.T) {
+	creator := newKey(t)
+	popcodeKey := newKey(t)
+	counter := sha256.Sum256([]byte("counter"))
+	popcode := &Pop.Pop{Address: client.Address(popcodeKey.PubKey()), Counter: counter[:]}
+
+	stale := sha256.Sum256([]byte("stale"))
+	createArgs, err := client.Create{Address: popcode.Address, Amount: 10, Type: "Water"}.Sign(stale[:], creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = popcode.CreateOutput(int(createArgs.Amount), createArgs.Type, createArgs.Data, createArgs.CreatorPubKey, createArgs.CreatorSig, nil, int(createArgs.Version))
+	if err == nil {
+		t.Fatal("create signed over a stale counter was accepted")
+	}
+}
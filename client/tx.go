@@ -0,0 +1,694 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+)
+
+// Create mints Amount units of Type on the popcode at Address.
+type Create struct {
+	Address string
+	Amount  int
+	Type    string
+	Data    string
+	Version int
+}
+
+func (tx Create) Sign(counter []byte, creator *btcec.PrivateKey) (*TuxedoPopsTX.CreateTX, error) {
+	m := Pop.CreateMessage(signingVersion(tx.Version), counter, tx.Address, tx.Amount, tx.Type, tx.Data)
+	creatorSig, err := sign(creator, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.CreateTX{}
+	msg.Address = tx.Address
+	msg.Amount = int32(tx.Amount)
+	msg.Type = tx.Type
+	msg.Data = tx.Data
+	msg.CreatorPubKey = creator.PubKey().SerializeCompressed()
+	msg.CreatorSig = creatorSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Transfer replaces the owners of an output. A zero Threshold requires all
+// of the new owners to sign later spends.
+type Transfer struct {
+	Output    int
+	Threshold int
+	Owners    []*btcec.PublicKey
+	Data      string
+	Version   int
+}
+
+func (tx Transfer) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.TransferOwners, error) {
+	if len(tx.Owners) < tx.Threshold {
+		return nil, fmt.Errorf("threshold value (%d) is larger than number of owners (%d)", tx.Threshold, len(tx.Owners))
+	}
+	newOwners := make([]btcec.PublicKey, len(tx.Owners))
+	msg := TuxedoPopsTX.TransferOwners{}
+	for i, owner := range tx.Owners {
+		newOwners[i] = *owner
+		msg.Owners = append(msg.Owners, owner.SerializeCompressed())
+	}
+	m := Pop.SetOwnerMessage(signingVersion(tx.Version), counter, tx.Output, tx.Threshold, tx.Data, newOwners)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg.Address = Address(popcode.PubKey())
+	msg.Output = int32(tx.Output)
+	msg.Threshold = int32(tx.Threshold)
+	msg.Data = tx.Data
+	msg.PrevOwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Unitize moves DestAmounts out of an output into new outputs on the popcode
+// at DestAddress.
+type Unitize struct {
+	SourceOutput int
+	DestAddress  string
+	DestAmounts  []int
+	Data         string
+	Version      int
+}
+
+func (tx Unitize) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.Unitize, error) {
+	m := Pop.UnitizeMessage(signingVersion(tx.Version), counter, tx.DestAddress, tx.Data, tx.SourceOutput, tx.DestAmounts)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.Unitize{}
+	msg.SourceOutput = int32(tx.SourceOutput)
+	msg.SourceAddress = Address(popcode.PubKey())
+	msg.DestAddress = tx.DestAddress
+	for _, amount := range tx.DestAmounts {
+		msg.DestAmounts = append(msg.DestAmounts, int32(amount))
+	}
+	msg.Data = tx.Data
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Source is an amount taken from one output of the combining popcode.
+type Source struct {
+	Output int
+	Amount int
+}
+
+// Combine consumes Sources according to Recipe and creates Amount units of
+// the recipe's created type. RecipeVersion pins a version of the recipe,
+// otherwise its latest active version is used.
+type Combine struct {
+	Sources       []Source
+	Amount        int
+	Recipe        string
+	RecipeVersion int
+	Data          string
+	Version       int
+	// ReturnChange leaves the units of the sources beyond the recipe's
+	// ratios on the source outputs instead of requiring exact amounts.
+	ReturnChange bool
+}
+
+func (tx Combine) message(counter []byte) []byte {
+	sources := make([]Pop.SourceOutput, len(tx.Sources))
+	for i, source := range tx.Sources {
+		sources[i] = &TuxedoPopsTX.CombineSources{SourceOutput: int32(source.Output), SourceAmount: int32(source.Amount)}
+	}
+	return Pop.CombineMessage(signingVersion(tx.Version), counter, tx.Recipe, tx.RecipeVersion, sources, tx.Amount, tx.Data, tx.ReturnChange)
+}
+
+func (tx Combine) Sign(counter []byte, creator *btcec.PrivateKey, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.Combine, error) {
+	msg := TuxedoPopsTX.Combine{}
+	for _, source := range tx.Sources {
+		combineSource := TuxedoPopsTX.CombineSources{}
+		combineSource.SourceOutput = int32(source.Output)
+		combineSource.SourceAmount = int32(source.Amount)
+		msg.Sources = append(msg.Sources, &combineSource)
+	}
+	m := tx.message(counter)
+	creatorSig, err := sign(creator, m)
+	if err != nil {
+		return nil, err
+	}
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg.Address = Address(popcode.PubKey())
+	msg.Amount = int32(tx.Amount)
+	msg.Recipe = tx.Recipe
+	msg.RecipeVersion = int32(tx.RecipeVersion)
+	msg.Data = tx.Data
+	msg.CreatorPubKey = creator.PubKey().SerializeCompressed()
+	msg.CreatorSig = creatorSig
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.ReturnChange = tx.ReturnChange
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Approve returns a manufacturer's approval of the combine, to be added to the
+// ApprovalSigs of the signed transaction when its recipe requires more than
+// one manufacturer.
+func (tx Combine) Approve(counter []byte, manufacturer *btcec.PrivateKey) ([]byte, error) {
+	return sign(manufacturer, tx.message(counter))
+}
+
+// CombineSource is an amount taken from one output of the popcode at Address.
+type CombineSource struct {
+	Address string
+	Output  int
+	Amount  int
+}
+
+// MultiCombine is a Combine whose Sources are held on several popcodes and
+// whose products are created on Destination. The creator and every source
+// popcode sign the same message over the counters of all source popcodes,
+// given in the order of Addresses.
+type MultiCombine struct {
+	Sources       []CombineSource
+	Destination   string
+	Amount        int
+	Recipe        string
+	RecipeVersion int
+	Data          string
+	Version       int
+	ReturnChange  bool
+}
+
+// Addresses returns the source popcodes in the order they first appear in
+// Sources.
+func (tx MultiCombine) Addresses() []string {
+	addresses := []string{}
+	seen := make(map[string]bool)
+	for _, source := range tx.Sources {
+		if !seen[source.Address] {
+			seen[source.Address] = true
+			addresses = append(addresses, source.Address)
+		}
+	}
+	return addresses
+}
+
+func (tx MultiCombine) message(counters [][]byte) []byte {
+	addresses := tx.Addresses()
+	sources := make([][]Pop.SourceOutput, len(addresses))
+	for i, address := range addresses {
+		for _, source := range tx.Sources {
+			if source.Address == address {
+				sources[i] = append(sources[i], &TuxedoPopsTX.CombineSources{SourceOutput: int32(source.Output), SourceAmount: int32(source.Amount)})
+			}
+		}
+	}
+	return Pop.MultiCombineMessage(signingVersion(tx.Version), addresses, counters, sources, tx.Destination, tx.Recipe, tx.RecipeVersion, tx.Amount, tx.Data, tx.ReturnChange)
+}
+
+// SignSource signs the combine for one of its source popcodes.
+func (tx MultiCombine) SignSource(counters [][]byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.CombineSigner, error) {
+	m := tx.message(counters)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	signer := TuxedoPopsTX.CombineSigner{}
+	signer.Address = Address(popcode.PubKey())
+	signer.OwnerSigs = ownerSigs
+	signer.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	signer.PopcodeSig = popcodeSig
+	return &signer, nil
+}
+
+// Approve returns a manufacturer's approval of the combine.
+func (tx MultiCombine) Approve(counters [][]byte, manufacturer *btcec.PrivateKey) ([]byte, error) {
+	return sign(manufacturer, tx.message(counters))
+}
+
+// Sign assembles the combine from the creator's signature and the results of
+// SignSource for every source popcode.
+func (tx MultiCombine) Sign(counters [][]byte, creator *btcec.PrivateKey, signers []*TuxedoPopsTX.CombineSigner) (*TuxedoPopsTX.MultiCombine, error) {
+	creatorSig, err := sign(creator, tx.message(counters))
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.MultiCombine{}
+	for _, source := range tx.Sources {
+		msg.Sources = append(msg.Sources, &TuxedoPopsTX.MultiCombineSource{Address: source.Address, Output: int32(source.Output), Amount: int32(source.Amount)})
+	}
+	msg.Signers = signers
+	msg.Destination = tx.Destination
+	msg.Amount = int32(tx.Amount)
+	msg.Recipe = tx.Recipe
+	msg.RecipeVersion = int32(tx.RecipeVersion)
+	msg.CreatorPubKey = creator.PubKey().SerializeCompressed()
+	msg.CreatorSig = creatorSig
+	msg.Data = tx.Data
+	msg.ReturnChange = tx.ReturnChange
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Ingredient is consumed at Numerator units of Type for every Denominator
+// units created.
+type Ingredient struct {
+	Numerator   int
+	Denominator int
+	Type        string
+}
+
+// Byproduct is minted at Numerator units of Type for every Denominator units
+// of a recipe's created type.
+type Byproduct struct {
+	Numerator   int
+	Denominator int
+	Type        string
+}
+
+// Recipe registers version RecipeVersion of a recipe named Name producing
+// CreatedType and Byproducts. Versions after the first must be signed by the
+// creator of the first and registered in order. When Manufacturers are listed
+// only they can create with the recipe, Threshold of them together if it is
+// above one.
+type Recipe struct {
+	Name          string
+	RecipeVersion int
+	CreatedType   string
+	Ingredients   []Ingredient
+	Byproducts    []Byproduct
+	Manufacturers []*btcec.PublicKey
+	Threshold     int
+	Version       int
+}
+
+func (tx Recipe) Sign(creator *btcec.PrivateKey) (*TuxedoPopsTX.Recipe, error) {
+	msg := TuxedoPopsTX.Recipe{}
+	msg.RecipeName = tx.Name
+	msg.RecipeVersion = int32(tx.RecipeVersion)
+	msg.CreatedType = tx.CreatedType
+	msg.Version = int32(signingVersion(tx.Version))
+	for _, ingredient := range tx.Ingredients {
+		txIngredient := TuxedoPopsTX.Ingredient{}
+		txIngredient.Numerator = int32(ingredient.Numerator)
+		txIngredient.Denominator = int32(ingredient.Denominator)
+		txIngredient.Type = ingredient.Type
+		msg.Ingredients = append(msg.Ingredients, &txIngredient)
+	}
+	for _, byproduct := range tx.Byproducts {
+		txProduct := TuxedoPopsTX.Product{}
+		txProduct.Numerator = int32(byproduct.Numerator)
+		txProduct.Denominator = int32(byproduct.Denominator)
+		txProduct.Type = byproduct.Type
+		msg.Byproducts = append(msg.Byproducts, &txProduct)
+	}
+	for _, manufacturer := range tx.Manufacturers {
+		msg.Manufacturers = append(msg.Manufacturers, manufacturer.SerializeCompressed())
+	}
+	msg.Threshold = int32(tx.Threshold)
+	m := Pop.RecipeMessage(signingVersion(tx.Version), msg.RecipeName, tx.RecipeVersion, msg.CreatedType, msg.Ingredients, msg.Byproducts,
+		msg.Manufacturers, tx.Threshold)
+	creatorSig, err := sign(creator, m)
+	if err != nil {
+		return nil, err
+	}
+	msg.CreatorPubKey = creator.PubKey().SerializeCompressed()
+	msg.CreatorSig = creatorSig
+	return &msg, nil
+}
+
+// RecipeStatus deprecates or revokes version RecipeVersion of a recipe. It
+// must be signed by the recipe's creator.
+type RecipeStatus struct {
+	Name          string
+	RecipeVersion int
+	Status        int
+	Version       int
+}
+
+func (tx RecipeStatus) Sign(creator *btcec.PrivateKey) (*TuxedoPopsTX.RecipeStatus, error) {
+	m := Pop.RecipeStatusMessage(signingVersion(tx.Version), tx.Name, tx.RecipeVersion, tx.Status)
+	creatorSig, err := sign(creator, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.RecipeStatus{}
+	msg.RecipeName = tx.Name
+	msg.RecipeVersion = int32(tx.RecipeVersion)
+	msg.Status = int32(tx.Status)
+	msg.CreatorPubKey = creator.PubKey().SerializeCompressed()
+	msg.CreatorSig = creatorSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// RegisterType registers the issuers and display details of an asset type,
+// after which only the issuers can create it. Revision is 1 for the first
+// registration and one more than the current revision to replace it, in which
+// case it is signed by one of the current issuers.
+type RegisterType struct {
+	Name         string
+	Issuers      []*btcec.PublicKey
+	DisplayName  string
+	Unit         string
+	Decimals     int
+	MetadataHash []byte
+	Revision     int
+	// Cap limits the units of the type that can ever be minted, 0 for no
+	// limit.
+	Cap     int64
+	Version int
+}
+
+func (tx RegisterType) Sign(issuer *btcec.PrivateKey) (*TuxedoPopsTX.RegisterType, error) {
+	issuers := make([][]byte, len(tx.Issuers))
+	for i, key := range tx.Issuers {
+		issuers[i] = key.SerializeCompressed()
+	}
+	m := Pop.RegisterTypeMessage(signingVersion(tx.Version), tx.Name, tx.Revision, issuers, tx.DisplayName, tx.Unit, tx.Decimals, tx.MetadataHash, tx.Cap)
+	issuerSig, err := sign(issuer, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.RegisterType{}
+	msg.Name = tx.Name
+	msg.Issuers = issuers
+	msg.DisplayName = tx.DisplayName
+	msg.Unit = tx.Unit
+	msg.Decimals = int32(tx.Decimals)
+	msg.MetadataHash = tx.MetadataHash
+	msg.Revision = int32(tx.Revision)
+	msg.Cap = tx.Cap
+	msg.IssuerPubKey = issuer.PubKey().SerializeCompressed()
+	msg.IssuerSig = issuerSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Burn destroys Amount units of an output, recording Redemption as the
+// reason or voucher reference.
+type Burn struct {
+	Output     int
+	Amount     int
+	Redemption string
+	Version    int
+}
+
+func (tx Burn) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.Burn, error) {
+	m := Pop.BurnMessage(signingVersion(tx.Version), counter, tx.Output, tx.Amount, tx.Redemption)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.Burn{}
+	msg.Address = Address(popcode.PubKey())
+	msg.Output = int32(tx.Output)
+	msg.Amount = int32(tx.Amount)
+	msg.Redemption = tx.Redemption
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Destination receives one new output per amount.
+type Destination struct {
+	Address string
+	Amounts []int
+}
+
+// MultiUnitize splits an output across several destination popcodes. With
+// KeepChange the remainder stays on the source as a change output.
+type MultiUnitize struct {
+	SourceOutput int
+	Destinations []Destination
+	KeepChange   bool
+	Data         string
+	Version      int
+}
+
+func (tx MultiUnitize) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.MultiUnitize, error) {
+	msg := TuxedoPopsTX.MultiUnitize{}
+	destAddresses := make([]string, len(tx.Destinations))
+	amounts := make([][]int, len(tx.Destinations))
+	for i, dest := range tx.Destinations {
+		destAddresses[i] = dest.Address
+		amounts[i] = dest.Amounts
+		txDest := TuxedoPopsTX.UnitizeDestination{}
+		txDest.DestAddress = dest.Address
+		for _, amount := range dest.Amounts {
+			txDest.DestAmounts = append(txDest.DestAmounts, int32(amount))
+		}
+		msg.Destinations = append(msg.Destinations, &txDest)
+	}
+	m := Pop.MultiUnitizeMessage(signingVersion(tx.Version), counter, tx.SourceOutput, destAddresses, amounts, tx.KeepChange, tx.Data)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg.SourceOutput = int32(tx.SourceOutput)
+	msg.SourceAddress = Address(popcode.PubKey())
+	msg.KeepChange = tx.KeepChange
+	msg.Data = tx.Data
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// SwapSide gives Amount units of output Output on the popcode at Address to
+// the other side of a swap.
+type SwapSide struct {
+	Address string
+	Output  int
+	Amount  int
+}
+
+// Swap exchanges outputs between popcodes A and B. Both sides sign the same
+// message over both counters, so each party can sign with SignSide and
+// exchange the results before assembling the transaction with Build.
+type Swap struct {
+	A       SwapSide
+	B       SwapSide
+	Data    string
+	Version int
+}
+
+func (tx Swap) message(counterA []byte, counterB []byte) []byte {
+	return Pop.SwapMessage(signingVersion(tx.Version), counterA, tx.A.Address, tx.A.Output, tx.A.Amount,
+		counterB, tx.B.Address, tx.B.Output, tx.B.Amount, tx.Data)
+}
+
+// SignSide signs the side of the swap belonging to popcode.
+func (tx Swap) SignSide(counterA []byte, counterB []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.SwapSide, error) {
+	side := SwapSide{}
+	switch Address(popcode.PubKey()) {
+	case tx.A.Address:
+		side = tx.A
+	case tx.B.Address:
+		side = tx.B
+	default:
+		return nil, fmt.Errorf("popcode %s is not a side of the swap", Address(popcode.PubKey()))
+	}
+	m := tx.message(counterA, counterB)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.SwapSide{}
+	msg.Address = side.Address
+	msg.Output = int32(side.Output)
+	msg.Amount = int32(side.Amount)
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	return &msg, nil
+}
+
+// Build assembles the swap from the signed sides of A and B.
+func (tx Swap) Build(a *TuxedoPopsTX.SwapSide, b *TuxedoPopsTX.SwapSide) *TuxedoPopsTX.Swap {
+	msg := TuxedoPopsTX.Swap{}
+	msg.A = a
+	msg.B = b
+	msg.Data = tx.Data
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg
+}
+
+func (tx Swap) Sign(counterA []byte, counterB []byte, ownersA []*btcec.PrivateKey, popcodeA *btcec.PrivateKey,
+	ownersB []*btcec.PrivateKey, popcodeB *btcec.PrivateKey) (*TuxedoPopsTX.Swap, error) {
+	a, err := tx.SignSide(counterA, counterB, ownersA, popcodeA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := tx.SignSide(counterA, counterB, ownersB, popcodeB)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Build(a, b), nil
+}
+
+// HashLock locks Amount units of an output so that Recipient can claim them
+// by revealing the preimage of Hash before Expiry, in unix seconds, after
+// which the owners can refund them.
+type HashLock struct {
+	Output    int
+	Amount    int
+	Hash      []byte
+	Expiry    int64
+	Recipient *btcec.PublicKey
+	Version   int
+}
+
+func (tx HashLock) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.HashLock, error) {
+	recipient := tx.Recipient.SerializeCompressed()
+	m := Pop.HashLockMessage(signingVersion(tx.Version), counter, tx.Output, tx.Amount, tx.Hash, tx.Expiry, recipient)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.HashLock{}
+	msg.Address = Address(popcode.PubKey())
+	msg.Output = int32(tx.Output)
+	msg.Amount = int32(tx.Amount)
+	msg.Hash = tx.Hash
+	msg.Expiry = tx.Expiry
+	msg.Recipient = recipient
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Claim takes a hash locked output on the popcode at Address for its
+// recipient by revealing Preimage.
+type Claim struct {
+	Address  string
+	Output   int
+	Preimage []byte
+	Version  int
+}
+
+func (tx Claim) Sign(counter []byte, recipient *btcec.PrivateKey) (*TuxedoPopsTX.Claim, error) {
+	m := Pop.ClaimMessage(signingVersion(tx.Version), counter, tx.Output, tx.Preimage)
+	recipientSig, err := sign(recipient, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.Claim{}
+	msg.Address = tx.Address
+	msg.Output = int32(tx.Output)
+	msg.Preimage = tx.Preimage
+	msg.RecipientSig = recipientSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// Refund returns an expired hash locked output to its owners.
+type Refund struct {
+	Output  int
+	Version int
+}
+
+func (tx Refund) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.Refund, error) {
+	m := Pop.RefundMessage(signingVersion(tx.Version), counter, tx.Output)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.Refund{}
+	msg.Address = Address(popcode.PubKey())
+	msg.Output = int32(tx.Output)
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
+
+// TimeLock locks Amount units of an output until they vest: nothing before
+// Start, everything at End and linearly in between, in steps of Period
+// seconds if it is set. Start equal to End locks everything until then.
+type TimeLock struct {
+	Output  int
+	Amount  int
+	Start   int64
+	End     int64
+	Period  int64
+	Version int
+}
+
+func (tx TimeLock) Sign(counter []byte, owners []*btcec.PrivateKey, popcode *btcec.PrivateKey) (*TuxedoPopsTX.TimeLock, error) {
+	m := Pop.TimeLockMessage(signingVersion(tx.Version), counter, tx.Output, tx.Amount, tx.Start, tx.End, tx.Period)
+	ownerSigs, err := signAll(owners, m)
+	if err != nil {
+		return nil, err
+	}
+	popcodeSig, err := sign(popcode, m)
+	if err != nil {
+		return nil, err
+	}
+	msg := TuxedoPopsTX.TimeLock{}
+	msg.Address = Address(popcode.PubKey())
+	msg.Output = int32(tx.Output)
+	msg.Amount = int32(tx.Amount)
+	msg.Start = tx.Start
+	msg.End = tx.End
+	msg.Period = tx.Period
+	msg.OwnerSigs = ownerSigs
+	msg.PopcodePubKey = popcode.PubKey().SerializeCompressed()
+	msg.PopcodeSig = popcodeSig
+	msg.Version = int32(signingVersion(tx.Version))
+	return &msg, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// balanceJSON mirrors Pop.ToJSON, whose Outputs are themselves JSON strings.
+type balanceJSON struct {
+	Address string
+	Counter string
+	Outputs []string
+}
+
+type decodedBalance struct {
+	Address string
+	Counter string
+	Outputs []json.RawMessage
+}
+
+func decodeBalance(r io.Reader) (*decodedBalance, error) {
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	balance := balanceJSON{}
+	err = json.Unmarshal(input, &balance)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balance query result: %v", err)
+	}
+	decoded := decodedBalance{Address: balance.Address, Counter: balance.Counter, Outputs: []json.RawMessage{}}
+	for i, output := range balance.Outputs {
+		if !json.Valid([]byte(output)) {
+			return nil, fmt.Errorf("output %d is not valid JSON: %s", i, output)
+		}
+		decoded.Outputs = append(decoded.Outputs, json.RawMessage(output))
+	}
+	return &decoded, nil
+}
+
+func balance(args []string) error {
+	decoded, err := decodeBalance(os.Stdin)
+	if err != nil {
+		return err
+	}
+	return printJSON(decoded)
+}
+
+func showRecipe(args []string) error {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	var recipe interface{}
+	err = json.Unmarshal(input, &recipe)
+	if err != nil {
+		return fmt.Errorf("invalid recipe query result: %v", err)
+	}
+	return printJSON(recipe)
+}
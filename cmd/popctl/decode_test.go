@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBalance(t *testing.T) {
+	input := `{"Address":"74ded2036e988fc56e3cff77a40c58239591e921","Counter":"92c7","Outputs":["{\"Owners\":null,\"Threshold\":0,\"Data\":\"Test Data\",\"Type\":\"Test Asset\",\"Amount\":10}"]}`
+	decoded, err := decodeBalance(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Outputs) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(decoded.Outputs))
+	}
+	output := struct {
+		Type   string
+		Amount int
+	}{}
+	if err := json.Unmarshal(decoded.Outputs[0], &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.Type != "Test Asset" || output.Amount != 10 {
+		t.Fatalf("unexpected output %+v", output)
+	}
+
+	_, err = decodeBalance(strings.NewReader(`{"Outputs":["not json"]}`))
+	if err == nil {
+		t.Fatal("expected error for invalid output")
+	}
+}
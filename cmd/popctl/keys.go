@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type keyJSON struct {
+	PrivateKey string `json:",omitempty"`
+	PublicKey  string
+	Address    string
+}
+
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	flags.Parse(args)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return err
+	}
+	return printJSON(keyJSON{
+		PrivateKey: hex.EncodeToString(privKey.Serialize()),
+		PublicKey:  hex.EncodeToString(privKey.PubKey().SerializeCompressed()),
+		Address:    client.Address(privKey.PubKey()),
+	})
+}
+
+func address(args []string) error {
+	flags := flag.NewFlagSet("address", flag.ExitOnError)
+	pubKeyHex := flags.String("pubkey", "", "hex encoded public key")
+	privKeyHex := flags.String("privkey", "", "hex encoded private key")
+	flags.Parse(args)
+
+	var pubKey *btcec.PublicKey
+	var err error
+	switch {
+	case *pubKeyHex != "":
+		pubKey, err = parsePubKey(*pubKeyHex)
+	case *privKeyHex != "":
+		var privKey *btcec.PrivateKey
+		privKey, err = parsePrivKey(*privKeyHex)
+		if err == nil {
+			pubKey = privKey.PubKey()
+		}
+	default:
+		err = fmt.Errorf("one of -pubkey or -privkey is required")
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(keyJSON{
+		PublicKey: hex.EncodeToString(pubKey.SerializeCompressed()),
+		Address:   client.Address(pubKey),
+	})
+}
+
+func parsePrivKey(privKeyHex string) (*btcec.PrivateKey, error) {
+	privKeyBytes, err := hex.DecodeString(privKeyHex)
+	if err != nil || len(privKeyBytes) != btcec.PrivKeyBytesLen {
+		return nil, fmt.Errorf("invalid private key (%s)", privKeyHex)
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privKeyBytes)
+	return privKey, nil
+}
+
+func parsePubKey(pubKeyHex string) (*btcec.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key (%s)", pubKeyHex)
+	}
+	pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key (%s): %v", pubKeyHex, err)
+	}
+	return pubKey, nil
+}
+
+// parsePrivKeys parses a comma separated list of hex private keys.
+func parsePrivKeys(list string) ([]*btcec.PrivateKey, error) {
+	keys := []*btcec.PrivateKey{}
+	for _, keyHex := range splitList(list) {
+		key, err := parsePrivKey(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parsePubKeys parses a comma separated list of hex public keys.
+func parsePubKeys(list string) ([]*btcec.PublicKey, error) {
+	keys := []*btcec.PublicKey{}
+	for _, keyHex := range splitList(list) {
+		key, err := parsePubKey(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printJSON(v interface{}) error {
+	jsonBytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, string(jsonBytes))
+	return nil
+}
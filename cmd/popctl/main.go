@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2016 Skuchain,Inc
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Command popctl generates keys, builds signed TuxedoPops transactions and
+// decodes query results.
+//
+// Transactions are printed as the hex argument expected by Invoke. They are
+// signed over the popcode counter passed with -counter, which is the Counter
+// field of the balance query for the popcode.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"keygen", "generate a secp256k1 key pair and its popcode address", keygen},
+	{"address", "derive the popcode address of a public or private key", address},
+	{"create", "build a signed create transaction", create},
+	{"transfer", "build a signed transfer transaction", transfer},
+	{"unitize", "build a signed unitize transaction", unitize},
+	{"multiunitize", "build a signed unitize to several destinations", multiUnitize},
+	{"combine", "build a signed combine transaction", combine},
+	{"multicombine", "build a combine of sources held on several popcodes", multiCombine},
+	{"burn", "build a signed burn transaction", burn},
+	{"swap", "build a swap of outputs between two popcodes", swap},
+	{"hashlock", "build a signed hash time lock of an output", hashLock},
+	{"claim", "build a claim of a hash locked output by its recipient", claim},
+	{"refund", "build a refund of an expired hash locked output", refund},
+	{"timelock", "build a signed time lock or vesting schedule for an output", timeLock},
+	{"recipe", "build a signed recipe registration", recipe},
+	{"recipestatus", "build a signed deprecation or revocation of a recipe version", recipeStatus},
+	{"registertype", "build a signed asset type registration", registerType},
+	{"batch", "combine signed transactions into an atomic batch", batch},
+	{"nextcounter", "advance a popcode counter for later steps of a batch", nextCounter},
+	{"balance", "pretty-print a balance query result read from stdin", balance},
+	{"showrecipe", "pretty-print a recipe query result read from stdin", showRecipe},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: popctl <command> [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun popctl <command> -h for the flags of a command\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			err := cmd.run(os.Args[2:])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "popctl %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,726 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+// listFlag collects every occurrence of a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, " ") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func parseCounter(counterHex string) ([]byte, error) {
+	if counterHex == "" {
+		return nil, fmt.Errorf("-counter is required, use the Counter field of the popcode's balance query")
+	}
+	counter, err := hex.DecodeString(counterHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid counter (%s)", counterHex)
+	}
+	return counter, nil
+}
+
+func parseInts(list string) ([]int, error) {
+	ints := []int{}
+	for _, item := range splitList(list) {
+		i, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number (%s)", item)
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+// clientVersion returns the client Version of the -version flag v.
+func clientVersion(v int) int {
+	if v == Pop.LegacyEncoding {
+		return client.Legacy
+	}
+	return v
+}
+
+func printTX(msg proto.Message, err error) error {
+	if err != nil {
+		return err
+	}
+	hexArg, err := client.Encode(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, hexArg)
+	return nil
+}
+
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the destination popcode")
+	addr := flags.String("address", "", "popcode address receiving the new output")
+	amount := flags.Int("amount", 0, "amount to create")
+	assetType := flags.String("type", "", "asset type")
+	data := flags.String("data", "", "output data")
+	creatorHex := flags.String("creator", "", "hex private key of the creator")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	creator, err := parsePrivKey(*creatorHex)
+	if err != nil {
+		return err
+	}
+	tx := client.Create{Address: *addr, Amount: *amount, Type: *assetType, Data: *data, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, creator))
+}
+
+func transfer(args []string) error {
+	flags := flag.NewFlagSet("transfer", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the popcode")
+	output := flags.Int("output", 0, "index of the output to transfer")
+	threshold := flags.Int("threshold", 0, "number of new owners required to sign, 0 for all")
+	owners := flags.String("owners", "", "comma separated hex public keys of the new owners")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the current owners")
+	data := flags.String("data", "", "output data")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	newOwners, err := parsePubKeys(*owners)
+	if err != nil {
+		return err
+	}
+	prevOwners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	tx := client.Transfer{Output: *output, Threshold: *threshold, Owners: newOwners, Data: *data, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, prevOwners, popcode))
+}
+
+func unitize(args []string) error {
+	flags := flag.NewFlagSet("unitize", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the source popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the source popcode")
+	output := flags.Int("output", 0, "index of the source output")
+	dest := flags.String("dest", "", "destination popcode address")
+	amounts := flags.String("amounts", "", "comma separated amounts to create on the destination")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	data := flags.String("data", "", "output data")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	destAmounts, err := parseInts(*amounts)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	tx := client.Unitize{SourceOutput: *output, DestAddress: *dest, DestAmounts: destAmounts, Data: *data, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, owners, popcode))
+}
+
+func multiUnitize(args []string) error {
+	var dests listFlag
+	flags := flag.NewFlagSet("multiunitize", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the source popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the source popcode")
+	output := flags.Int("output", 0, "index of the source output")
+	flags.Var(&dests, "dest", "address:amount[,amount...] of a destination, may be repeated")
+	keepChange := flags.Bool("change", false, "leave the remainder on the source output")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	data := flags.String("data", "", "output data")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	tx := client.MultiUnitize{SourceOutput: *output, KeepChange: *keepChange, Data: *data, Version: clientVersion(*version)}
+	for _, dest := range dests {
+		parts := strings.SplitN(dest, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid destination (%s), expected address:amounts", dest)
+		}
+		amounts, err := parseInts(parts[1])
+		if err != nil {
+			return err
+		}
+		tx.Destinations = append(tx.Destinations, client.Destination{Address: parts[0], Amounts: amounts})
+	}
+	return printTX(tx.Sign(counter, owners, popcode))
+}
+
+func combine(args []string) error {
+	flags := flag.NewFlagSet("combine", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the popcode")
+	creatorHex := flags.String("creator", "", "hex private key of the creator")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	approverKeys := flags.String("approverkeys", "", "comma separated hex private keys of manufacturers approving the combine")
+	sources := flags.String("sources", "", "comma separated output:amount pairs to consume")
+	amount := flags.Int("amount", 0, "amount to create")
+	recipeName := flags.String("recipe", "", "registered recipe name")
+	recipeVersion := flags.Int("recipeversion", 0, "recipe version to pin, 0 for the latest active version")
+	data := flags.String("data", "", "output data")
+	returnChange := flags.Bool("change", false, "leave units beyond the recipe ratios on the sources instead of requiring exact amounts")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	creator, err := parsePrivKey(*creatorHex)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	tx := client.Combine{Amount: *amount, Recipe: *recipeName, RecipeVersion: *recipeVersion, Data: *data, ReturnChange: *returnChange, Version: clientVersion(*version)}
+	for _, pair := range splitList(*sources) {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid source (%s), expected output:amount", pair)
+		}
+		ints, err := parseInts(parts[0] + "," + parts[1])
+		if err != nil {
+			return err
+		}
+		tx.Sources = append(tx.Sources, client.Source{Output: ints[0], Amount: ints[1]})
+	}
+	approvers, err := parsePrivKeys(*approverKeys)
+	if err != nil {
+		return err
+	}
+	msg, err := tx.Sign(counter, creator, owners, popcode)
+	if err != nil {
+		return err
+	}
+	for _, approver := range approvers {
+		approval, err := tx.Approve(counter, approver)
+		if err != nil {
+			return err
+		}
+		msg.ApprovalSigs = append(msg.ApprovalSigs, approval)
+	}
+	return printTX(msg, nil)
+}
+
+func multiCombine(args []string) error {
+	var sources, signers, counters listFlag
+	flags := flag.NewFlagSet("multicombine", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	flags.Var(&sources, "source", "address:output:amount to consume, may be repeated")
+	flags.Var(&counters, "counter", "hex counter of each source popcode, in the order they first appear in -source")
+	flags.Var(&signers, "signer", "popcodekey[:ownerkey,ownerkey] of each source popcode, in the same order")
+	creatorHex := flags.String("creator", "", "hex private key of the creator")
+	approverKeys := flags.String("approverkeys", "", "comma separated hex private keys of manufacturers approving the combine")
+	dest := flags.String("dest", "", "address of the popcode receiving the products")
+	amount := flags.Int("amount", 0, "amount to create")
+	recipeName := flags.String("recipe", "", "registered recipe name")
+	recipeVersion := flags.Int("recipeversion", 0, "recipe version to pin, 0 for the latest active version")
+	data := flags.String("data", "", "output data")
+	returnChange := flags.Bool("change", false, "leave units beyond the recipe ratios on the sources instead of requiring exact amounts")
+	flags.Parse(args)
+
+	tx := client.MultiCombine{Destination: *dest, Amount: *amount, Recipe: *recipeName, RecipeVersion: *recipeVersion, Data: *data,
+		ReturnChange: *returnChange, Version: clientVersion(*version)}
+	for _, source := range sources {
+		parts := strings.Split(source, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid source (%s), expected address:output:amount", source)
+		}
+		ints, err := parseInts(parts[1] + "," + parts[2])
+		if err != nil {
+			return err
+		}
+		tx.Sources = append(tx.Sources, client.CombineSource{Address: parts[0], Output: ints[0], Amount: ints[1]})
+	}
+	addresses := tx.Addresses()
+	if len(counters) != len(addresses) || len(signers) != len(addresses) {
+		return fmt.Errorf("expected a -counter and a -signer for each of the %d source popcodes", len(addresses))
+	}
+	counterBytes := make([][]byte, len(counters))
+	for i, counterHex := range counters {
+		counter, err := parseCounter(counterHex)
+		if err != nil {
+			return err
+		}
+		counterBytes[i] = counter
+	}
+	combineSigners := []*TuxedoPopsTX.CombineSigner{}
+	for _, signer := range signers {
+		parts := strings.SplitN(signer, ":", 2)
+		popcode, err := parsePrivKey(parts[0])
+		if err != nil {
+			return err
+		}
+		owners := []*btcec.PrivateKey{}
+		if len(parts) == 2 {
+			owners, err = parsePrivKeys(parts[1])
+			if err != nil {
+				return err
+			}
+		}
+		combineSigner, err := tx.SignSource(counterBytes, owners, popcode)
+		if err != nil {
+			return err
+		}
+		combineSigners = append(combineSigners, combineSigner)
+	}
+	creator, err := parsePrivKey(*creatorHex)
+	if err != nil {
+		return err
+	}
+	approvers, err := parsePrivKeys(*approverKeys)
+	if err != nil {
+		return err
+	}
+	msg, err := tx.Sign(counterBytes, creator, combineSigners)
+	if err != nil {
+		return err
+	}
+	for _, approver := range approvers {
+		approval, err := tx.Approve(counterBytes, approver)
+		if err != nil {
+			return err
+		}
+		msg.ApprovalSigs = append(msg.ApprovalSigs, approval)
+	}
+	return printTX(msg, nil)
+}
+
+func burn(args []string) error {
+	flags := flag.NewFlagSet("burn", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the popcode")
+	output := flags.Int("output", 0, "index of the output to burn")
+	amount := flags.Int("amount", 0, "amount to burn")
+	redemption := flags.String("redemption", "", "redemption reference")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	tx := client.Burn{Output: *output, Amount: *amount, Redemption: *redemption, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, owners, popcode))
+}
+
+func recipe(args []string) error {
+	var ingredients, byproducts listFlag
+	flags := flag.NewFlagSet("recipe", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	creatorHex := flags.String("creator", "", "hex private key of the recipe creator")
+	name := flags.String("name", "", "recipe name")
+	recipeVersion := flags.Int("recipeversion", 1, "recipe version, later versions must follow the latest one")
+	createdType := flags.String("created", "", "asset type created by the recipe")
+	flags.Var(&ingredients, "ingredient", "numerator:denominator:type, may be repeated")
+	flags.Var(&byproducts, "byproduct", "numerator:denominator:type per unit created, may be repeated")
+	manufacturers := flags.String("manufacturers", "", "comma separated hex public keys allowed to create with the recipe")
+	threshold := flags.Int("threshold", 0, "number of manufacturers that must sign each combine")
+	flags.Parse(args)
+
+	creator, err := parsePrivKey(*creatorHex)
+	if err != nil {
+		return err
+	}
+	tx := client.Recipe{Name: *name, RecipeVersion: *recipeVersion, CreatedType: *createdType, Threshold: *threshold, Version: clientVersion(*version)}
+	tx.Manufacturers, err = parsePubKeys(*manufacturers)
+	if err != nil {
+		return err
+	}
+	for _, ingredient := range ingredients {
+		parts := strings.SplitN(ingredient, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid ingredient (%s), expected numerator:denominator:type", ingredient)
+		}
+		ratio, err := parseInts(parts[0] + "," + parts[1])
+		if err != nil {
+			return err
+		}
+		tx.Ingredients = append(tx.Ingredients, client.Ingredient{Numerator: ratio[0], Denominator: ratio[1], Type: parts[2]})
+	}
+	for _, byproduct := range byproducts {
+		parts := strings.SplitN(byproduct, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid byproduct (%s), expected numerator:denominator:type", byproduct)
+		}
+		ratio, err := parseInts(parts[0] + "," + parts[1])
+		if err != nil {
+			return err
+		}
+		tx.Byproducts = append(tx.Byproducts, client.Byproduct{Numerator: ratio[0], Denominator: ratio[1], Type: parts[2]})
+	}
+	return printTX(tx.Sign(creator))
+}
+
+func recipeStatus(args []string) error {
+	flags := flag.NewFlagSet("recipestatus", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	creatorHex := flags.String("creator", "", "hex private key of the recipe creator")
+	name := flags.String("name", "", "recipe name")
+	recipeVersion := flags.Int("recipeversion", 1, "recipe version to change")
+	statusName := flags.String("status", "", "deprecated or revoked")
+	flags.Parse(args)
+
+	creator, err := parsePrivKey(*creatorHex)
+	if err != nil {
+		return err
+	}
+	status, err := Pop.ParseRecipeStatus(*statusName)
+	if err != nil {
+		return err
+	}
+	tx := client.RecipeStatus{Name: *name, RecipeVersion: *recipeVersion, Status: status, Version: clientVersion(*version)}
+	return printTX(tx.Sign(creator))
+}
+
+func registerType(args []string) error {
+	flags := flag.NewFlagSet("registertype", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	issuerHex := flags.String("issuer", "", "hex private key of an issuer signing the registration")
+	name := flags.String("name", "", "asset type")
+	issuerKeys := flags.String("issuers", "", "comma separated hex public keys allowed to create the type")
+	displayName := flags.String("display", "", "display name")
+	unit := flags.String("unit", "", "unit of measure")
+	decimals := flags.Int("decimals", 0, "decimal places of one unit")
+	metadataHex := flags.String("metadata", "", "hex hash of the type's metadata document")
+	revision := flags.Int("revision", 1, "1 to register the type, one more than the current revision to replace it")
+	supplyCap := flags.Int64("cap", 0, "most units of the type that can ever be minted, 0 for no limit")
+	flags.Parse(args)
+
+	issuer, err := parsePrivKey(*issuerHex)
+	if err != nil {
+		return err
+	}
+	metadataHash, err := hex.DecodeString(*metadataHex)
+	if err != nil {
+		return fmt.Errorf("invalid metadata hash (%s)", *metadataHex)
+	}
+	tx := client.RegisterType{Name: *name, DisplayName: *displayName, Unit: *unit, Decimals: *decimals, MetadataHash: metadataHash,
+		Revision: *revision, Cap: *supplyCap, Version: clientVersion(*version)}
+	tx.Issuers, err = parsePubKeys(*issuerKeys)
+	if err != nil {
+		return err
+	}
+	return printTX(tx.Sign(issuer))
+}
+
+func batch(args []string) error {
+	var steps listFlag
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	flags.Var(&steps, "step", "function:hex of a signed transaction, may be repeated, run in order")
+	flags.Parse(args)
+
+	b := client.Batch{}
+	for _, step := range steps {
+		parts := strings.SplitN(step, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid step (%s), expected function:hex", step)
+		}
+		stepArgs, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid step (%s), expected hex transaction", step)
+		}
+		b.Steps = append(b.Steps, &TuxedoPopsTX.BatchStep{Function: parts[0], Args: stepArgs})
+	}
+	if len(b.Steps) == 0 {
+		return fmt.Errorf("at least one -step is required")
+	}
+	hexArg, err := b.Encode()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, hexArg)
+	return nil
+}
+
+func nextCounter(args []string) error {
+	flags := flag.NewFlagSet("nextcounter", flag.ExitOnError)
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	n := flags.Int("n", 1, "number of steps to advance")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < *n; i++ {
+		counter = client.NextCounter(counter)
+	}
+	fmt.Fprintln(os.Stdout, hex.EncodeToString(counter))
+	return nil
+}
+
+// swapSideFlags are the flags describing one side of a swap.
+type swapSideFlags struct {
+	counter, address, popcode, ownerKeys, signed *string
+	output, amount                               *int
+}
+
+func newSwapSideFlags(flags *flag.FlagSet, side string) swapSideFlags {
+	return swapSideFlags{
+		counter:   flags.String("counter"+side, "", "hex counter of popcode "+side),
+		address:   flags.String("address"+side, "", "address of popcode "+side+", derived from -popcode"+side+" if set"),
+		popcode:   flags.String("popcode"+side, "", "hex private key of popcode "+side+", omit to use -signed"+side),
+		ownerKeys: flags.String("ownerkeys"+side, "", "comma separated hex private keys of the owners of side "+side),
+		signed:    flags.String("signed"+side, "", "hex side "+side+" already signed by its popcode"),
+		output:    flags.Int("output"+side, 0, "index of the output given by side "+side),
+		amount:    flags.Int("amount"+side, 0, "amount given by side "+side),
+	}
+}
+
+func swap(args []string) error {
+	flags := flag.NewFlagSet("swap", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	data := flags.String("data", "", "output data")
+	a := newSwapSideFlags(flags, "a")
+	b := newSwapSideFlags(flags, "b")
+	flags.Parse(args)
+
+	counterA, err := parseCounter(*a.counter)
+	if err != nil {
+		return err
+	}
+	counterB, err := parseCounter(*b.counter)
+	if err != nil {
+		return err
+	}
+	tx := client.Swap{Data: *data, Version: clientVersion(*version)}
+	sides := []*TuxedoPopsTX.SwapSide{nil, nil}
+	for i, side := range []swapSideFlags{a, b} {
+		txSide := client.SwapSide{Address: *side.address, Output: *side.output, Amount: *side.amount}
+		if *side.popcode != "" {
+			popcode, err := parsePrivKey(*side.popcode)
+			if err != nil {
+				return err
+			}
+			txSide.Address = client.Address(popcode.PubKey())
+		}
+		if i == 0 {
+			tx.A = txSide
+		} else {
+			tx.B = txSide
+		}
+	}
+	for i, side := range []swapSideFlags{a, b} {
+		if *side.popcode == "" {
+			if *side.signed == "" {
+				continue
+			}
+			signedBytes, err := hex.DecodeString(*side.signed)
+			if err != nil {
+				return fmt.Errorf("invalid signed side (%s) expected hex", *side.signed)
+			}
+			sides[i] = &TuxedoPopsTX.SwapSide{}
+			err = proto.Unmarshal(signedBytes, sides[i])
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		popcode, err := parsePrivKey(*side.popcode)
+		if err != nil {
+			return err
+		}
+		owners, err := parsePrivKeys(*side.ownerKeys)
+		if err != nil {
+			return err
+		}
+		sides[i], err = tx.SignSide(counterA, counterB, owners, popcode)
+		if err != nil {
+			return err
+		}
+	}
+	// with a single side signed print it for the other party to complete
+	if sides[0] == nil || sides[1] == nil {
+		if sides[0] == nil && sides[1] == nil {
+			return fmt.Errorf("sign at least one side with -popcodea or -popcodeb")
+		}
+		if sides[0] != nil {
+			return printTX(sides[0], nil)
+		}
+		return printTX(sides[1], nil)
+	}
+	return printTX(tx.Build(sides[0], sides[1]), nil)
+}
+
+func hashLock(args []string) error {
+	flags := flag.NewFlagSet("hashlock", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the popcode")
+	output := flags.Int("output", 0, "index of the output to lock")
+	amount := flags.Int("amount", 0, "amount to lock")
+	hashHex := flags.String("hash", "", "hex sha256 hash of the preimage")
+	preimage := flags.String("preimage", "", "preimage to hash instead of -hash")
+	expiry := flags.Int64("expiry", 0, "unix time after which the lock can be refunded")
+	recipientHex := flags.String("recipient", "", "hex public key that can claim the output")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	recipient, err := parsePubKey(*recipientHex)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	hash, err := hex.DecodeString(*hashHex)
+	if err != nil {
+		return fmt.Errorf("invalid hash (%s) expected hex", *hashHex)
+	}
+	if *preimage != "" {
+		digest := sha256.Sum256([]byte(*preimage))
+		hash = digest[:]
+	}
+	tx := client.HashLock{Output: *output, Amount: *amount, Hash: hash, Expiry: *expiry, Recipient: recipient, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, owners, popcode))
+}
+
+func claim(args []string) error {
+	flags := flag.NewFlagSet("claim", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	addr := flags.String("address", "", "address of the popcode holding the locked output")
+	output := flags.Int("output", 0, "index of the locked output")
+	preimage := flags.String("preimage", "", "preimage of the hash lock")
+	recipientHex := flags.String("recipient", "", "hex private key of the recipient")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	recipient, err := parsePrivKey(*recipientHex)
+	if err != nil {
+		return err
+	}
+	tx := client.Claim{Address: *addr, Output: *output, Preimage: []byte(*preimage), Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, recipient))
+}
+
+func refund(args []string) error {
+	flags := flag.NewFlagSet("refund", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the popcode")
+	output := flags.Int("output", 0, "index of the expired locked output")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	tx := client.Refund{Output: *output, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, owners, popcode))
+}
+
+func timeLock(args []string) error {
+	flags := flag.NewFlagSet("timelock", flag.ExitOnError)
+	version := flags.Int("version", Pop.CanonicalEncoding, "signing version, 0 legacy or 1 canonical")
+	counterHex := flags.String("counter", "", "hex counter of the popcode")
+	popcodeHex := flags.String("popcode", "", "hex private key of the popcode")
+	output := flags.Int("output", 0, "index of the output to lock")
+	amount := flags.Int("amount", 0, "amount to lock")
+	start := flags.Int64("start", 0, "unix time at which vesting starts, defaults to -end")
+	end := flags.Int64("end", 0, "unix time at which everything has vested")
+	period := flags.Int64("period", 0, "seconds between vesting steps, 0 vests continuously")
+	ownerKeys := flags.String("ownerkeys", "", "comma separated hex private keys of the owners")
+	flags.Parse(args)
+
+	counter, err := parseCounter(*counterHex)
+	if err != nil {
+		return err
+	}
+	popcode, err := parsePrivKey(*popcodeHex)
+	if err != nil {
+		return err
+	}
+	owners, err := parsePrivKeys(*ownerKeys)
+	if err != nil {
+		return err
+	}
+	if *start == 0 {
+		*start = *end
+	}
+	tx := client.TimeLock{Output: *output, Amount: *amount, Start: *start, End: *end, Period: *period, Version: clientVersion(*version)}
+	return printTX(tx.Sign(counter, owners, popcode))
+}
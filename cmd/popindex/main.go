@@ -0,0 +1,140 @@
+/*
+Copyright (c) 2016 Skuchain,Inc
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Command popindex keeps the indexes of package indexer up to date from the
+// chaincode's events and serves them over HTTP.
+//
+// Events come either from the event hub of a peer, given with -peer and
+// -chaincode, or from an event log in the form read by popreplay, given with
+// -events ("-" for stdin). An event log is read from its first event, and the
+// events already indexed are skipped, so the same log can be passed again
+// after a restart. The events ingested are appended to the -data log, from
+// which the indexes are rebuilt at start.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric/events/consumer"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/skuchain/TuxedoPops/indexer"
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+func main() {
+	data := flag.String("data", "popindex.log", "event log the index is kept in")
+	listen := flag.String("listen", ":8080", "address to serve the HTTP API on")
+	events := flag.String("events", "", "event log to ingest, - for stdin")
+	peer := flag.String("peer", "", "address of the peer event hub to ingest events from")
+	chaincode := flag.String("chaincode", "", "name of the chaincode whose events are ingested from the peer")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: popindex [-data file] [-listen addr] (-events file | -peer addr -chaincode name)\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 || (*events == "") == (*peer == "") || (*peer != "" && *chaincode == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ix, err := indexer.Open(*data)
+	if err != nil {
+		log.Fatalf("popindex: %v", err)
+	}
+	defer ix.Close()
+	log.Printf("popindex: %d events in %s", ix.Events(), *data)
+
+	if *events != "" {
+		go func() {
+			err := ingestLog(ix, *events)
+			if err != nil {
+				log.Printf("popindex: %v", err)
+				return
+			}
+			log.Printf("popindex: %d events indexed, end of %s", ix.Events(), *events)
+		}()
+	} else {
+		adapter := &adapter{ix: ix, chaincode: *chaincode}
+		client, err := consumer.NewEventsClient(*peer, 5*time.Second, adapter)
+		if err != nil {
+			log.Fatalf("popindex: %v", err)
+		}
+		err = client.Start()
+		if err != nil {
+			log.Fatalf("popindex: could not connect to %s: %v", *peer, err)
+		}
+		defer client.Stop()
+	}
+	log.Fatal(http.ListenAndServe(*listen, ix.Handler()))
+}
+
+// ingestLog ingests the events of an event log after those already indexed.
+func ingestLog(ix *indexer.Indexer, name string) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	events := replay.NewLogReader(r)
+	for read := 0; ; read++ {
+		event, err := events.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if read < ix.Events() {
+			continue
+		}
+		err = ix.Ingest(event)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// adapter ingests the events of a chaincode from the event hub.
+type adapter struct {
+	ix        *indexer.Indexer
+	chaincode string
+}
+
+func (a *adapter) GetInterestedEvents() ([]*pb.Interest, error) {
+	return []*pb.Interest{{EventType: pb.EventType_CHAINCODE,
+		RegInfo: &pb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &pb.ChaincodeReg{ChaincodeID: a.chaincode, EventName: ""}}}}, nil
+}
+
+func (a *adapter) Recv(msg *pb.Event) (bool, error) {
+	event, ok := msg.Event.(*pb.Event_ChaincodeEvent)
+	if !ok || event.ChaincodeEvent == nil {
+		return true, nil
+	}
+	err := a.ix.Ingest(replay.Event{Name: event.ChaincodeEvent.EventName, Payload: event.ChaincodeEvent.Payload,
+		TxID: event.ChaincodeEvent.TxID})
+	if err != nil {
+		log.Printf("popindex: %v", err)
+	}
+	return true, nil
+}
+
+func (a *adapter) Disconnected(err error) {
+	log.Fatalf("popindex: disconnected from the event hub: %v", err)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2016 Skuchain,Inc
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Command popreplay rebuilds the popcodes of a ledger from its event log.
+//
+// The log is read from the file named by the last argument, or from stdin, as
+// a stream of JSON objects with the Name of each event and its Payload in hex.
+// Without -snapshot the replayed popcodes are printed one per line in the form
+// of the balance query. With -snapshot, a file of balance query results, every
+// difference from it is printed and the exit status is 1 if there are any.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+func main() {
+	snapshotFile := flag.String("snapshot", "", "file of balance query results to compare the replayed popcodes with")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: popreplay [-snapshot file] [events.json]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	err := run(*snapshotFile, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "popreplay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(snapshotFile string, logFile string) error {
+	var log io.Reader = os.Stdin
+	if logFile != "" {
+		f, err := os.Open(logFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log = f
+	}
+	events, err := replay.ReadLog(log)
+	if err != nil {
+		return err
+	}
+	state, err := replay.Replay(events)
+	if err != nil {
+		return err
+	}
+
+	if snapshotFile == "" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, popcode := range state.Balances() {
+			err = encoder.Encode(popcode)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	f, err := os.Open(snapshotFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	snapshot, err := replay.ReadSnapshot(f)
+	if err != nil {
+		return err
+	}
+	divergences := state.Diff(snapshot)
+	for _, divergence := range divergences {
+		fmt.Println(divergence)
+	}
+	if len(divergences) > 0 {
+		return fmt.Errorf("%d popcodes replayed from %d events diverge from the snapshot in %d places",
+			len(state.Popcodes), len(events), len(divergences))
+	}
+	fmt.Printf("%d popcodes replayed from %d events match the snapshot\n", len(state.Popcodes), len(events))
+	return nil
+}
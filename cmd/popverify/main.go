@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2016 Skuchain,Inc
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Command popverify checks a provenance bundle returned by the proof query
+// without access to the ledger.
+//
+// The bundle is read from the file named by the first argument, or from stdin
+// when there is none. On success the origins of the output are printed and
+// the exit status is 0.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/skuchain/TuxedoPops/proof"
+)
+
+func main() {
+	if len(os.Args) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: popverify [bundle.json]\n")
+		os.Exit(2)
+	}
+	var input []byte
+	var err error
+	if len(os.Args) == 2 {
+		input, err = ioutil.ReadFile(os.Args[1])
+	} else {
+		input, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "popverify: %v\n", err)
+		os.Exit(1)
+	}
+	bundle := proof.Bundle{}
+	err = json.Unmarshal(input, &bundle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "popverify: invalid bundle: %v\n", err)
+		os.Exit(1)
+	}
+	origins, err := proof.Verify(&bundle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "popverify: %s does not verify: %v\n", bundle.Counter, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s verified through %d records\n", bundle.Counter, len(bundle.Records))
+	for _, origin := range origins {
+		fmt.Printf("origin %s\n", origin)
+	}
+}
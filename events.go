@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+)
+
+// envelopeVersion is the version of the TxEvents.Envelope emitted.
+const envelopeVersion = 1
+
+// eventTypes names the payload message of each event.
+var eventTypes = map[string]string{
+	"create":       "CreateEvent",
+	"transfer":     "TransferEvent",
+	"unitize":      "UnitizeEvent",
+	"combine":      "CombineEvent",
+	"multiCombine": "MultiCombineEvent",
+	"multiUnitize": "MultiUnitizeEvent",
+	"burn":         "BurnEvent",
+	"recipe":       "RecipeEvent",
+	"recipeStatus": "RecipeStatusEvent",
+	"registerType": "RegisterTypeEvent",
+	"swap":         "SwapEvent",
+	"hashLock":     "HashLockEvent",
+	"claim":        "ClaimEvent",
+	"refund":       "RefundEvent",
+	"timeLock":     "TimeLockEvent",
+	"batch":        "BatchEvent",
+}
+
+// envelopeStub holds the event a transaction sets so that Invoke can emit it
+// in an envelope once the transaction has succeeded.
+type envelopeStub struct {
+	shim.ChaincodeStubInterface
+	name    string
+	payload []byte
+}
+
+func (e *envelopeStub) SetEvent(name string, payload []byte) error {
+	e.name = name
+	e.payload = payload
+	return nil
+}
+
+// The number of envelopes emitted so far is kept under EventSequence.
+func getEventSequence(stub shim.ChaincodeStubInterface) (uint64, error) {
+	sequenceBytes, err := stub.GetState("EventSequence")
+	if err != nil {
+		fmt.Println("Could not get EventSequence State")
+		return 0, fmt.Errorf("Could not get EventSequence State")
+	}
+	if len(sequenceBytes) == 0 {
+		return 0, nil
+	}
+	sequence, err := strconv.ParseUint(string(sequenceBytes), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Could not deserialize EventSequence")
+	}
+	return sequence, nil
+}
+
+// emitEnvelope sets the event held by es, wrapped in an envelope with the
+// next sequence number.
+func emitEnvelope(stub shim.ChaincodeStubInterface, function string, es *envelopeStub) error {
+	if es.name == "" {
+		return nil
+	}
+	sequence, err := getEventSequence(stub)
+	if err != nil {
+		return err
+	}
+	sequence++
+	err = stub.PutState("EventSequence", []byte(strconv.FormatUint(sequence, 10)))
+	if err != nil {
+		fmt.Printf("error putting event sequence to ledger: (%s)\n", err.Error())
+		return fmt.Errorf("error putting event sequence to ledger: (%s)\n", err.Error())
+	}
+
+	envelope := TxEvents.Envelope{}
+	envelope.Version = envelopeVersion
+	envelope.TxID = stub.GetTxID()
+	// without a transaction timestamp the envelope has none
+	envelope.Timestamp, _ = txTime(stub)
+	envelope.Function = function
+	envelope.Sequence = sequence
+	envelope.Type = eventTypes[es.name]
+	envelope.Payload = es.payload
+	envelopeBytes, err := proto.Marshal(&envelope)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent(es.name, envelopeBytes)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+// invokeEnvelope runs an invoke transaction and returns the envelope of the
+// one event it emits.
+func invokeEnvelope(t *testing.T, stub *shim.MockStub, function string, arg string) *TxEvents.Envelope {
+	envelopes, err := invokeWithEnvelopes(stub, function, []string{arg})
+	if err != nil {
+		HandleError(t, fmt.Errorf("%s failed: %s", function, err.Error()))
+		t.FailNow()
+	}
+	if len(envelopes) != 1 || envelopes[function] == nil {
+		HandleError(t, fmt.Errorf("%s emitted %d events", function, len(envelopes)))
+		t.FailNow()
+	}
+	envelope := TxEvents.Envelope{}
+	err = proto.Unmarshal(envelopes[function], &envelope)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	return &envelope
+}
+
+func TestEnvelopes(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+	now := int64(1000)
+	defer setTxTime(&now)()
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	manufacturer, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	typeHex, _ := c.RegisterType(client.RegisterType{Name: "Flour", Issuers: []*btcec.PublicKey{creator.PubKey()},
+		Unit: "kg", Revision: 1}, creator)
+	envelope := invokeEnvelope(t, stub, "registerType", typeHex)
+	if envelope.Version != 1 || envelope.Sequence != 1 || envelope.Function != "registerType" || envelope.Type != "RegisterTypeEvent" ||
+		envelope.TxID != "1" || envelope.Timestamp != now {
+		HandleError(t, fmt.Errorf("unexpected envelope %v", envelope))
+	}
+	typeEvent := TxEvents.RegisterTypeEvent{}
+	proto.Unmarshal(envelope.Payload, &typeEvent)
+	if typeEvent.Name != "Flour" || typeEvent.Unit != "kg" || typeEvent.Revision != 1 || len(typeEvent.Issuers) != 1 {
+		HandleError(t, fmt.Errorf("unexpected type event %v", typeEvent))
+	}
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{
+		{Numerator: 2, Denominator: 1, Type: "Flour"}}, Byproducts: []client.Byproduct{{Numerator: 1, Denominator: 2, Type: "Crumbs"}},
+		Manufacturers: []*btcec.PublicKey{manufacturer.PubKey()}, Threshold: 1}, creator)
+	envelope = invokeEnvelope(t, stub, "recipe", recipeHex)
+	if envelope.Sequence != 2 || envelope.Type != "RecipeEvent" {
+		HandleError(t, fmt.Errorf("unexpected envelope %v", envelope))
+	}
+	recipeEvent := TxEvents.RecipeEvent{}
+	proto.Unmarshal(envelope.Payload, &recipeEvent)
+	if recipeEvent.RecipeName != "Loaf" || recipeEvent.RecipeVersion != 1 || recipeEvent.CreatedType != "Bread" ||
+		len(recipeEvent.Ingredients) != 1 || recipeEvent.Ingredients[0].Numerator != 2 || recipeEvent.Ingredients[0].Type != "Flour" ||
+		len(recipeEvent.Byproducts) != 1 || recipeEvent.Byproducts[0].Denominator != 2 ||
+		len(recipeEvent.Manufacturers) != 1 || recipeEvent.Threshold != 1 || len(recipeEvent.CreatorPubKey) == 0 {
+		HandleError(t, fmt.Errorf("unexpected recipe event %v", recipeEvent))
+	}
+
+	statusHex, _ := c.RecipeStatus(client.RecipeStatus{Name: "Loaf", RecipeVersion: 1, Status: Pop.RecipeDeprecated}, creator)
+	envelope = invokeEnvelope(t, stub, "recipeStatus", statusHex)
+	statusEvent := TxEvents.RecipeStatusEvent{}
+	proto.Unmarshal(envelope.Payload, &statusEvent)
+	if envelope.Sequence != 3 || envelope.Type != "RecipeStatusEvent" || statusEvent.RecipeName != "Loaf" ||
+		statusEvent.RecipeVersion != 1 || statusEvent.Status != Pop.RecipeDeprecated {
+		HandleError(t, fmt.Errorf("unexpected envelope %v of %v", envelope, statusEvent))
+	}
+
+	// a failed transaction emits nothing and takes no sequence number
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Flour"}, manufacturer)
+	if envelopes, err := invokeWithEnvelopes(stub, "create", []string{createHex}); err == nil || len(envelopes) != 0 {
+		HandleError(t, fmt.Errorf("create by a key that is not an issuer emitted %d events", len(envelopes)))
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 10, Type: "Flour"}, creator)
+	envelope = invokeEnvelope(t, stub, "create", createHex)
+	if envelope.Sequence != 4 || envelope.Type != "CreateEvent" {
+		HandleError(t, fmt.Errorf("unexpected envelope %v", envelope))
+	}
+
+	// a batch is one transaction with one envelope around its steps' events
+	counter, _ := c.Counter(address)
+	transfer, _ := client.Transfer{Output: 0, Owners: []*btcec.PublicKey{manufacturer.PubKey()}}.Sign(counter, nil, popcode)
+	burn, _ := client.Burn{Output: 0, Amount: 1}.Sign(client.NextCounter(counter), []*btcec.PrivateKey{manufacturer}, popcode)
+	batch := client.Batch{}
+	batch.Add("transfer", transfer)
+	batch.Add("burn", burn)
+	batchHex, _ := batch.Encode()
+	envelope = invokeEnvelope(t, stub, "batch", batchHex)
+	batchEvent := TxEvents.BatchEvent{}
+	proto.Unmarshal(envelope.Payload, &batchEvent)
+	if envelope.Sequence != 5 || envelope.Type != "BatchEvent" || len(batchEvent.Steps) != 2 || batchEvent.Steps[1].Name != "burn" {
+		HandleError(t, fmt.Errorf("unexpected envelope %v of %v", envelope, batchEvent))
+	}
+
+	// transactions through the plain mock stub still count
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 3, Type: "Flour"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 3, Type: "Flour"}, creator)
+	if envelope = invokeEnvelope(t, stub, "create", createHex); envelope.Sequence != 7 {
+		HandleError(t, fmt.Errorf("expected sequence 7, got %d", envelope.Sequence))
+	}
+}
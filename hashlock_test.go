@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+// setTxTime makes every transaction see *now as its timestamp until the
+// returned function restores the real clock.
+func setTxTime(now *int64) func() {
+	realTxTime := txTime
+	txTime = func(stub shim.ChaincodeStubInterface) (int64, error) {
+		return *now, nil
+	}
+	return func() { txTime = realTxTime }
+}
+
+func TestHashLock(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	recipient, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+	owners := []*btcec.PrivateKey{owner}
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Gold"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	secret := []byte("settled on the other ledger")
+	hash := sha256.Sum256(secret)
+	lock := client.HashLock{Output: 0, Amount: 4, Hash: hash[:], Expiry: 2000, Recipient: recipient.PubKey()}
+
+	// MockStub has no transaction timestamp
+	lockHex, _ := c.HashLock(lock, owners, popcode)
+	if _, err := stub.MockInvoke("1", "hashLock", []string{lockHex}); err == nil {
+		HandleError(t, fmt.Errorf("hash lock without a transaction timestamp was accepted"))
+	}
+
+	now := int64(1000)
+	defer setTxTime(&now)()
+
+	events, err := invokeWithEvents(stub, "hashLock", []string{lockHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	lockEvent := TxEvents.HashLockEvent{}
+	proto.Unmarshal(events["hashLock"], &lockEvent)
+	if lockEvent.LockedOutput != 1 || lockEvent.Amount != 4 || lockEvent.Type != "Gold" {
+		HandleError(t, fmt.Errorf("unexpected hash lock event %v", lockEvent))
+	}
+	balance := getBalance(t, stub, &keyInfo{address: address})
+	if len(balance.Outputs) != 2 || balance.Outputs[0].Amount != 6 || balance.Outputs[0].HashLock != nil ||
+		balance.Outputs[1].Amount != 4 || balance.Outputs[1].HashLock == nil || balance.Outputs[1].HashLock.Expiry != 2000 {
+		HandleError(t, fmt.Errorf("unexpected balance after hash lock %v", balance))
+	}
+
+	// locked outputs can not be spent normally
+	burnHex, _ := c.Burn(client.Burn{Output: 1, Amount: 4}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err == nil {
+		HandleError(t, fmt.Errorf("burn of a hash locked output was accepted"))
+	}
+	refundHex, _ := c.Refund(client.Refund{Output: 1}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "refund", []string{refundHex}); err == nil {
+		HandleError(t, fmt.Errorf("refund before expiry was accepted"))
+	}
+	claimHex, _ := c.Claim(client.Claim{Address: address, Output: 1, Preimage: []byte("guess")}, recipient)
+	if _, err := stub.MockInvoke("1", "claim", []string{claimHex}); err == nil {
+		HandleError(t, fmt.Errorf("claim with the wrong preimage was accepted"))
+	}
+	claimHex, _ = c.Claim(client.Claim{Address: address, Output: 1, Preimage: secret}, owner)
+	if _, err := stub.MockInvoke("1", "claim", []string{claimHex}); err == nil {
+		HandleError(t, fmt.Errorf("claim by someone other than the recipient was accepted"))
+	}
+
+	claimHex, _ = c.Claim(client.Claim{Address: address, Output: 1, Preimage: secret}, recipient)
+	events, err = invokeWithEvents(stub, "claim", []string{claimHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	claimEvent := TxEvents.ClaimEvent{}
+	proto.Unmarshal(events["claim"], &claimEvent)
+	if string(claimEvent.Preimage) != string(secret) || claimEvent.Amount != 4 {
+		HandleError(t, fmt.Errorf("unexpected claim event %v", claimEvent))
+	}
+	balance = getBalance(t, stub, &keyInfo{address: address})
+	if len(balance.Outputs) != 2 || balance.Outputs[1].HashLock != nil || len(balance.Outputs[1].Owners) != 1 ||
+		balance.Outputs[1].Owners[0] != fmt.Sprintf("%x", recipient.PubKey().SerializeCompressed()) {
+		HandleError(t, fmt.Errorf("claim did not hand the output to the recipient %v", balance))
+	}
+
+	// once expired the lock can only be refunded
+	lock = client.HashLock{Output: 0, Amount: 6, Hash: hash[:], Expiry: 1500, Recipient: recipient.PubKey(), Version: 1}
+	lockHex, _ = c.HashLock(lock, owners, popcode)
+	if _, err := stub.MockInvoke("1", "hashLock", []string{lockHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	now = 1500
+	claimHex, _ = c.Claim(client.Claim{Address: address, Output: 0, Preimage: secret}, recipient)
+	if _, err := stub.MockInvoke("1", "claim", []string{claimHex}); err == nil {
+		HandleError(t, fmt.Errorf("claim after expiry was accepted"))
+	}
+	refundHex, _ = c.Refund(client.Refund{Output: 0}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "refund", []string{refundHex}); err == nil {
+		HandleError(t, fmt.Errorf("refund without the owner signature was accepted"))
+	}
+	refundHex, _ = c.Refund(client.Refund{Output: 0}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "refund", []string{refundHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	burnHex, _ = c.Burn(client.Burn{Output: 0, Amount: 6}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, fmt.Errorf("refunded output can not be spent by its owners: %v", err))
+	}
+}
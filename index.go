@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/OTX"
+	"github.com/skuchain/TuxedoPops/Pop"
+)
+
+// indexKeys returns the index keys of the outputs of p. Every output is
+// indexed under TypeIdx:<type>:<address>:<counter> and, when it has a creator,
+// CreatorIdx:<pubkey>:<address>:<counter>. Owned outputs are also indexed
+// under Owner:<pubkey>:<address>:<counter> once per owner; outputs without
+// owners are controlled by the popcode alone.
+func indexKeys(address string, p *Pop.Pop) map[string]bool {
+	keys := make(map[string]bool)
+	for _, output := range p.Outputs {
+		suffix := ":" + address + ":" + hex.EncodeToString(output.PrevCounter)
+		keys["TypeIdx:"+output.Type+suffix] = true
+		if output.Creator != nil {
+			keys["CreatorIdx:"+hex.EncodeToString(output.Creator.SerializeCompressed())+suffix] = true
+		}
+		for _, owner := range output.Owners {
+			keys["Owner:"+hex.EncodeToString(owner.SerializeCompressed())+suffix] = true
+		}
+	}
+	return keys
+}
+
+// putPopcode stores p at address and updates the indexes for the outputs
+// that were added or removed since it was last stored. Every write of a
+// popcode goes through putPopcode so that the indexes never go stale.
+func putPopcode(stub shim.ChaincodeStubInterface, address string, p *Pop.Pop) error {
+	stored := Pop.Pop{}
+	storedBytes, err := stub.GetState("Popcode:" + address)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return fmt.Errorf("Could not get Popcode State")
+	}
+	if len(storedBytes) > 0 {
+		err = stored.FromBytes(storedBytes)
+		if err != nil {
+			return fmt.Errorf("Could not deserialize Popcode %s", address)
+		}
+		// every signed transaction is over the counter, so one that left it
+		// unchanged could be replayed
+		if bytes.Equal(stored.Counter, p.Counter) {
+			return fmt.Errorf("Popcode %s counter did not change", address)
+		}
+	}
+	oldKeys := indexKeys(address, &stored)
+	newKeys := indexKeys(address, p)
+	for key := range oldKeys {
+		if !newKeys[key] {
+			err = stub.DelState(key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for key := range newKeys {
+		if !oldKeys[key] {
+			err = stub.PutState(key, []byte{1})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return stub.PutState("Popcode:"+address, p.ToBytes())
+}
+
+// JSONIndexedOutput is an output found through one of the indexes.
+type JSONIndexedOutput struct {
+	Address   string
+	Output    int
+	Counter   string
+	Type      string
+	Amount    int
+	Creator   string
+	Owners    []string
+	Threshold int
+}
+
+// indexedOutputs returns a page of the outputs indexed under prefix, which is
+// an index name and key followed by ":". keep filters the outputs whose
+// address and counter match an index key.
+func indexedOutputs(stub shim.ChaincodeStubInterface, prefix string, start string, pageSize int,
+	keep func(output OTX.SecP256k1Output) bool) ([]JSONIndexedOutput, string, error) {
+	outputs := []JSONIndexedOutput{}
+	popcodes := make(map[string]*Pop.Pop)
+	// types can contain ":" so a key under a longer type can share the prefix
+	skip := func(key string) bool {
+		return strings.Count(key[len(prefix):], ":") != 1
+	}
+	next, err := rangePage(stub, prefix, start, pageSize, skip, func(key string, value []byte) error {
+		parts := strings.Split(key[len(prefix):], ":")
+		address, counter := parts[0], parts[1]
+		popcode, ok := popcodes[address]
+		if !ok {
+			popcode = &Pop.Pop{}
+			popcodeBytes, err := stub.GetState("Popcode:" + address)
+			if err != nil {
+				fmt.Println("Could not get Popcode State")
+				return fmt.Errorf("Could not get Popcode State")
+			}
+			err = popcode.FromBytes(popcodeBytes)
+			if err != nil {
+				return fmt.Errorf("Could not deserialize Popcode %s", address)
+			}
+			popcodes[address] = popcode
+		}
+		for idx, output := range popcode.Outputs {
+			if hex.EncodeToString(output.PrevCounter) != counter || !keep(output) {
+				continue
+			}
+			indexed := JSONIndexedOutput{}
+			indexed.Address = address
+			indexed.Output = idx
+			indexed.Counter = counter
+			indexed.Type = output.Type
+			indexed.Amount = output.Amount
+			if output.Creator != nil {
+				indexed.Creator = hex.EncodeToString(output.Creator.SerializeCompressed())
+			}
+			for _, owner := range output.Owners {
+				indexed.Owners = append(indexed.Owners, hex.EncodeToString(owner.SerializeCompressed()))
+			}
+			indexed.Threshold = output.Threshold
+			outputs = append(outputs, indexed)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, "", err
+	}
+	return outputs, next, nil
+}
+
+// parseIndexKey reads a hex public key argument in its compressed form.
+func parseIndexKey(keyHex string) (*btcec.PublicKey, string, error) {
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid key (%s)\n", keyHex)
+	}
+	key, err := btcec.ParsePubKey(keyBytes, btcec.S256())
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid key (%s)\n", keyHex)
+	}
+	return key, hex.EncodeToString(key.SerializeCompressed()), nil
+}
+
+func holdings(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("no argument specified\n")
+	}
+	ownerKey, owner, err := parseIndexKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	outputs, _, err := indexedOutputs(stub, "Owner:"+owner+":", "", 0, func(output OTX.SecP256k1Output) bool {
+		return isOwner(output.Owners, ownerKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type JSONTypeHoldings struct {
+		Total   int64
+		Outputs []JSONIndexedOutput
+	}
+	result := struct {
+		Owner    string
+		Holdings map[string]*JSONTypeHoldings
+	}{Owner: owner, Holdings: make(map[string]*JSONTypeHoldings)}
+	for _, output := range outputs {
+		typeHoldings, ok := result.Holdings[output.Type]
+		if !ok {
+			typeHoldings = &JSONTypeHoldings{}
+			result.Holdings[output.Type] = typeHoldings
+		}
+		typeHoldings.Total += int64(output.Amount)
+		typeHoldings.Outputs = append(typeHoldings.Outputs, output)
+	}
+	return json.Marshal(result)
+}
+
+func isOwner(owners []btcec.PublicKey, key *btcec.PublicKey) bool {
+	for _, owner := range owners {
+		if owner.IsEqual(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputsByType pages through the live outputs of a type. It takes the type
+// and the optional page size and continuation key of a listing query.
+func outputsByType(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("no argument specified\n")
+	}
+	assetType := args[0]
+	pageSize, start, err := parsePage(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	page := struct {
+		Outputs []JSONIndexedOutput
+		Next    string
+	}{}
+	page.Outputs, page.Next, err = indexedOutputs(stub, "TypeIdx:"+assetType+":", start, pageSize, func(output OTX.SecP256k1Output) bool {
+		return output.Type == assetType
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(page)
+}
+
+// outputsByCreator pages through the live outputs minted by a creator key,
+// given as hex, by create or combine.
+func outputsByCreator(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("no argument specified\n")
+	}
+	creatorKey, creator, err := parseIndexKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	pageSize, start, err := parsePage(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	page := struct {
+		Outputs []JSONIndexedOutput
+		Next    string
+	}{}
+	page.Outputs, page.Next, err = indexedOutputs(stub, "CreatorIdx:"+creator+":", start, pageSize, func(output OTX.SecP256k1Output) bool {
+		return output.Creator != nil && output.Creator.IsEqual(creatorKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(page)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type indexedPage struct {
+	Outputs []struct {
+		Address string
+		Output  int
+		Type    string
+		Amount  int
+		Creator string
+	}
+	Next string
+}
+
+func getIndexedPage(t *testing.T, stub *shim.MockStub, function string, args ...string) indexedPage {
+	pageBytes, err := stub.MockQuery(function, args)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	page := indexedPage{}
+	json.Unmarshal(pageBytes, &page)
+	return page
+}
+
+func TestOutputsByType(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+
+	creates := []client.Create{
+		{Address: addressA, Amount: 5, Type: "Apple"},
+		{Address: addressA, Amount: 7, Type: "Apple:Green"},
+		{Address: addressB, Amount: 3, Type: "Apple"},
+		{Address: addressB, Amount: 2, Type: "Pear"},
+	}
+	for _, create := range creates {
+		createHex, _ := c.Create(create, creator)
+		if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+	}
+
+	// types that share a prefix are kept apart
+	page := getIndexedPage(t, stub, "outputsByType", "Apple")
+	if len(page.Outputs) != 2 || page.Next != "" {
+		HandleError(t, fmt.Errorf("unexpected outputs of type Apple %+v", page))
+	}
+	for _, output := range page.Outputs {
+		if output.Type != "Apple" {
+			HandleError(t, fmt.Errorf("output of type %s listed as Apple", output.Type))
+		}
+	}
+	if page = getIndexedPage(t, stub, "outputsByType", "Apple:Green"); len(page.Outputs) != 1 || page.Outputs[0].Amount != 7 {
+		HandleError(t, fmt.Errorf("unexpected outputs of type Apple:Green %+v", page))
+	}
+
+	first := getIndexedPage(t, stub, "outputsByType", "Apple", "1")
+	if len(first.Outputs) != 1 || first.Next == "" {
+		HandleError(t, fmt.Errorf("unexpected first page %+v", first))
+		t.FailNow()
+	}
+	second := getIndexedPage(t, stub, "outputsByType", "Apple", "1", first.Next)
+	if len(second.Outputs) != 1 || second.Next != "" || second.Outputs[0].Address == first.Outputs[0].Address {
+		HandleError(t, fmt.Errorf("unexpected second page %+v", second))
+	}
+
+	// burnt outputs leave the index in the same transaction
+	burnHex, _ := c.Burn(client.Burn{Output: 0, Amount: 3}, nil, popcodeB)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if page = getIndexedPage(t, stub, "outputsByType", "Apple"); len(page.Outputs) != 1 || page.Outputs[0].Address != addressA {
+		HandleError(t, fmt.Errorf("unexpected outputs of type Apple after burn %+v", page))
+	}
+	if page = getIndexedPage(t, stub, "outputsByType", "Plum"); len(page.Outputs) != 0 {
+		HandleError(t, fmt.Errorf("unexpected outputs of an unused type %+v", page))
+	}
+	if _, err := stub.MockQuery("outputsByType", []string{"Apple", "x"}); err == nil {
+		HandleError(t, fmt.Errorf("query with an invalid page size succeeded"))
+	}
+}
+
+func TestOutputsByCreator(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	farmer, _ := btcec.NewPrivateKey(btcec.S256())
+	baker, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+	farmerHex := hex.EncodeToString(farmer.PubKey().SerializeCompressed())
+	bakerHex := hex.EncodeToString(baker.PubKey().SerializeCompressed())
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread",
+		Ingredients: []client.Ingredient{{Numerator: 2, Denominator: 1, Type: "Flour"}}}, baker)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Flour"}, farmer)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if page := getIndexedPage(t, stub, "outputsByCreator", farmerHex); len(page.Outputs) != 1 || page.Outputs[0].Creator != farmerHex {
+		HandleError(t, fmt.Errorf("unexpected outputs of the farmer %+v", page))
+	}
+
+	// combining all the flour moves the popcode's only output to the baker
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 10}}, Amount: 5, Recipe: "Loaf"}, baker, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if page := getIndexedPage(t, stub, "outputsByCreator", farmerHex); len(page.Outputs) != 0 {
+		HandleError(t, fmt.Errorf("consumed outputs still listed for the farmer %+v", page))
+	}
+	page := getIndexedPage(t, stub, "outputsByCreator", bakerHex)
+	if len(page.Outputs) != 1 || page.Outputs[0].Type != "Bread" || page.Outputs[0].Amount != 5 {
+		HandleError(t, fmt.Errorf("unexpected outputs of the baker %+v", page))
+	}
+	if page = getIndexedPage(t, stub, "outputsByType", "Flour"); len(page.Outputs) != 0 {
+		HandleError(t, fmt.Errorf("consumed flour still listed %+v", page))
+	}
+	if _, err := stub.MockQuery("outputsByCreator", []string{"zz"}); err == nil {
+		HandleError(t, fmt.Errorf("query of an invalid creator key succeeded"))
+	}
+}
@@ -0,0 +1,246 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+// Output is an output in the form of the chaincode's index queries.
+type Output struct {
+	Address   string
+	Output    int
+	Counter   string
+	Type      string
+	Amount    int
+	Creator   string
+	Owners    []string
+	Threshold int
+}
+
+// TypeHoldings is what an owner holds of one type.
+type TypeHoldings struct {
+	Total   int64
+	Outputs []Output
+}
+
+// Holdings is the result of the holdings query.
+type Holdings struct {
+	Owner    string
+	Holdings map[string]*TypeHoldings
+}
+
+// Trace is the result of the trace query.
+type Trace struct {
+	Counter string
+	Records []Record
+	Origins []string
+	Unknown []string
+}
+
+// Status is the state of ingestion.
+type Status struct {
+	Events   int
+	Sequence uint64
+	Popcodes int
+	Error    string `json:",omitempty"`
+}
+
+// Popcode returns the current state of the popcode at address.
+func (ix *Indexer) Popcode(address string) (replay.Popcode, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	p, ok := ix.state.Popcodes[address]
+	if !ok {
+		return replay.Popcode{}, false
+	}
+	popcode := replay.Popcode{}
+	json.Unmarshal(p.ToJSON(), &popcode)
+	return popcode, true
+}
+
+// History returns the events that changed the popcode at address, oldest
+// first.
+func (ix *Indexer) History(address string) []Entry {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	history := make([]Entry, len(ix.history[address]))
+	copy(history, ix.history[address])
+	return history
+}
+
+// Holdings returns the live outputs owned by the hex public key keyHex, by
+// type, ordered by popcode address and counter as the chaincode lists them.
+func (ix *Indexer) Holdings(keyHex string) (*Holdings, error) {
+	owner, err := parseOwner(keyHex)
+	if err != nil {
+		return nil, err
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	addresses := []string{}
+	for address := range ix.owners[owner] {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	result := &Holdings{Owner: owner, Holdings: make(map[string]*TypeHoldings)}
+	for _, address := range addresses {
+		p := ix.state.Popcodes[address]
+		outputs := []Output{}
+		for idx, output := range p.Outputs {
+			output := output
+			owned := false
+			for _, key := range output.Owners {
+				owned = owned || hex.EncodeToString(key.SerializeCompressed()) == owner
+			}
+			if !owned {
+				continue
+			}
+			indexed := Output{Address: address, Output: idx, Counter: hex.EncodeToString(output.PrevCounter),
+				Type: output.Type, Amount: output.Amount, Threshold: output.Threshold}
+			if output.Creator != nil {
+				indexed.Creator = hex.EncodeToString(output.Creator.SerializeCompressed())
+			}
+			for _, key := range output.Owners {
+				indexed.Owners = append(indexed.Owners, hex.EncodeToString(key.SerializeCompressed()))
+			}
+			outputs = append(outputs, indexed)
+		}
+		sort.SliceStable(outputs, func(i, j int) bool { return outputs[i].Counter < outputs[j].Counter })
+		for _, output := range outputs {
+			typeHoldings, ok := result.Holdings[output.Type]
+			if !ok {
+				typeHoldings = &TypeHoldings{}
+				result.Holdings[output.Type] = typeHoldings
+			}
+			typeHoldings.Total += int64(output.Amount)
+			typeHoldings.Outputs = append(typeHoldings.Outputs, output)
+		}
+	}
+	return result, nil
+}
+
+// Trace returns the ancestry of the output with counter back to the creates
+// it started from, breadth first from the output.
+func (ix *Indexer) Trace(counter string) (*Trace, error) {
+	counterBytes, err := hex.DecodeString(counter)
+	if err != nil || len(counterBytes) == 0 {
+		return nil, fmt.Errorf("Invalid counter %s", counter)
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	result := &Trace{Counter: counter, Records: []Record{}, Origins: []string{}, Unknown: []string{}}
+	seen := map[string]bool{counter: true}
+	queue := []string{counter}
+	for len(queue) > 0 {
+		counter := queue[0]
+		queue = queue[1:]
+		record, ok := ix.lineage[counter]
+		if !ok {
+			result.Unknown = append(result.Unknown, counter)
+			continue
+		}
+		for _, parent := range record.Parents {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+		if record.Operation == "create" {
+			result.Origins = append(result.Origins, counter)
+		}
+		result.Records = append(result.Records, *record)
+	}
+	return result, nil
+}
+
+// Status returns the number of events ingested, the sequence number of the
+// last one, the number of popcodes known and the error that stopped ingestion
+// if any.
+func (ix *Indexer) Status() Status {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	status := Status{Events: ix.events, Sequence: ix.state.Sequence, Popcodes: len(ix.state.Popcodes)}
+	if ix.err != nil {
+		status.Error = ix.err.Error()
+	}
+	return status
+}
+
+// Handler serves the indexes as JSON:
+//
+//	GET /status
+//	GET /popcodes/{address}
+//	GET /popcodes/{address}/history
+//	GET /owners/{pubkey}/holdings
+//	GET /trace/{counter}
+func (ix *Indexer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ix.Status())
+	})
+	mux.HandleFunc("/popcodes/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/popcodes/"), "/")
+		address := parts[0]
+		switch {
+		case len(parts) == 1 && address != "":
+			popcode, ok := ix.Popcode(address)
+			if !ok {
+				writeError(w, http.StatusNotFound, fmt.Errorf("Unknown popcode %s", address))
+				return
+			}
+			writeJSON(w, http.StatusOK, popcode)
+		case len(parts) == 2 && parts[1] == "history":
+			writeJSON(w, http.StatusOK, ix.History(address))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/owners/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/owners/"), "/")
+		if len(parts) != 2 || parts[1] != "holdings" {
+			http.NotFound(w, r)
+			return
+		}
+		holdings, err := ix.Holdings(parts[0])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, holdings)
+	})
+	mux.HandleFunc("/trace/", func(w http.ResponseWriter, r *http.Request) {
+		trace, err := ix.Trace(strings.TrimPrefix(r.URL.Path, "/trace/"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, trace)
+	})
+	return onlyGet(mux)
+}
+
+func onlyGet(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s not allowed", r.Method))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct{ Error string }{err.Error()})
+}
@@ -0,0 +1,404 @@
+// Package indexer keeps the popcodes of a TuxedoPops ledger, the history of
+// each popcode, the outputs held by each owner and the lineage of every output
+// up to date from the chaincode's events, and serves them over HTTP.
+//
+// The only thing kept on disk is the event log itself, appended to as events
+// are ingested. The indexes are rebuilt from it in memory when the indexer is
+// opened.
+package indexer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+// Record is how one output counter came to be.
+type Record struct {
+	Counter       string
+	Operation     string
+	Address       string
+	Type          string
+	Recipe        string `json:",omitempty"`
+	RecipeVersion int    `json:",omitempty"`
+	Parents       []string
+	Sequence      uint64
+	TxID          string `json:",omitempty"`
+}
+
+// Entry is an event that changed a popcode: the counters of the outputs it
+// took units from and of the outputs it created there.
+type Entry struct {
+	Sequence uint64
+	TxID     string `json:",omitempty"`
+	Event    string
+	Spent    []string
+	Created  []string
+}
+
+// Indexer is safe for concurrent use. Once an event fails to apply the
+// indexer stops ingesting and keeps serving the state before that event.
+type Indexer struct {
+	mu      sync.RWMutex
+	log     *os.File
+	state   *replay.State
+	events  int
+	history map[string][]Entry
+	lineage map[string]*Record
+	// owners maps the compressed hex key of an owner to the addresses of
+	// the popcodes holding its outputs, and holders the reverse
+	owners  map[string]map[string]bool
+	holders map[string][]string
+	err     error
+}
+
+// New returns an indexer that keeps nothing on disk.
+func New() *Indexer {
+	return &Indexer{
+		state:   replay.NewState(),
+		history: make(map[string][]Entry),
+		lineage: make(map[string]*Record),
+		owners:  make(map[string]map[string]bool),
+		holders: make(map[string][]string),
+	}
+}
+
+// Open returns an indexer that appends the events it ingests to the log at
+// path, after rebuilding its indexes from the events already there.
+func Open(path string) (*Indexer, error) {
+	ix := New()
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	events, err := replay.ReadLog(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Could not read event log %s: %s", path, err.Error())
+	}
+	for _, event := range events {
+		err = ix.Ingest(event)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	ix.log = f
+	return ix, nil
+}
+
+// Close closes the event log.
+func (ix *Indexer) Close() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.log == nil {
+		return nil
+	}
+	return ix.log.Close()
+}
+
+// Ingest applies the next event of the ledger to the indexes and appends it
+// to the event log.
+func (ix *Indexer) Ingest(event replay.Event) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.err != nil {
+		return ix.err
+	}
+	envelope, err := replay.Unwrap(event.Name, event.Payload)
+	if err == nil {
+		err = ix.state.Follows(envelope)
+	}
+	if err == nil {
+		// the transaction id given with the event is that of the event hub
+		txID := event.TxID
+		if txID == "" {
+			txID = envelope.TxID
+		}
+		err = ix.apply(event.Name, envelope.Payload, envelope.Sequence, txID)
+	}
+	if err == nil && ix.log != nil {
+		err = replay.WriteEvent(ix.log, event)
+		if err == nil {
+			err = ix.log.Sync()
+		}
+	}
+	if err != nil {
+		ix.err = fmt.Errorf("Event %d (%s): %s", ix.events, event.Name, err.Error())
+		return ix.err
+	}
+	ix.state.Sequence = envelope.Sequence
+	ix.events++
+	return nil
+}
+
+// Events returns the number of events ingested.
+func (ix *Indexer) Events() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.events
+}
+
+// Err returns the error that stopped ingestion, if any.
+func (ix *Indexer) Err() error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.err
+}
+
+func (ix *Indexer) apply(name string, payload []byte, sequence uint64, txID string) error {
+	if name == "batch" {
+		batchEvent := TxEvents.BatchEvent{}
+		err := proto.Unmarshal(payload, &batchEvent)
+		if err != nil {
+			return fmt.Errorf("Invalid batch event: %s", err.Error())
+		}
+		for i, step := range batchEvent.Steps {
+			if step.Name == "batch" {
+				return fmt.Errorf("Batch step %d: batches can not be nested", i)
+			}
+			err = ix.apply(step.Name, step.Payload, sequence, txID)
+			if err != nil {
+				return fmt.Errorf("Batch step %d (%s): %s", i, step.Name, err.Error())
+			}
+		}
+		return nil
+	}
+
+	// lineage is read from the event against the state before it
+	records, spent, err := ix.changes(name, payload)
+	if err != nil {
+		return err
+	}
+	err = ix.state.ApplyEvent(name, payload)
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]*Entry)
+	entry := func(address string) *Entry {
+		if _, ok := entries[address]; !ok {
+			entries[address] = &Entry{Sequence: sequence, TxID: txID, Event: name, Spent: []string{}, Created: []string{}}
+		}
+		return entries[address]
+	}
+	for address, counters := range spent {
+		for _, counter := range counters {
+			entry(address).Spent = append(entry(address).Spent, hex.EncodeToString(counter))
+		}
+	}
+	for _, record := range records {
+		record.Sequence = sequence
+		record.TxID = txID
+		ix.lineage[record.Counter] = record
+		entry(record.Address).Created = append(entry(record.Address).Created, record.Counter)
+	}
+	addresses := []string{}
+	for address := range entries {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		ix.history[address] = append(ix.history[address], *entries[address])
+		ix.indexOwners(address)
+	}
+	return nil
+}
+
+// indexOwners updates the owner index for the popcode at address.
+func (ix *Indexer) indexOwners(address string) {
+	for _, owner := range ix.holders[address] {
+		delete(ix.owners[owner], address)
+	}
+	holders := []string{}
+	seen := make(map[string]bool)
+	if p, ok := ix.state.Popcodes[address]; ok {
+		for _, output := range p.Outputs {
+			for _, key := range output.Owners {
+				owner := hex.EncodeToString(key.SerializeCompressed())
+				if seen[owner] {
+					continue
+				}
+				seen[owner] = true
+				holders = append(holders, owner)
+				if ix.owners[owner] == nil {
+					ix.owners[owner] = make(map[string]bool)
+				}
+				ix.owners[owner][address] = true
+			}
+		}
+	}
+	ix.holders[address] = holders
+}
+
+// changes returns the lineage records of the outputs an event creates and,
+// by popcode, the counters of the outputs it takes units from. Partly spent
+// outputs keep their counter and their record.
+func (ix *Indexer) changes(name string, payload []byte) ([]*Record, map[string][][]byte, error) {
+	records := []*Record{}
+	spent := make(map[string][][]byte)
+	record := func(counter []byte, operation string, address string, assetType string, parents ...[]byte) *Record {
+		r := &Record{Counter: hex.EncodeToString(counter), Operation: operation, Address: address, Type: assetType, Parents: []string{}}
+		for _, parent := range parents {
+			r.Parents = append(r.Parents, hex.EncodeToString(parent))
+		}
+		records = append(records, r)
+		return r
+	}
+	var err error
+	switch name {
+	case "create":
+		event := TxEvents.CreateEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			record(event.DestCounter, name, event.Address, event.Type)
+		}
+	case "transfer":
+		event := TxEvents.TransferEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounter)
+			record(event.DestCounter, name, event.Address, event.Type, event.SourceCounter)
+		}
+	case "unitize":
+		event := TxEvents.UnitizeEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.SourceAddress] = append(spent[event.SourceAddress], event.SourceCounter)
+			// unitize events do not carry the type of the units they move
+			assetType := ix.outputType(event.SourceAddress, event.SourceOutput)
+			for i := len(event.DestCounters) - 1; i >= 0; i-- {
+				record(event.DestCounters[i], name, event.DestAddress, assetType, event.SourceCounter)
+			}
+		}
+	case "multiUnitize":
+		event := TxEvents.MultiUnitizeEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.SourceAddress] = append(spent[event.SourceAddress], event.SourceCounter)
+			for _, destination := range event.Destinations {
+				for _, counter := range destination.DestCounters {
+					record(counter, name, destination.DestAddress, event.Type, event.SourceCounter)
+				}
+			}
+			if event.ChangeCounter != nil {
+				record(event.ChangeCounter, name, event.SourceAddress, event.Type, event.SourceCounter)
+			}
+		}
+	case "combine":
+		event := TxEvents.CombineEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounters...)
+			for _, product := range event.Products {
+				r := record(product.DestCounter, name, event.Address, product.Type, event.SourceCounters...)
+				r.Recipe = event.Recipe
+				r.RecipeVersion = int(event.RecipeVersion)
+			}
+		}
+	case "multiCombine":
+		event := TxEvents.MultiCombineEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			parents := [][]byte{}
+			for _, source := range event.Sources {
+				spent[source.Address] = append(spent[source.Address], source.SourceCounter)
+				parents = append(parents, source.SourceCounter)
+			}
+			for _, product := range event.Products {
+				r := record(product.DestCounter, name, event.Destination, product.Type, parents...)
+				r.Recipe = event.Recipe
+				r.RecipeVersion = int(event.RecipeVersion)
+			}
+		}
+	case "burn":
+		event := TxEvents.BurnEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounter)
+			// what is left of a partly burnt output moves to a new counter
+			if ix.outputAmount(event.Address, event.Output) > int(event.Amount) {
+				record(event.DestCounter, name, event.Address, event.Type, event.SourceCounter)
+			}
+		}
+	case "swap":
+		event := TxEvents.SwapEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			if event.A == nil || event.B == nil {
+				return nil, nil, fmt.Errorf("Swap event without two sides")
+			}
+			spent[event.A.Address] = append(spent[event.A.Address], event.A.SourceCounter)
+			spent[event.B.Address] = append(spent[event.B.Address], event.B.SourceCounter)
+			record(event.A.DestCounter, name, event.B.Address, event.A.Type, event.A.SourceCounter)
+			record(event.B.DestCounter, name, event.A.Address, event.B.Type, event.B.SourceCounter)
+		}
+	case "hashLock":
+		event := TxEvents.HashLockEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounter)
+			record(event.DestCounter, name, event.Address, event.Type, event.SourceCounter)
+		}
+	case "claim":
+		event := TxEvents.ClaimEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounter)
+			record(event.DestCounter, name, event.Address, event.Type, event.SourceCounter)
+		}
+	case "refund":
+		event := TxEvents.RefundEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounter)
+			record(event.DestCounter, name, event.Address, event.Type, event.SourceCounter)
+		}
+	case "timeLock":
+		event := TxEvents.TimeLockEvent{}
+		if err = proto.Unmarshal(payload, &event); err == nil {
+			spent[event.Address] = append(spent[event.Address], event.SourceCounter)
+			record(event.DestCounter, name, event.Address, event.Type, event.SourceCounter)
+		}
+	case "recipe", "recipeStatus", "registerType":
+		// registrations do not change any popcode
+	default:
+		return nil, nil, fmt.Errorf("Unknown event %s", name)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid %s event: %s", name, err.Error())
+	}
+	return records, spent, nil
+}
+
+func (ix *Indexer) output(address string, idx int32) *Pop.Pop {
+	p, ok := ix.state.Popcodes[address]
+	if !ok || idx < 0 || int(idx) >= len(p.Outputs) {
+		return nil
+	}
+	return p
+}
+
+func (ix *Indexer) outputType(address string, idx int32) string {
+	if p := ix.output(address, idx); p != nil {
+		return p.Outputs[idx].Type
+	}
+	return ""
+}
+
+func (ix *Indexer) outputAmount(address string, idx int32) int {
+	if p := ix.output(address, idx); p != nil {
+		return p.Outputs[idx].Amount
+	}
+	return 0
+}
+
+// parseOwner returns the compressed hex form of a hex public key.
+func parseOwner(keyHex string) (string, error) {
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return "", fmt.Errorf("Invalid public key %s", keyHex)
+	}
+	key, err := btcec.ParsePubKey(keyBytes, btcec.S256())
+	if err != nil {
+		return "", fmt.Errorf("Invalid public key %s", keyHex)
+	}
+	return hex.EncodeToString(key.SerializeCompressed()), nil
+}
@@ -0,0 +1,131 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/replay/replaytest"
+)
+
+func TestIngest(t *testing.T) {
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	ownerHex := hex.EncodeToString(owner.PubKey().SerializeCompressed())
+	events, unitized := replaytest.Log(t, creator.PubKey(), owner.PubKey())
+
+	dir, err := ioutil.TempDir("", "indexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dataFile := filepath.Join(dir, "events.log")
+	ix, err := Open(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if err = ix.Ingest(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if status := ix.Status(); status.Events != 3 || status.Sequence != 3 || status.Popcodes != 2 || status.Error != "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if _, ok := ix.Popcode(replaytest.AddressB); !ok {
+		t.Errorf("popcode %s is not indexed", replaytest.AddressB)
+	}
+	history := ix.History(replaytest.AddressA)
+	if len(history) != 3 || history[2].Event != "unitize" || len(history[2].Spent) != 1 || len(history[2].Created) != 0 ||
+		history[1].TxID != "c" {
+		t.Errorf("unexpected history %+v", history)
+	}
+	// the units moved to B keep their owners
+	holdings, err := ix.Holdings(ownerHex)
+	if err != nil || holdings.Holdings["Flour"] == nil || holdings.Holdings["Flour"].Total != 10 ||
+		len(holdings.Holdings["Flour"].Outputs) != 2 {
+		t.Errorf("unexpected holdings %+v: %v", holdings, err)
+	}
+	if _, err = ix.Holdings("zz"); err == nil {
+		t.Errorf("holdings of an invalid key")
+	}
+	trace, err := ix.Trace(hex.EncodeToString(unitized))
+	if err != nil || len(trace.Records) != 3 || len(trace.Origins) != 1 || trace.Records[0].Operation != "unitize" ||
+		trace.Records[0].Type != "Flour" || trace.Records[0].Sequence != 3 {
+		t.Errorf("unexpected trace %+v: %v", trace, err)
+	}
+
+	// an event that does not follow stops ingestion
+	if err = ix.Ingest(events[1]); err == nil {
+		t.Errorf("replayed transfer was ingested")
+	}
+	if err = ix.Ingest(replaytest.Wrap(t, 4, "burn", &TxEvents.BurnEvent{})); err == nil || ix.Err() == nil {
+		t.Errorf("ingestion went on after an error")
+	}
+	ix.Close()
+
+	reopened, err := Open(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Events() != 3 || len(reopened.History(replaytest.AddressB)) != 1 {
+		t.Errorf("reopened index has %d events", reopened.Events())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	ownerHex := hex.EncodeToString(owner.PubKey().SerializeCompressed())
+	events, unitized := replaytest.Log(t, creator.PubKey(), owner.PubKey())
+	ix := New()
+	for _, event := range events {
+		if err := ix.Ingest(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer(ix.Handler())
+	defer server.Close()
+
+	for path, status := range map[string]int{
+		"/status":                          http.StatusOK,
+		"/popcodes/" + replaytest.AddressA: http.StatusOK,
+		"/popcodes/" + replaytest.AddressA + "/history": http.StatusOK,
+		"/popcodes/unknown":                             http.StatusNotFound,
+		"/owners/" + ownerHex + "/holdings":             http.StatusOK,
+		"/owners/zz/holdings":                           http.StatusBadRequest,
+		"/trace/" + hex.EncodeToString(unitized):        http.StatusOK,
+		"/trace/zz":                                     http.StatusBadRequest,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := map[string]interface{}{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("GET %s returned %d, expected %d", path, resp.StatusCode, status)
+		}
+		if status != http.StatusOK && body["Error"] == nil {
+			t.Errorf("GET %s returned no error message", path)
+		}
+	}
+	resp, err := http.Post(server.URL+"/status", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST returned %d", resp.StatusCode)
+	}
+}
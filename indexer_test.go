@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+	"github.com/skuchain/TuxedoPops/indexer"
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+func getIndexed(t *testing.T, server *httptest.Server, path string, status int, v interface{}) {
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		HandleError(t, fmt.Errorf("GET %s returned %d, expected %d", path, resp.StatusCode, status))
+		t.FailNow()
+	}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+}
+
+func TestIndexer(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+	log := &eventLog{t: t, stub: stub}
+
+	miller, _ := btcec.NewPrivateKey(btcec.S256())
+	baker, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+	bakerHex := hex.EncodeToString(baker.PubKey().SerializeCompressed())
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{
+		{Numerator: 2, Denominator: 1, Type: "Flour"}, {Numerator: 1, Denominator: 1, Type: "Water"}}}, baker)
+	log.invoke("recipe", recipeHex)
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 10, Type: "Flour"}, miller)
+	log.invoke("create", createHex)
+	createHex, _ = c.Create(client.Create{Address: addressA, Amount: 5, Type: "Water"}, miller)
+	log.invoke("create", createHex)
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{baker.PubKey()}}, nil, popcodeA)
+	log.invoke("transfer", transferHex)
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: addressB, DestAmounts: []int{4}}, []*btcec.PrivateKey{baker}, popcodeA)
+	log.invoke("unitize", unitizeHex)
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 2}, {Output: 1, Amount: 1}},
+		Amount: 1, Recipe: "Loaf"}, baker, []*btcec.PrivateKey{baker}, popcodeA)
+	log.invoke("combine", combineHex)
+
+	ix := indexer.New()
+	events, _ := replay.ReadLog(bytes.NewReader(log.events.Bytes()))
+	for i, event := range events {
+		event.TxID = fmt.Sprintf("tx%d", i)
+		if err := ix.Ingest(event); err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+	}
+	server := httptest.NewServer(ix.Handler())
+	defer server.Close()
+
+	status := indexer.Status{}
+	getIndexed(t, server, "/status", http.StatusOK, &status)
+	if status.Events != 6 || status.Sequence != 6 || status.Popcodes != 2 || status.Error != "" {
+		HandleError(t, fmt.Errorf("unexpected status %+v", status))
+	}
+
+	// popcodes and holdings are those of the chaincode's queries
+	for _, address := range []string{addressA, addressB} {
+		ledger := replay.Popcode{}
+		balance, _ := stub.MockQuery("balance", []string{address})
+		json.Unmarshal(balance, &ledger)
+		indexed := replay.Popcode{}
+		getIndexed(t, server, "/popcodes/"+address, http.StatusOK, &indexed)
+		if !reflect.DeepEqual(indexed, ledger) {
+			HandleError(t, fmt.Errorf("indexed popcode %+v, ledger has %+v", indexed, ledger))
+		}
+	}
+	ledgerHoldings := indexer.Holdings{}
+	holdingsBytes, _ := stub.MockQuery("holdings", []string{bakerHex})
+	json.Unmarshal(holdingsBytes, &ledgerHoldings)
+	holdings := indexer.Holdings{}
+	getIndexed(t, server, "/owners/"+bakerHex+"/holdings", http.StatusOK, &holdings)
+	if !reflect.DeepEqual(holdings, ledgerHoldings) {
+		HandleError(t, fmt.Errorf("indexed holdings %+v, ledger has %+v", holdings, ledgerHoldings))
+	}
+	if holdings.Holdings["Flour"] == nil || holdings.Holdings["Flour"].Total != 8 || holdings.Holdings["Bread"] != nil {
+		HandleError(t, fmt.Errorf("unexpected holdings %+v", holdings.Holdings))
+	}
+
+	// the bread traces back through the flour to both creates
+	popcode, _ := ix.Popcode(addressA)
+	bread := map[string]interface{}{}
+	json.Unmarshal([]byte(popcode.Outputs[len(popcode.Outputs)-1]), &bread)
+	breadCounter, _ := bread["PrevCounter"].(string)
+	ledgerTrace := indexer.Trace{}
+	traceBytes, _ := stub.MockQuery("trace", []string{breadCounter})
+	json.Unmarshal(traceBytes, &ledgerTrace)
+	trace := indexer.Trace{}
+	getIndexed(t, server, "/trace/"+breadCounter, http.StatusOK, &trace)
+	if len(trace.Records) != 4 || len(trace.Origins) != 2 || len(trace.Unknown) != 0 ||
+		trace.Records[0].TxID != "tx5" || trace.Records[0].Sequence != 6 {
+		HandleError(t, fmt.Errorf("unexpected trace %+v", trace))
+	}
+	for i := range trace.Records {
+		trace.Records[i].Sequence = 0
+		trace.Records[i].TxID = ""
+	}
+	if !reflect.DeepEqual(trace, ledgerTrace) {
+		HandleError(t, fmt.Errorf("indexed trace %+v, ledger has %+v", trace, ledgerTrace))
+	}
+}
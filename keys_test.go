@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+//generates and returns SHA256 private key string
+func newPrivateKeyString() (string, error) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return "", fmt.Errorf("Error generating private key\n")
+	}
+	privKeyBytes := privKey.Serialize()
+	privKeyString := hex.EncodeToString(privKeyBytes)
+	return privKeyString, nil
+}
+
+//generates and returns SHA256 public key string fromessage private key string input
+func newPubKeyString(privKeyString string) (string, error) {
+	privKeyBytes, err := hex.DecodeString(privKeyString)
+	if err != nil {
+		return "", fmt.Errorf("error decoding private key string (%s)", privKeyString)
+	}
+	_, pubKey := btcec.PrivKeyFromBytes(btcec.S256(), privKeyBytes)
+	pubKeyBytes := pubKey.SerializeCompressed()
+	pubkKeyString := hex.EncodeToString(pubKeyBytes)
+	return pubkKeyString, nil
+}
+
+//generates and returns first forty characters of sha256 hash of public key string
+func newAddress(pubKeyStr string) string {
+	pubKeyBytes, err := hex.DecodeString(pubKeyStr)
+	if err != nil {
+		fmt.Printf("error decoding pubkeystring (%s)", pubKeyStr)
+	}
+	hasher := sha256.New()
+	hasher.Write(pubKeyBytes)
+	hashedPubKeyBytes := []byte{}
+	hashedPubKeyBytes = hasher.Sum(hashedPubKeyBytes)
+	hashedPubKeyString := hex.EncodeToString(hashedPubKeyBytes[0:20])
+	address := hashedPubKeyString
+	return address
+}
+
+func generateKeys() (*keyInfo, error) {
+	var err error
+	keys := new(keyInfo)
+	keys.privKeyStr, err = newPrivateKeyString()
+	if err != nil {
+		fmt.Printf("error generating private key: %v", err.Error())
+		return nil, fmt.Errorf("error generating private key: %v", err.Error())
+	}
+	keys.pubKeyStr, err = newPubKeyString(keys.privKeyStr)
+	if err != nil {
+		fmt.Printf("error generating public key: %v", err.Error())
+		return nil, fmt.Errorf("error generating public key: %v", err.Error())
+	}
+	keys.address = newAddress(keys.pubKeyStr)
+	return keys, nil
+}
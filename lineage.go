@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+)
+
+// maxTraceRecords bounds the number of lineage records a trace reads.
+const maxTraceRecords = 5000
+
+// How each output counter came to be is kept under Lineage:<counter>: the
+// counters of the outputs it was made from, the operation, the popcode it
+// was made on and for combines the recipe. Outputs that only lost units keep
+// their counter and their lineage.
+func getLineage(stub shim.ChaincodeStubInterface, counter string) (*TuxedoPopsStore.Lineage, error) {
+	lineageBytes, err := stub.GetState("Lineage:" + counter)
+	if err != nil {
+		fmt.Println("Could not get Lineage State")
+		return nil, errors.New("Could not get Lineage State")
+	}
+	if len(lineageBytes) == 0 {
+		return nil, nil
+	}
+	lineage := TuxedoPopsStore.Lineage{}
+	err = proto.Unmarshal(lineageBytes, &lineage)
+	if err != nil {
+		return nil, fmt.Errorf("Could not deserialize Lineage %s", counter)
+	}
+	return &lineage, nil
+}
+
+// putLineage records lineage for each of counters.
+func putLineage(stub shim.ChaincodeStubInterface, lineage *TuxedoPopsStore.Lineage, counters ...[]byte) error {
+	lineageBytes, err := proto.Marshal(lineage)
+	if err != nil {
+		fmt.Printf("Lineage Store Serialization error\n")
+		return fmt.Errorf("Lineage Store Serialization Error\n")
+	}
+	for _, counter := range counters {
+		err = stub.PutState("Lineage:"+hex.EncodeToString(counter), lineageBytes)
+		if err != nil {
+			fmt.Printf("error putting lineage state to ledger: (%s)\n", err.Error())
+			return fmt.Errorf("error putting lineage state to ledger: (%s)\n", err.Error())
+		}
+	}
+	return nil
+}
+
+// traceRoot reads the counter of the output a trace starts from, given as a
+// counter or as an address and output index.
+func traceRoot(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	var root string
+	switch len(args) {
+	case 1:
+		root = args[0]
+	case 2:
+		popcode, err := loadPopcode(stub, args[0])
+		if err != nil {
+			return "", err
+		}
+		idx, err := strconv.Atoi(args[1])
+		if err != nil || idx < 0 || idx >= len(popcode.Outputs) {
+			return "", fmt.Errorf("Invalid Output index %s", args[1])
+		}
+		root = hex.EncodeToString(popcode.Outputs[idx].PrevCounter)
+	default:
+		return "", fmt.Errorf("expected a counter or an address and output index\n")
+	}
+	counterBytes, err := hex.DecodeString(root)
+	if err != nil || len(counterBytes) == 0 {
+		return "", fmt.Errorf("invalid counter (%s)\n", root)
+	}
+	return root, nil
+}
+
+// walkLineage calls visit with the lineage of root and then of each of its
+// ancestors once, breadth first, and with nil for counters without a record.
+func walkLineage(stub shim.ChaincodeStubInterface, root string, visit func(counter string, lineage *TuxedoPopsStore.Lineage)) error {
+	records := 0
+	seen := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		counter := queue[0]
+		queue = queue[1:]
+		lineage, err := getLineage(stub, counter)
+		if err != nil {
+			return err
+		}
+		if lineage != nil {
+			if records == maxTraceRecords {
+				return fmt.Errorf("Trace of %s has more than %d records", root, maxTraceRecords)
+			}
+			records++
+			for _, parentBytes := range lineage.Parents {
+				parent := hex.EncodeToString(parentBytes)
+				if !seen[parent] {
+					seen[parent] = true
+					queue = append(queue, parent)
+				}
+			}
+		}
+		visit(counter, lineage)
+	}
+	return nil
+}
+
+// trace returns the ancestry of an output back to the creates it started
+// from. Records are listed breadth first from the output. Counters without a
+// record were made before lineage was recorded.
+func trace(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	root, err := traceRoot(stub, args)
+	if err != nil {
+		return nil, err
+	}
+
+	type JSONLineage struct {
+		Counter       string
+		Operation     string
+		Address       string
+		Type          string
+		Recipe        string `json:",omitempty"`
+		RecipeVersion int    `json:",omitempty"`
+		Parents       []string
+	}
+	result := struct {
+		Counter string
+		Records []JSONLineage
+		Origins []string
+		Unknown []string
+	}{Counter: root, Records: []JSONLineage{}, Origins: []string{}, Unknown: []string{}}
+
+	err = walkLineage(stub, root, func(counter string, lineage *TuxedoPopsStore.Lineage) {
+		if lineage == nil {
+			result.Unknown = append(result.Unknown, counter)
+			return
+		}
+		record := JSONLineage{}
+		record.Counter = counter
+		record.Operation = lineage.Operation
+		record.Address = lineage.Address
+		record.Type = lineage.Type
+		record.Recipe = lineage.Recipe
+		record.RecipeVersion = int(lineage.RecipeVersion)
+		record.Parents = []string{}
+		for _, parent := range lineage.Parents {
+			record.Parents = append(record.Parents, hex.EncodeToString(parent))
+		}
+		if lineage.Operation == "create" {
+			result.Origins = append(result.Origins, counter)
+		}
+		result.Records = append(result.Records, record)
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type traceResult struct {
+	Counter string
+	Records []struct {
+		Counter   string
+		Operation string
+		Address   string
+		Type      string
+		Recipe    string
+		Parents   []string
+	}
+	Origins []string
+	Unknown []string
+}
+
+func getTrace(t *testing.T, stub *shim.MockStub, args ...string) traceResult {
+	traceBytes, err := stub.MockQuery("trace", args)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	result := traceResult{}
+	json.Unmarshal(traceBytes, &result)
+	return result
+}
+
+func TestTrace(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	miller, _ := btcec.NewPrivateKey(btcec.S256())
+	baker, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{
+		{Numerator: 2, Denominator: 1, Type: "Flour"}, {Numerator: 1, Denominator: 1, Type: "Water"}}}, baker)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 10, Type: "Flour"}, miller)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{baker.PubKey()}}, nil, popcodeA)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: addressB, DestAmounts: []int{4}}, []*btcec.PrivateKey{baker}, popcodeA)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: addressB, Amount: 5, Type: "Water"}, baker)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 4}, {Output: 1, Amount: 2}}, Amount: 2, Recipe: "Loaf"},
+		baker, []*btcec.PrivateKey{baker}, popcodeB)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	// the bread goes back through the unitize and transfer of the flour to
+	// its create, and straight to the create of the water
+	bread := getTrace(t, stub, addressB, "1")
+	operations := []string{}
+	for _, record := range bread.Records {
+		operations = append(operations, record.Operation)
+	}
+	if fmt.Sprint(operations) != "[combine unitize create transfer create]" {
+		HandleError(t, fmt.Errorf("unexpected trace of the bread %v", operations))
+	}
+	if len(bread.Records) == 5 {
+		if bread.Records[0].Counter != bread.Counter || bread.Records[0].Recipe != "Loaf" || len(bread.Records[0].Parents) != 2 ||
+			bread.Records[0].Address != addressB || bread.Records[0].Type != "Bread" {
+			HandleError(t, fmt.Errorf("unexpected combine record %+v", bread.Records[0]))
+		}
+		if bread.Records[1].Address != addressB || bread.Records[3].Address != addressA {
+			HandleError(t, fmt.Errorf("unexpected addresses in trace %+v", bread.Records))
+		}
+	}
+	if len(bread.Origins) != 2 || len(bread.Unknown) != 0 {
+		HandleError(t, fmt.Errorf("unexpected origins %v or unknown counters %v", bread.Origins, bread.Unknown))
+	}
+
+	// the flour left behind keeps the counter of its transfer
+	flour := getTrace(t, stub, addressA, "0")
+	if len(flour.Records) != 2 || flour.Records[0].Operation != "transfer" {
+		HandleError(t, fmt.Errorf("unexpected trace of the remaining flour %+v", flour))
+	}
+	if byCounter := getTrace(t, stub, flour.Counter); len(byCounter.Records) != 2 {
+		HandleError(t, fmt.Errorf("trace by counter differs from trace by output %+v", byCounter))
+	}
+
+	// outputs that predate lineage have no record
+	if unknown := getTrace(t, stub, "00"); len(unknown.Records) != 0 || len(unknown.Unknown) != 1 {
+		HandleError(t, fmt.Errorf("unexpected trace of an unknown counter %+v", unknown))
+	}
+	for _, args := range [][]string{{"zz"}, {addressA, strconv.Itoa(5)}, {addressA, "0", "0"}} {
+		if _, err := stub.MockQuery("trace", args); err == nil {
+			HandleError(t, fmt.Errorf("trace of %v succeeded", args))
+		}
+	}
+}
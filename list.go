@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// parsePage reads the optional page size and continuation arguments of a
+// listing query.
+func parsePage(args []string) (int, string, error) {
+	if len(args) > 2 {
+		return 0, "", fmt.Errorf("expected at most a page size and a continuation key\n")
+	}
+	pageSize := defaultPageSize
+	if len(args) > 0 && args[0] != "" {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size < 0 {
+			return 0, "", fmt.Errorf("invalid page size (%s)\n", args[0])
+		}
+		if size > 0 {
+			pageSize = size
+		}
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	next := ""
+	if len(args) > 1 {
+		next = args[1]
+	}
+	return pageSize, next, nil
+}
+
+// rangePage calls visit with up to pageSize keys under prefix in key order,
+// or with every key when pageSize is 0, starting at prefix+start, and returns
+// the part of the key after prefix where the next page starts, or "" after
+// the last page. Keys for which skip, when given, is true are passed over and
+// never start a page.
+func rangePage(stub shim.ChaincodeStubInterface, prefix string, start string, pageSize int, skip func(key string) bool,
+	visit func(key string, value []byte) error) (string, error) {
+	startKey := prefix + start
+	// the last byte of the prefix is incremented to get the first key after
+	// every key beginning with it
+	endKey := prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		return "", fmt.Errorf("Could not query range %s: %s", prefix, err.Error())
+	}
+	defer iter.Close()
+
+	count := 0
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return "", err
+		}
+		// some stubs do not bound the range themselves
+		if key < startKey {
+			continue
+		}
+		if key >= endKey {
+			break
+		}
+		if skip != nil && skip(key) {
+			continue
+		}
+		if pageSize > 0 && count == pageSize {
+			return key[len(prefix):], nil
+		}
+		err = visit(key, value)
+		if err != nil {
+			return "", err
+		}
+		count++
+	}
+	return "", nil
+}
+
+func listPopcodes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	pageSize, start, err := parsePage(args)
+	if err != nil {
+		return nil, err
+	}
+	type JSONPopcodeSummary struct {
+		Address string
+		Counter string
+		Outputs int
+		Totals  map[string]int64
+	}
+	page := struct {
+		Popcodes []JSONPopcodeSummary
+		Next     string
+	}{Popcodes: []JSONPopcodeSummary{}}
+	page.Next, err = rangePage(stub, "Popcode:", start, pageSize, nil, func(key string, value []byte) error {
+		popcode := Pop.Pop{}
+		err := popcode.FromBytes(value)
+		if err != nil {
+			return fmt.Errorf("Could not deserialize %s", key)
+		}
+		summary := JSONPopcodeSummary{}
+		summary.Address = popcode.Address
+		summary.Counter = hex.EncodeToString(popcode.Counter)
+		summary.Outputs = len(popcode.Outputs)
+		summary.Totals = unitsByType(&popcode)
+		page.Popcodes = append(page.Popcodes, summary)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	return json.Marshal(page)
+}
+
+func listRecipes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	pageSize, start, err := parsePage(args)
+	if err != nil {
+		return nil, err
+	}
+	type JSONRecipeSummary struct {
+		Name        string
+		CreatedType string
+		Creator     string
+		Ingredients int
+		Latest      int
+		Status      string
+	}
+	page := struct {
+		Recipes []JSONRecipeSummary
+		Next    string
+	}{Recipes: []JSONRecipeSummary{}}
+	// only version 1 is stored under Recipe:, the summary describes the
+	// latest version
+	page.Next, err = rangePage(stub, "Recipe:", start, pageSize, nil, func(key string, value []byte) error {
+		name := key[len("Recipe:"):]
+		first, err := getRecipe(stub, name, 1)
+		if err != nil {
+			return err
+		}
+		recipe := first
+		if first.Latest > 1 {
+			recipe, err = getRecipe(stub, name, int(first.Latest))
+			if err != nil {
+				return err
+			}
+			if recipe == nil {
+				return fmt.Errorf("Recipe %s has no version %d", name, first.Latest)
+			}
+		}
+		summary := JSONRecipeSummary{}
+		summary.Name = name
+		summary.CreatedType = recipe.CreatedType
+		summary.Creator = hex.EncodeToString(recipe.Creator)
+		summary.Ingredients = len(recipe.Ingredients)
+		summary.Latest = int(first.Latest)
+		summary.Status = Pop.RecipeStatusName(int(recipe.Status))
+		page.Recipes = append(page.Recipes, summary)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	return json.Marshal(page)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestListPopcodes(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	addresses := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		popcode, _ := btcec.NewPrivateKey(btcec.S256())
+		address := client.Address(popcode.PubKey())
+		addresses[address] = true
+		createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Apple"}, creator)
+		stub.MockInvoke("1", "create", []string{createHex})
+		createHex, _ = c.Create(client.Create{Address: address, Amount: i + 1, Type: "Pear"}, creator)
+		stub.MockInvoke("1", "create", []string{createHex})
+	}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Cider", CreatedType: "Cider", Ingredients: []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Apple"}}}, creator)
+	stub.MockInvoke("1", "recipe", []string{recipeHex})
+
+	type page struct {
+		Popcodes []struct {
+			Address string
+			Outputs int
+			Totals  map[string]int64
+		}
+		Next string
+	}
+	seen := map[string]bool{}
+	next := ""
+	pages := 0
+	for {
+		pageBytes, err := stub.MockQuery("listPopcodes", []string{"2", next})
+		if err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+		p := page{}
+		json.Unmarshal(pageBytes, &p)
+		if len(p.Popcodes) > 2 {
+			HandleError(t, fmt.Errorf("page larger than the page size %s", pageBytes))
+		}
+		for _, summary := range p.Popcodes {
+			if seen[summary.Address] || !addresses[summary.Address] {
+				HandleError(t, fmt.Errorf("unexpected popcode %s in listing", summary.Address))
+			}
+			seen[summary.Address] = true
+			if summary.Totals["Apple"] != 10 || summary.Outputs < 1 {
+				HandleError(t, fmt.Errorf("unexpected summary %+v", summary))
+			}
+		}
+		pages++
+		if p.Next == "" || pages > 5 {
+			break
+		}
+		next = p.Next
+	}
+	if len(seen) != 5 || pages != 3 {
+		HandleError(t, fmt.Errorf("listed %d popcodes in %d pages, expected 5 in 3", len(seen), pages))
+	}
+	if _, err := stub.MockQuery("listPopcodes", []string{"x"}); err == nil {
+		HandleError(t, fmt.Errorf("invalid page size was accepted"))
+	}
+}
+
+func TestListRecipes(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	for _, name := range []string{"Cider", "Juice", "Vinegar"} {
+		recipeHex, _ := c.Recipe(client.Recipe{Name: name, CreatedType: name, Ingredients: []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Apple"}}}, creator)
+		if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+	}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Juice", RecipeVersion: 2, CreatedType: "Juice",
+		Ingredients: []client.Ingredient{{Numerator: 2, Denominator: 1, Type: "Apple"}}}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	type page struct {
+		Recipes []struct {
+			Name   string
+			Latest int
+		}
+		Next string
+	}
+	pageBytes, err := stub.MockQuery("listRecipes", []string{"2"})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	first := page{}
+	json.Unmarshal(pageBytes, &first)
+	if len(first.Recipes) != 2 || first.Recipes[0].Name != "Cider" || first.Recipes[1].Name != "Juice" ||
+		first.Recipes[1].Latest != 2 || first.Next != "Vinegar" {
+		HandleError(t, fmt.Errorf("unexpected first page %s", pageBytes))
+	}
+	pageBytes, err = stub.MockQuery("listRecipes", []string{"2", first.Next})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	second := page{}
+	json.Unmarshal(pageBytes, &second)
+	if len(second.Recipes) != 1 || second.Recipes[0].Name != "Vinegar" || second.Next != "" {
+		HandleError(t, fmt.Errorf("unexpected second page %s", pageBytes))
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/OTX"
 	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/TxEvents"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
@@ -54,10 +57,6 @@ func TestPopcodeChaincode(t *testing.T) {
 		As such, these tests must be performed on a fresh chaincode (they must be performed first)
 		otherwise, the counterseeds will not match expected values
 	*/
-	for i := 0; i < 2; i++ {
-		checkCounterSeedChange(t, stub)
-	}
-
 	testMint(t, stub, popcodes, users)
 
 	/*
@@ -98,7 +97,7 @@ func TestHardCoded(t *testing.T) {
 	hardCodedPossess(t, stub, "afab4e267a433fe306d1da4608629ce9a280bde98f7004ff883383d65b9f5948", 1)
 	checkQuery(t, stub, "74ded2036e988fc56e3cff77a40c58239591e921", `{"Address":"74ded2036e988fc56e3cff77a40c58239591e921","Counter":"92c7dff498fbe29d4b8d959a0f519a26ce43844f8871736191e5b62f8f507ea0","Outputs":["{\"Owners\":null,\"Threshold\":0,\"Data\":\"Test Data\",\"Type\":\"Test Asset\",\"PrevCounter\":\"e91d1eab53d597e8e18bb9ebbbaec66d08187d7e14a4a58c8782610ce7c7a74b\",\"Creator\":\"03cc7d40833fdf46e05a7f86a6c9cf8a697a129fbae0676ad6bad71f163ea22b26\",\"Amount\":10}","{\"Owners\":[\"0278b76afbefb1e1185bc63ed1a17dd88634e0587491f03e9a8d2d25d9ab289ee7\"],\"Threshold\":1,\"Data\":\"Test possess\",\"Type\":\"Test Asset\",\"PrevCounter\":\"afab4e267a433fe306d1da4608629ce9a280bde98f7004ff883383d65b9f5948\",\"Creator\":\"03cc7d40833fdf46e05a7f86a6c9cf8a697a129fbae0676ad6bad71f163ea22b26\",\"Amount\":10}"]}`)
 	hardCodedUnitize(t, stub, "92c7dff498fbe29d4b8d959a0f519a26ce43844f8871736191e5b62f8f507ea0")
-	checkQuery(t, stub, "74ded2036e988fc56e3cff77a40c58239591e921", `{"Address":"74ded2036e988fc56e3cff77a40c58239591e921","Counter":"92c7dff498fbe29d4b8d959a0f519a26ce43844f8871736191e5b62f8f507ea0","Outputs":["{\"Owners\":[\"0278b76afbefb1e1185bc63ed1a17dd88634e0587491f03e9a8d2d25d9ab289ee7\"],\"Threshold\":1,\"Data\":\"Test possess\",\"Type\":\"Test Asset\",\"PrevCounter\":\"afab4e267a433fe306d1da4608629ce9a280bde98f7004ff883383d65b9f5948\",\"Creator\":\"03cc7d40833fdf46e05a7f86a6c9cf8a697a129fbae0676ad6bad71f163ea22b26\",\"Amount\":10}"]}`)
+	checkQuery(t, stub, "74ded2036e988fc56e3cff77a40c58239591e921", `{"Address":"74ded2036e988fc56e3cff77a40c58239591e921","Counter":"d72005e980a917c97ed17626a9483f3c58eb90efb872db6d5aa2f4ffb98f8a6f","Outputs":["{\"Owners\":[\"0278b76afbefb1e1185bc63ed1a17dd88634e0587491f03e9a8d2d25d9ab289ee7\"],\"Threshold\":1,\"Data\":\"Test possess\",\"Type\":\"Test Asset\",\"PrevCounter\":\"afab4e267a433fe306d1da4608629ce9a280bde98f7004ff883383d65b9f5948\",\"Creator\":\"03cc7d40833fdf46e05a7f86a6c9cf8a697a129fbae0676ad6bad71f163ea22b26\",\"Amount\":10}"]}`)
 	checkQuery(t, stub, "10734390011641497f489cb475743b8e50d429bb", `{"Address":"10734390011641497f489cb475743b8e50d429bb","Counter":"3d2cc9f7d475cf79347ff317b1164daa50ced56d3ee977252da0430f39fa7a4e","Outputs":["{\"Owners\":null,\"Threshold\":0,\"Data\":\"Test Unitize\",\"Type\":\"Test Asset\",\"PrevCounter\":\"660bfdba4544847711d515fb26c5f1f62f0c9fc45b5a41b0fefcc1d58de4f1c0\",\"Creator\":\"03cc7d40833fdf46e05a7f86a6c9cf8a697a129fbae0676ad6bad71f163ea22b26\",\"Amount\":10}"]}`)
 	hardCodedCombine(t, stub)
 }
@@ -106,7 +105,7 @@ func TestHardCoded(t *testing.T) {
 func checkInit(t *testing.T, stub *shim.MockStub, args []string) {
 	_, err := stub.MockInit("1", "", args)
 	if err != nil {
-		HandleError(t, fmt.Errorf("INIT", args, "failed", err))
+		HandleError(t, fmt.Errorf("INIT %v failed: %v", args, err))
 		t.FailNow()
 	}
 }
@@ -114,7 +113,7 @@ func checkInit(t *testing.T, stub *shim.MockStub, args []string) {
 func checkInvoke(t *testing.T, stub *shim.MockStub, args []string) {
 	_, err := stub.MockInvoke("1", "invoke", args)
 	if err != nil {
-		HandleError(t, fmt.Errorf("invoke", args, "failed", err))
+		HandleError(t, fmt.Errorf("invoke %v failed: %v", args, err))
 		t.FailNow()
 	}
 }
@@ -123,11 +122,11 @@ func checkQuery(t *testing.T, stub *shim.MockStub, name string, value string) {
 	bytes, err := stub.MockQuery("balance", []string{name})
 
 	if err != nil {
-		HandleError(t, fmt.Errorf("Query for address (", name, ") failed", err))
+		HandleError(t, fmt.Errorf("Query for address (%s) failed: %v", name, err))
 		t.FailNow()
 	}
 	if bytes == nil {
-		HandleError(t, fmt.Errorf("Query for address (", name, ") failed to get value"))
+		HandleError(t, fmt.Errorf("Query for address (%s) failed to get value", name))
 		t.FailNow()
 	}
 	if string(bytes) != value {
@@ -168,6 +167,9 @@ type outputJSON struct {
 	Recipe      string   `json:"Recipe"`
 	Creator     string   `json:"Creator"`
 	PrevCounter string   `json:"PrevCounter"`
+
+	HashLock *OTX.JSONHashLock `json:"HashLock"`
+	TimeLock *OTX.TimeLock     `json:"TimeLock"`
 }
 
 func getBalance(t *testing.T, stub *shim.MockStub, keys *keyInfo) finalBalanceJSON {
@@ -370,3 +372,37 @@ func HandleError(t *testing.T, err error) (b bool) {
 	}
 	return
 }
+
+// eventStub records the events set by the chaincode, which MockStub drops.
+type eventStub struct {
+	*shim.MockStub
+	events map[string][]byte
+}
+
+func (s *eventStub) SetEvent(name string, payload []byte) error {
+	s.events[name] = payload
+	return nil
+}
+
+// invokeWithEnvelopes runs an invoke transaction against stub and returns the
+// events it set, in their envelopes.
+func invokeWithEnvelopes(stub *shim.MockStub, function string, args []string) (map[string][]byte, error) {
+	es := &eventStub{MockStub: stub, events: make(map[string][]byte)}
+	stub.MockTransactionStart("1")
+	_, err := new(tuxedoPopsChaincode).Invoke(es, function, args)
+	stub.MockTransactionEnd("1")
+	return es.events, err
+}
+
+// invokeWithEvents runs an invoke transaction against stub and returns the
+// payloads of the events it set.
+func invokeWithEvents(stub *shim.MockStub, function string, args []string) (map[string][]byte, error) {
+	envelopes, err := invokeWithEnvelopes(stub, function, args)
+	events := make(map[string][]byte)
+	for name, envelopeBytes := range envelopes {
+		envelope := TxEvents.Envelope{}
+		proto.Unmarshal(envelopeBytes, &envelope)
+		events[name] = envelope.Payload
+	}
+	return events, err
+}
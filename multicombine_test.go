@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestMultiCombine(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	ownerB, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeDest, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+	destAddress := client.Address(popcodeDest.PubKey())
+
+	// flour and water are kept in different warehouses
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 6, Type: "Flour"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: addressB, Amount: 4, Type: "Water"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{ownerB.PubKey()}}, nil, popcodeB)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Dough", CreatedType: "Dough", Ingredients: []client.Ingredient{
+		{Numerator: 3, Denominator: 1, Type: "Flour"}, {Numerator: 2, Denominator: 1, Type: "Water"}}}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	beforeA := getBalance(t, stub, &keyInfo{address: addressA})
+	beforeB := getBalance(t, stub, &keyInfo{address: addressB})
+
+	tx := client.MultiCombine{
+		Sources:     []client.CombineSource{{Address: addressA, Output: 0, Amount: 6}, {Address: addressB, Output: 0, Amount: 4}},
+		Destination: destAddress,
+		Amount:      2,
+		Recipe:      "Dough",
+	}
+	combineHex, _ := c.MultiCombine(tx, creator, [][]*btcec.PrivateKey{nil, nil}, []*btcec.PrivateKey{popcodeA, popcodeB})
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine without the owner signature of B was accepted"))
+	}
+	combineHex, _ = c.MultiCombine(tx, creator, [][]*btcec.PrivateKey{nil, {ownerB}}, []*btcec.PrivateKey{popcodeA, popcodeB})
+
+	// every source popcode must sign
+	counterA, _ := c.Counter(addressA)
+	counterB, _ := c.Counter(addressB)
+	counters := [][]byte{counterA, counterB}
+	signerA, _ := tx.SignSource(counters, nil, popcodeA)
+	unsigned, _ := tx.Sign(counters, creator, []*TuxedoPopsTX.CombineSigner{signerA})
+	unsignedHex, _ := client.Encode(unsigned)
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{unsignedHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine without a signature of B was accepted"))
+	}
+
+	events, err := invokeWithEvents(stub, "multiCombine", []string{combineHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	afterA := getBalance(t, stub, &keyInfo{address: addressA})
+	afterB := getBalance(t, stub, &keyInfo{address: addressB})
+	dest := getBalance(t, stub, &keyInfo{address: destAddress})
+	if len(afterA.Outputs) != 0 || len(afterB.Outputs) != 0 {
+		HandleError(t, fmt.Errorf("sources were not consumed %v %v", afterA, afterB))
+	}
+	if afterA.Counter == beforeA.Counter || afterB.Counter == beforeB.Counter {
+		HandleError(t, fmt.Errorf("combine did not advance the source counters"))
+	}
+	if len(dest.Outputs) != 1 || dest.Outputs[0].Type != "Dough" || dest.Outputs[0].Amount != 2 {
+		HandleError(t, fmt.Errorf("unexpected destination balance %v", dest))
+	}
+	combineEvent := TxEvents.MultiCombineEvent{}
+	proto.Unmarshal(events["multiCombine"], &combineEvent)
+	if len(combineEvent.Sources) != 2 || combineEvent.Sources[1].Type != "Water" || combineEvent.Destination != destAddress ||
+		len(combineEvent.Products) != 1 || combineEvent.Waste != 8 {
+		HandleError(t, fmt.Errorf("unexpected combine event %v", combineEvent))
+	}
+
+	// replaying fails because the counters moved
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("replayed combine was accepted"))
+	}
+
+	// the product can land on one of the source popcodes
+	createHex, _ = c.Create(client.Create{Address: addressA, Amount: 3, Type: "Flour"}, creator)
+	stub.MockInvoke("1", "create", []string{createHex})
+	createHex, _ = c.Create(client.Create{Address: addressB, Amount: 2, Type: "Water"}, creator)
+	stub.MockInvoke("1", "create", []string{createHex})
+	tx = client.MultiCombine{
+		Sources:     []client.CombineSource{{Address: addressB, Output: 0, Amount: 2}, {Address: addressA, Output: 0, Amount: 3}},
+		Destination: addressA,
+		Amount:      1,
+		Recipe:      "Dough",
+	}
+	combineHex, _ = c.MultiCombine(tx, creator, [][]*btcec.PrivateKey{nil, nil}, []*btcec.PrivateKey{popcodeB, popcodeA})
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	afterA = getBalance(t, stub, &keyInfo{address: addressA})
+	if len(afterA.Outputs) != 1 || afterA.Outputs[0].Type != "Dough" || afterA.Outputs[0].Amount != 1 {
+		HandleError(t, fmt.Errorf("unexpected balance of the destination source %v", afterA))
+	}
+
+	// change goes back to the popcode it was drawn from
+	createHex, _ = c.Create(client.Create{Address: addressB, Amount: 5, Type: "Flour"}, creator)
+	stub.MockInvoke("1", "create", []string{createHex})
+	createHex, _ = c.Create(client.Create{Address: addressA, Amount: 2, Type: "Water"}, creator)
+	stub.MockInvoke("1", "create", []string{createHex})
+	tx = client.MultiCombine{
+		Sources:      []client.CombineSource{{Address: addressB, Output: 0, Amount: 5}, {Address: addressA, Output: 1, Amount: 2}},
+		Destination:  destAddress,
+		Amount:       1,
+		Recipe:       "Dough",
+		ReturnChange: true,
+	}
+	combineHex, _ = c.MultiCombine(tx, creator, [][]*btcec.PrivateKey{nil, nil}, []*btcec.PrivateKey{popcodeB, popcodeA})
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	afterB = getBalance(t, stub, &keyInfo{address: addressB})
+	if len(afterB.Outputs) != 1 || afterB.Outputs[0].Type != "Flour" || afterB.Outputs[0].Amount != 2 {
+		HandleError(t, fmt.Errorf("unexpected change on the source %v", afterB))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestMultiUnitize(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	source, _ := btcec.NewPrivateKey(btcec.S256())
+	sourceAddress := client.Address(source.PubKey())
+	stores := make([]string, 3)
+	for i := range stores {
+		store, _ := btcec.NewPrivateKey(btcec.S256())
+		stores[i] = client.Address(store.PubKey())
+	}
+
+	createHex, _ := c.Create(client.Create{Address: sourceAddress, Amount: 100, Type: "Pallet"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	destinations := []client.Destination{
+		{Address: stores[0], Amounts: []int{10, 5}},
+		{Address: stores[1], Amounts: []int{20}},
+		{Address: stores[2], Amounts: []int{30}},
+	}
+
+	// leftover units require an explicit change output
+	multiHex, _ := c.MultiUnitize(client.MultiUnitize{Destinations: destinations, Data: "ship"}, nil, source)
+	if _, err := stub.MockInvoke("1", "multiUnitize", []string{multiHex}); err == nil {
+		HandleError(t, fmt.Errorf("multiUnitize leaving units without change was accepted"))
+	}
+
+	duplicate := append(destinations, client.Destination{Address: stores[0], Amounts: []int{1}})
+	multiHex, _ = c.MultiUnitize(client.MultiUnitize{Destinations: duplicate, KeepChange: true}, nil, source)
+	if _, err := stub.MockInvoke("1", "multiUnitize", []string{multiHex}); err == nil {
+		HandleError(t, fmt.Errorf("multiUnitize with duplicate destinations was accepted"))
+	}
+
+	before := getBalance(t, stub, &keyInfo{address: sourceAddress})
+	multiHex, _ = c.MultiUnitize(client.MultiUnitize{Destinations: destinations, KeepChange: true, Data: "ship", Version: 1}, nil, source)
+	events, err := invokeWithEvents(stub, "multiUnitize", []string{multiHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	event := TxEvents.MultiUnitizeEvent{}
+	proto.Unmarshal(events["multiUnitize"], &event)
+	if len(event.Destinations) != 3 || event.ChangeAmount != 35 || fmt.Sprintf("%x", event.ChangeCounter) != before.Counter {
+		HandleError(t, fmt.Errorf("unexpected multiUnitize event %v", event))
+	}
+
+	for i, dest := range destinations {
+		balance := getBalance(t, stub, &keyInfo{address: dest.Address})
+		if len(balance.Outputs) != len(dest.Amounts) {
+			HandleError(t, fmt.Errorf("destination %s has outputs %v", dest.Address, balance.Outputs))
+			continue
+		}
+		for j, output := range balance.Outputs {
+			if int(output.Amount) != dest.Amounts[j] || output.Type != "Pallet" ||
+				output.PrevCounter != fmt.Sprintf("%x", event.Destinations[i].DestCounters[j]) {
+				HandleError(t, fmt.Errorf("unexpected output %v on %s", output, dest.Address))
+			}
+		}
+	}
+	after := getBalance(t, stub, &keyInfo{address: sourceAddress})
+	if len(after.Outputs) != 1 || after.Outputs[0].Amount != 35 || after.Counter == before.Counter {
+		HandleError(t, fmt.Errorf("unexpected source balance after multiUnitize %v", after))
+	}
+
+	if _, err := stub.MockInvoke("1", "multiUnitize", []string{multiHex}); err == nil {
+		HandleError(t, fmt.Errorf("replayed multiUnitize was accepted"))
+	}
+
+	// spend the change exactly, removing the source output
+	multiHex, _ = c.MultiUnitize(client.MultiUnitize{Destinations: []client.Destination{{Address: stores[0], Amounts: []int{35}}}}, nil, source)
+	if _, err := stub.MockInvoke("1", "multiUnitize", []string{multiHex}); err != nil {
+		HandleError(t, err)
+	}
+	if after = getBalance(t, stub, &keyInfo{address: sourceAddress}); len(after.Outputs) != 0 {
+		HandleError(t, fmt.Errorf("source output was not consumed %v", after))
+	}
+	if balance := getBalance(t, stub, &keyInfo{address: stores[0]}); len(balance.Outputs) != 3 {
+		HandleError(t, fmt.Errorf("expected 3 outputs on %s, got %v", stores[0], balance.Outputs))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type holdingsResult struct {
+	Holdings map[string]struct {
+		Total   int64
+		Outputs []struct {
+			Address string
+			Amount  int
+		}
+	}
+}
+
+func getHoldings(t *testing.T, stub *shim.MockStub, owner *btcec.PrivateKey) holdingsResult {
+	holdingsBytes, err := stub.MockQuery("holdings", []string{hex.EncodeToString(owner.PubKey().SerializeCompressed())})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	result := holdingsResult{}
+	json.Unmarshal(holdingsBytes, &result)
+	return result
+}
+
+func TestHoldings(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	alice, _ := btcec.NewPrivateKey(btcec.S256())
+	bob, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 10, Type: "Shares"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if len(getHoldings(t, stub, alice).Holdings) != 0 {
+		HandleError(t, fmt.Errorf("unowned output listed in holdings"))
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{alice.PubKey()}}, nil, popcodeA)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	holdings := getHoldings(t, stub, alice)
+	if shares := holdings.Holdings["Shares"]; shares.Total != 10 || len(shares.Outputs) != 1 || shares.Outputs[0].Address != addressA {
+		HandleError(t, fmt.Errorf("unexpected holdings after transfer %+v", holdings))
+	}
+
+	// units moved to another popcode keep their owners
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: addressB, DestAmounts: []int{4}}, []*btcec.PrivateKey{alice}, popcodeA)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	holdings = getHoldings(t, stub, alice)
+	if shares := holdings.Holdings["Shares"]; shares.Total != 10 || len(shares.Outputs) != 2 {
+		HandleError(t, fmt.Errorf("unexpected holdings after unitize %+v", holdings))
+	}
+	burnHex, _ := c.Burn(client.Burn{Output: 0, Amount: 6}, []*btcec.PrivateKey{alice}, popcodeA)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	holdings = getHoldings(t, stub, alice)
+	if shares := holdings.Holdings["Shares"]; shares.Total != 4 || len(shares.Outputs) != 1 || shares.Outputs[0].Address != addressB {
+		HandleError(t, fmt.Errorf("unexpected holdings after burn %+v", holdings))
+	}
+
+	transferHex, _ = c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{bob.PubKey()}}, []*btcec.PrivateKey{alice}, popcodeB)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if holdings = getHoldings(t, stub, alice); len(holdings.Holdings) != 0 {
+		HandleError(t, fmt.Errorf("previous owner still holds %+v", holdings))
+	}
+	if holdings = getHoldings(t, stub, bob); holdings.Holdings["Shares"].Total != 4 {
+		HandleError(t, fmt.Errorf("unexpected holdings of the new owner %+v", holdings))
+	}
+	if _, err := stub.MockQuery("holdings", []string{"zz"}); err == nil {
+		HandleError(t, fmt.Errorf("holdings of an invalid key succeeded"))
+	}
+}
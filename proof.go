@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/proof"
+)
+
+// proofPopcode captures the counter of p and its outputs at indices before an
+// operation changes them.
+func proofPopcode(p *Pop.Pop, indices ...int) *TuxedoPopsStore.ProofPopcode {
+	popcode := TuxedoPopsStore.ProofPopcode{}
+	popcode.Address = p.Address
+	popcode.Counter = make([]byte, len(p.Counter))
+	copy(popcode.Counter, p.Counter)
+	for _, idx := range indices {
+		popcode.Indices = append(popcode.Indices, int32(idx))
+		popcode.Outputs = append(popcode.Outputs, p.Outputs[idx].ToProtoBuf())
+	}
+	return &popcode
+}
+
+// newStep describes an operation for proof bundles: its signed transaction,
+// the time it was checked at and the popcodes it read.
+func newStep(stub shim.ChaincodeStubInterface, function string, argsBytes []byte, popcodes ...*TuxedoPopsStore.ProofPopcode) *TuxedoPopsStore.ProofStep {
+	step := TuxedoPopsStore.ProofStep{}
+	step.Function = function
+	step.Tx = argsBytes
+	step.Time = spendTime(stub)
+	step.Popcodes = popcodes
+	return &step
+}
+
+// putStep stores step with the event of its operation under Step:<hash> and
+// returns the hash, which the lineage records of the operation refer to.
+func putStep(stub shim.ChaincodeStubInterface, step *TuxedoPopsStore.ProofStep, event proto.Message) ([]byte, error) {
+	eventBytes, err := proto.Marshal(event)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	step.Event = eventBytes
+	stepBytes, err := proto.Marshal(step)
+	if err != nil {
+		fmt.Printf("Step Store Serialization error\n")
+		return nil, fmt.Errorf("Step Store Serialization Error\n")
+	}
+	stepHash := sha256.Sum256(stepBytes)
+	err = stub.PutState("Step:"+hex.EncodeToString(stepHash[:]), stepBytes)
+	if err != nil {
+		fmt.Printf("error putting step state to ledger: (%s)\n", err.Error())
+		return nil, fmt.Errorf("error putting step state to ledger: (%s)\n", err.Error())
+	}
+	return stepHash[:], nil
+}
+
+// proofBundle returns the ancestry of an output as a proof.Bundle that can be
+// checked offline with proof.Verify. It takes the arguments of trace.
+func proofBundle(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	root, err := traceRoot(stub, args)
+	if err != nil {
+		return nil, err
+	}
+	bundle := proof.Bundle{Counter: root, Records: []proof.Record{}, Steps: make(map[string][]byte)}
+	var stepErr error
+	err = walkLineage(stub, root, func(counter string, lineage *TuxedoPopsStore.Lineage) {
+		if lineage == nil || stepErr != nil {
+			return
+		}
+		lineageBytes, err := proto.Marshal(lineage)
+		if err != nil {
+			stepErr = err
+			return
+		}
+		bundle.Records = append(bundle.Records, proof.Record{Counter: counter, Lineage: lineageBytes})
+		// records made before steps were kept cannot be verified
+		if len(lineage.Step) == 0 {
+			return
+		}
+		stepKey := hex.EncodeToString(lineage.Step)
+		if _, ok := bundle.Steps[stepKey]; ok {
+			return
+		}
+		stepBytes, err := stub.GetState("Step:" + stepKey)
+		if err != nil {
+			fmt.Println("Could not get Step State")
+			stepErr = errors.New("Could not get Step State")
+			return
+		}
+		bundle.Steps[stepKey] = stepBytes
+	})
+	if err == nil {
+		err = stepErr
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	return json.Marshal(bundle)
+}
@@ -0,0 +1,191 @@
+// Package proof checks the provenance bundles returned by the proof query
+// without access to the ledger. Every step in a bundle is replayed with the
+// rules of Pop, so the signatures and counters of each operation are checked
+// exactly as the chaincode checked them.
+package proof
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/OTX"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+)
+
+// Bundle is the ancestry of the output at Counter: the lineage record of
+// every counter back to the creates it started from, and the steps those
+// records refer to keyed by their hex hash.
+type Bundle struct {
+	Counter string
+	Records []Record
+	Steps   map[string][]byte
+}
+
+// Record is the lineage of one counter as stored on the ledger.
+type Record struct {
+	Counter string
+	Lineage []byte
+}
+
+// link is an output spent by a step, which must be the output its parent
+// step produced.
+type link struct {
+	parent  string
+	address string
+	spent   *TuxedoPopsStore.OTX
+}
+
+// Verify checks that every record of b is produced by replaying its step,
+// that every output a step spent is one its parent produced, and that the
+// ancestry of b.Counter ends in creates. It returns the counters of those
+// creates.
+func Verify(b *Bundle) ([]string, error) {
+	lineages := make(map[string]*TuxedoPopsStore.Lineage)
+	for _, record := range b.Records {
+		lineage := TuxedoPopsStore.Lineage{}
+		err := proto.Unmarshal(record.Lineage, &lineage)
+		if err != nil {
+			return nil, fmt.Errorf("Could not deserialize Lineage %s", record.Counter)
+		}
+		lineages[record.Counter] = &lineage
+	}
+
+	replayed := make(map[string][]*Pop.Pop)
+	links := []link{}
+	origins := []string{}
+	seen := map[string]bool{b.Counter: true}
+	queue := []string{b.Counter}
+	for len(queue) > 0 {
+		counter := queue[0]
+		queue = queue[1:]
+		lineage, ok := lineages[counter]
+		if !ok {
+			return nil, fmt.Errorf("No lineage of %s", counter)
+		}
+		step, after, err := replayStep(b, lineage.Step, replayed)
+		if err != nil {
+			return nil, fmt.Errorf("Step of %s: %s", counter, err.Error())
+		}
+		if step.Function != lineage.Operation {
+			return nil, fmt.Errorf("Lineage of %s is a %s but its step is a %s", counter, lineage.Operation, step.Function)
+		}
+		produced := outputsAt(after, lineage.Address, counter)
+		if len(produced) == 0 {
+			return nil, fmt.Errorf("Step of %s does not produce it on %s", counter, lineage.Address)
+		}
+		for _, output := range produced {
+			if output.Type != lineage.Type {
+				return nil, fmt.Errorf("Step of %s produces %s, not %s", counter, output.Type, lineage.Type)
+			}
+		}
+		if lineage.Operation == "create" {
+			origins = append(origins, counter)
+		} else if len(lineage.Parents) == 0 {
+			return nil, fmt.Errorf("Lineage of %s has no parents", counter)
+		}
+		for _, parentBytes := range lineage.Parents {
+			parent := hex.EncodeToString(parentBytes)
+			address, spent := spentOutput(step, parentBytes)
+			if spent == nil {
+				return nil, fmt.Errorf("Step of %s does not spend its parent %s", counter, parent)
+			}
+			links = append(links, link{parent: parent, address: address, spent: spent})
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	for _, l := range links {
+		parent := lineages[l.parent]
+		if parent.Address != l.address {
+			return nil, fmt.Errorf("Output %s was spent on %s but made on %s", l.parent, l.address, parent.Address)
+		}
+		after := replayed[hex.EncodeToString(parent.Step)]
+		if !matchesAny(outputsAt(after, parent.Address, l.parent), l.spent) {
+			return nil, fmt.Errorf("Output %s as spent differs from the output its step produced", l.parent)
+		}
+	}
+	return origins, nil
+}
+
+// replayStep replays the step with hash stepHash once, keeping the popcodes
+// it leaves in replayed.
+func replayStep(b *Bundle, stepHash []byte, replayed map[string][]*Pop.Pop) (*TuxedoPopsStore.ProofStep, []*Pop.Pop, error) {
+	key := hex.EncodeToString(stepHash)
+	stepBytes, ok := b.Steps[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("missing step %s", key)
+	}
+	digest := sha256.Sum256(stepBytes)
+	if !bytes.Equal(digest[:], stepHash) {
+		return nil, nil, fmt.Errorf("step %s does not match its hash", key)
+	}
+	step := TuxedoPopsStore.ProofStep{}
+	err := proto.Unmarshal(stepBytes, &step)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not deserialize step %s", key)
+	}
+	after, ok := replayed[key]
+	if !ok {
+		after, err = replay(&step)
+		if err != nil {
+			return nil, nil, err
+		}
+		replayed[key] = after
+	}
+	return &step, after, nil
+}
+
+// outputsAt returns the outputs of the popcode at address with counter.
+func outputsAt(pops []*Pop.Pop, address string, counter string) []OTX.SecP256k1Output {
+	outputs := []OTX.SecP256k1Output{}
+	for _, p := range pops {
+		if p.Address != address {
+			continue
+		}
+		for _, output := range p.Outputs {
+			if output.Creator != nil && hex.EncodeToString(output.PrevCounter) == counter {
+				outputs = append(outputs, output)
+			}
+		}
+	}
+	return outputs
+}
+
+// spentOutput finds the output with counter among those step read and
+// returns it with the address of its popcode.
+func spentOutput(step *TuxedoPopsStore.ProofStep, counter []byte) (string, *TuxedoPopsStore.OTX) {
+	for _, popcode := range step.Popcodes {
+		for _, output := range popcode.Outputs {
+			if bytes.Equal(output.PrevCounter, counter) {
+				return popcode.Address, output
+			}
+		}
+	}
+	return "", nil
+}
+
+// matchesAny reports whether spent is one of produced. Outputs can lose units
+// without changing counter, so spent may hold fewer units than were produced
+// but must match in everything else.
+func matchesAny(produced []OTX.SecP256k1Output, spent *TuxedoPopsStore.OTX) bool {
+	for _, output := range produced {
+		made := *output.ToProtoBuf()
+		used := *spent
+		if used.Amount > made.Amount {
+			continue
+		}
+		made.Amount = 0
+		used.Amount = 0
+		if proto.Equal(&made, &used) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package proof
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type testKeys struct {
+	creator *btcec.PrivateKey
+	owner   *btcec.PrivateKey
+	popcode *btcec.PrivateKey
+}
+
+// addStep adds step to b and returns the popcodes it leaves and its hash.
+func addStep(t *testing.T, b *Bundle, step *TuxedoPopsStore.ProofStep) ([]*Pop.Pop, []byte) {
+	stepBytes, err := proto.Marshal(step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(stepBytes)
+	b.Steps[hex.EncodeToString(digest[:])] = stepBytes
+	after, err := replay(step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return after, digest[:]
+}
+
+// addRecord adds the lineage of counter to b.
+func addRecord(t *testing.T, b *Bundle, counter []byte, lineage *TuxedoPopsStore.Lineage) {
+	lineageBytes, err := proto.Marshal(lineage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Records = append(b.Records, Record{Counter: hex.EncodeToString(counter), Lineage: lineageBytes})
+}
+
+// testBundle returns the proof of an output created and then transferred to
+// the owner, with transferData as the data of the transfer.
+func testBundle(t *testing.T, keys testKeys, transferData string) *Bundle {
+	address := client.Address(keys.popcode.PubKey())
+	seed := sha256.Sum256([]byte("seed"))
+	b := &Bundle{Steps: make(map[string][]byte)}
+
+	create, _ := client.Create{Address: address, Amount: 10, Type: "Flour", Version: 1}.Sign(seed[:], keys.creator)
+	createBytes, _ := proto.Marshal(create)
+	created, createHash := addStep(t, b, &TuxedoPopsStore.ProofStep{Function: "create", Tx: createBytes,
+		Popcodes: []*TuxedoPopsStore.ProofPopcode{{Address: address, Counter: seed[:]}}})
+	p := created[0]
+	createdCounter := p.Outputs[0].PrevCounter
+	addRecord(t, b, createdCounter, &TuxedoPopsStore.Lineage{Operation: "create", Address: address, Type: "Flour", Step: createHash})
+
+	transfer, _ := client.Transfer{Output: 0, Owners: []*btcec.PublicKey{keys.owner.PubKey()}, Version: 1}.Sign(p.Counter, nil, keys.popcode)
+	transfer.Data = transferData
+	transferBytes, _ := proto.Marshal(transfer)
+	step := &TuxedoPopsStore.ProofStep{Function: "transfer", Tx: transferBytes, Popcodes: []*TuxedoPopsStore.ProofPopcode{{
+		Address: address, Counter: p.Counter, Indices: []int32{0}, Outputs: []*TuxedoPopsStore.OTX{p.Outputs[0].ToProtoBuf()}}}}
+	stepBytes, _ := proto.Marshal(step)
+	digest := sha256.Sum256(stepBytes)
+	b.Steps[hex.EncodeToString(digest[:])] = stepBytes
+	// the transferred output moves to the counter the popcode was at
+	transferred := p.Counter
+	addRecord(t, b, transferred, &TuxedoPopsStore.Lineage{Parents: [][]byte{createdCounter}, Operation: "transfer",
+		Address: address, Type: "Flour", Step: digest[:]})
+	b.Counter = hex.EncodeToString(transferred)
+	return b
+}
+
+func TestVerify(t *testing.T) {
+	keys := testKeys{}
+	keys.creator, _ = btcec.NewPrivateKey(btcec.S256())
+	keys.owner, _ = btcec.NewPrivateKey(btcec.S256())
+	keys.popcode, _ = btcec.NewPrivateKey(btcec.S256())
+
+	b := testBundle(t, keys, "")
+	origins, err := Verify(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(origins) != 1 || len(b.Records) != 2 || origins[0] != b.Records[0].Counter {
+		t.Errorf("unexpected origins %v", origins)
+	}
+
+	// a transfer whose data was changed after it was signed
+	if _, err = Verify(testBundle(t, keys, "forged")); err == nil {
+		t.Errorf("bundle with a forged transfer was verified")
+	}
+
+	for name, tamper := range map[string]func(b *Bundle){
+		"missing step": func(b *Bundle) {
+			for key := range b.Steps {
+				delete(b.Steps, key)
+				break
+			}
+		},
+		"altered step": func(b *Bundle) {
+			for key, step := range b.Steps {
+				b.Steps[key] = append(step, 0)
+			}
+		},
+		"missing record":  func(b *Bundle) { b.Records = b.Records[1:] },
+		"unknown counter": func(b *Bundle) { b.Counter = "00" },
+	} {
+		b := testBundle(t, keys, "")
+		tamper(b)
+		if _, err = Verify(b); err == nil {
+			t.Errorf("bundle with a %s was verified", name)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package proof
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/OTX"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+)
+
+// restore rebuilds a popcode as a step read it. Outputs the step did not
+// read are left empty so that the indices in its transaction still line up.
+func restore(popcode *TuxedoPopsStore.ProofPopcode) (*Pop.Pop, error) {
+	if len(popcode.Indices) != len(popcode.Outputs) {
+		return nil, fmt.Errorf("popcode %s has %d indices for %d outputs", popcode.Address, len(popcode.Indices), len(popcode.Outputs))
+	}
+	p := &Pop.Pop{Address: popcode.Address, Counter: popcode.Counter}
+	for i, idx := range popcode.Indices {
+		if idx < 0 {
+			return nil, fmt.Errorf("Invalid index %d", idx)
+		}
+		for len(p.Outputs) <= int(idx) {
+			p.Outputs = append(p.Outputs, OTX.SecP256k1Output{})
+		}
+		err := p.Outputs[idx].FromProtoBuf(*popcode.Outputs[i])
+		if err != nil {
+			return nil, fmt.Errorf("Could not deserialize output %d of %s", idx, popcode.Address)
+		}
+	}
+	return p, nil
+}
+
+// checkOutput checks that the output a transaction refers to was read by its
+// step.
+func checkOutput(p *Pop.Pop, idx int32) error {
+	if idx < 0 || int(idx) >= len(p.Outputs) || p.Outputs[idx].Creator == nil {
+		return fmt.Errorf("Output %d of %s is not in the proof", idx, p.Address)
+	}
+	return nil
+}
+
+func checkAddress(p *Pop.Pop, address string) error {
+	if p.Address != address {
+		return fmt.Errorf("Popcode %s is not the popcode %s of the transaction", p.Address, address)
+	}
+	return nil
+}
+
+func int32s(amounts []int32) []int {
+	converted := make([]int, len(amounts))
+	for i, amount := range amounts {
+		converted[i] = int(amount)
+	}
+	return converted
+}
+
+// replay runs the transaction of step on the popcodes it read, in the order
+// the chaincode recorded them, and returns the popcodes as it left them.
+func replay(step *TuxedoPopsStore.ProofStep) ([]*Pop.Pop, error) {
+	pops := make([]*Pop.Pop, len(step.Popcodes))
+	for i, popcode := range step.Popcodes {
+		p, err := restore(popcode)
+		if err != nil {
+			return nil, err
+		}
+		pops[i] = p
+	}
+	count := map[string]int{"create": 1, "transfer": 1, "unitize": 2, "combine": 1, "burn": 1, "swap": 2,
+		"timeLock": 1, "hashLock": 1, "claim": 1, "refund": 1}
+	if n, ok := count[step.Function]; ok && len(pops) != n {
+		return nil, fmt.Errorf("%s step has %d popcodes", step.Function, len(pops))
+	}
+	if len(pops) == 0 {
+		return nil, fmt.Errorf("%s step has no popcodes", step.Function)
+	}
+
+	var err error
+	switch step.Function {
+	case "create":
+		tx := TuxedoPopsTX.CreateTX{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		// the issuers of registered types are not part of the proof
+		err = pops[0].CreateOutput(int(tx.Amount), tx.Type, tx.Data, tx.CreatorPubKey, tx.CreatorSig, nil, int(tx.Version))
+	case "transfer":
+		tx := TuxedoPopsTX.TransferOwners{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.Output); err != nil {
+			break
+		}
+		if len(tx.Owners) < int(tx.Threshold) {
+			err = fmt.Errorf("threshold value (%d) is larger than number of owners (%d)", tx.Threshold, len(tx.Owners))
+			break
+		}
+		err = pops[0].SetOwner(int(tx.Output), int(tx.Threshold), tx.Data, tx.Owners, tx.PrevOwnerSigs, tx.PopcodePubKey, tx.PopcodeSig,
+			step.Time, int(tx.Version))
+	case "unitize":
+		tx := TuxedoPopsTX.Unitize{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.SourceAddress); err != nil {
+			break
+		}
+		if err = checkAddress(pops[1], tx.DestAddress); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.SourceOutput); err != nil {
+			break
+		}
+		err = pops[0].UnitizeOutput(int(tx.SourceOutput), int32s(tx.DestAmounts), tx.Data, pops[1], tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig,
+			step.Time, int(tx.Version))
+	case "multiUnitize":
+		tx := TuxedoPopsTX.MultiUnitize{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if len(pops) != len(tx.Destinations)+1 {
+			err = fmt.Errorf("multiUnitize step has %d popcodes for %d destinations", len(pops), len(tx.Destinations))
+			break
+		}
+		if err = checkAddress(pops[0], tx.SourceAddress); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.SourceOutput); err != nil {
+			break
+		}
+		amounts := make([][]int, len(tx.Destinations))
+		for i, destination := range tx.Destinations {
+			if err = checkAddress(pops[i+1], destination.DestAddress); err != nil {
+				break
+			}
+			amounts[i] = int32s(destination.DestAmounts)
+		}
+		if err != nil {
+			break
+		}
+		err = pops[0].MultiUnitizeOutput(int(tx.SourceOutput), pops[1:], amounts, tx.KeepChange, tx.Data, tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig,
+			step.Time, int(tx.Version))
+	case "combine":
+		tx := TuxedoPopsTX.Combine{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if step.Recipe == nil {
+			err = fmt.Errorf("combine step has no recipe")
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		sources := make([]Pop.SourceOutput, len(tx.Sources))
+		for i, source := range tx.Sources {
+			if err = checkOutput(pops[0], source.SourceOutput); err != nil {
+				break
+			}
+			sources[i] = source
+		}
+		if err != nil {
+			break
+		}
+		err = pops[0].CombineOutputs(sources, tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig, int(tx.Amount), tx.Recipe, int(tx.RecipeVersion),
+			*step.Recipe, tx.Data, tx.CreatorPubKey, tx.CreatorSig, tx.ApprovalSigs, tx.ReturnChange, step.Time, int(tx.Version))
+	case "multiCombine":
+		tx := TuxedoPopsTX.MultiCombine{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if step.Recipe == nil {
+			err = fmt.Errorf("multiCombine step has no recipe")
+			break
+		}
+		err = replayMultiCombine(&tx, step, pops)
+	case "burn":
+		tx := TuxedoPopsTX.Burn{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.Output); err != nil {
+			break
+		}
+		err = pops[0].BurnOutput(int(tx.Output), int(tx.Amount), tx.Redemption, tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig,
+			step.Time, int(tx.Version))
+	case "swap":
+		tx := TuxedoPopsTX.Swap{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if tx.A == nil || tx.B == nil {
+			err = fmt.Errorf("swap needs two sides")
+			break
+		}
+		for i, side := range []*TuxedoPopsTX.SwapSide{tx.A, tx.B} {
+			if err = checkAddress(pops[i], side.Address); err != nil {
+				break
+			}
+			if err = checkOutput(pops[i], side.Output); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+		err = pops[0].SwapOutputs(int(tx.A.Output), int(tx.A.Amount), tx.A.OwnerSigs, tx.A.PopcodePubKey, tx.A.PopcodeSig,
+			pops[1], int(tx.B.Output), int(tx.B.Amount), tx.B.OwnerSigs, tx.B.PopcodePubKey, tx.B.PopcodeSig,
+			tx.Data, step.Time, int(tx.Version))
+	case "timeLock":
+		tx := TuxedoPopsTX.TimeLock{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.Output); err != nil {
+			break
+		}
+		err = pops[0].TimeLockOutput(int(tx.Output), int(tx.Amount), tx.Start, tx.End, tx.Period, tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig,
+			step.Time, int(tx.Version))
+	case "hashLock":
+		tx := TuxedoPopsTX.HashLock{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.Output); err != nil {
+			break
+		}
+		err = pops[0].LockOutput(int(tx.Output), int(tx.Amount), tx.Hash, tx.Expiry, tx.Recipient, tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig,
+			step.Time, int(tx.Version))
+	case "claim":
+		tx := TuxedoPopsTX.Claim{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.Output); err != nil {
+			break
+		}
+		err = pops[0].ClaimOutput(int(tx.Output), tx.Preimage, tx.RecipientSig, step.Time, int(tx.Version))
+	case "refund":
+		tx := TuxedoPopsTX.Refund{}
+		if err = proto.Unmarshal(step.Tx, &tx); err != nil {
+			break
+		}
+		if err = checkAddress(pops[0], tx.Address); err != nil {
+			break
+		}
+		if err = checkOutput(pops[0], tx.Output); err != nil {
+			break
+		}
+		err = pops[0].RefundOutput(int(tx.Output), tx.OwnerSigs, tx.PopcodePubKey, tx.PopcodeSig, step.Time, int(tx.Version))
+	default:
+		err = fmt.Errorf("unknown operation %s", step.Function)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pops, nil
+}
+
+// replayMultiCombine groups the sources of tx by popcode the way the
+// chaincode does. The step holds the signers' popcodes in that order followed
+// by the destination when it is not a signer.
+func replayMultiCombine(tx *TuxedoPopsTX.MultiCombine, step *TuxedoPopsStore.ProofStep, pops []*Pop.Pop) error {
+	signerArgs := make(map[string]*TuxedoPopsTX.CombineSigner)
+	for _, signer := range tx.Signers {
+		signerArgs[signer.Address] = signer
+	}
+	signers := []Pop.CombineSigner{}
+	signerIdx := make(map[string]int)
+	for _, source := range tx.Sources {
+		i, ok := signerIdx[source.Address]
+		if !ok {
+			signer, ok := signerArgs[source.Address]
+			if !ok {
+				return fmt.Errorf("No signatures for source popcode %s", source.Address)
+			}
+			i = len(signers)
+			if i >= len(pops) {
+				return fmt.Errorf("multiCombine step is missing popcode %s", source.Address)
+			}
+			err := checkAddress(pops[i], source.Address)
+			if err != nil {
+				return err
+			}
+			signerIdx[source.Address] = i
+			signers = append(signers, Pop.CombineSigner{Pop: pops[i], OwnerSigs: signer.OwnerSigs, PopPubKey: signer.PopcodePubKey, PopSig: signer.PopcodeSig})
+		}
+		err := checkOutput(signers[i].Pop, source.Output)
+		if err != nil {
+			return err
+		}
+		signers[i].Sources = append(signers[i].Sources, &TuxedoPopsTX.CombineSources{SourceOutput: source.Output, SourceAmount: source.Amount})
+	}
+	if len(signers) != len(tx.Signers) {
+		return fmt.Errorf("Every signer of a combine must have sources")
+	}
+
+	var dest *Pop.Pop
+	if i, ok := signerIdx[tx.Destination]; ok {
+		dest = signers[i].Pop
+		if len(pops) != len(signers) {
+			return fmt.Errorf("multiCombine step has %d popcodes for %d signers", len(pops), len(signers))
+		}
+	} else {
+		if len(pops) != len(signers)+1 {
+			return fmt.Errorf("multiCombine step has %d popcodes for %d signers", len(pops), len(signers))
+		}
+		dest = pops[len(signers)]
+		err := checkAddress(dest, tx.Destination)
+		if err != nil {
+			return err
+		}
+	}
+	return dest.CombineAcross(signers, int(tx.Amount), tx.Recipe, int(tx.RecipeVersion), *step.Recipe, tx.Data, tx.CreatorPubKey, tx.CreatorSig,
+		tx.ApprovalSigs, tx.ReturnChange, step.Time, int(tx.Version))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/client"
+	"github.com/skuchain/TuxedoPops/proof"
+)
+
+func getProof(t *testing.T, stub *shim.MockStub, args ...string) proof.Bundle {
+	proofBytes, err := stub.MockQuery("proof", args)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	bundle := proof.Bundle{}
+	err = json.Unmarshal(proofBytes, &bundle)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	return bundle
+}
+
+func TestProof(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	miller, _ := btcec.NewPrivateKey(btcec.S256())
+	baker, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{
+		{Numerator: 2, Denominator: 1, Type: "Flour"}, {Numerator: 1, Denominator: 1, Type: "Water"}}}, baker)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 10, Type: "Flour"}, miller)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{baker.PubKey()}}, nil, popcodeA)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: addressB, DestAmounts: []int{4}}, []*btcec.PrivateKey{baker}, popcodeA)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: addressB, Amount: 5, Type: "Water"}, baker)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 4}, {Output: 1, Amount: 2}}, Amount: 2, Recipe: "Loaf"},
+		baker, []*btcec.PrivateKey{baker}, popcodeB)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	bread := getProof(t, stub, addressB, "1")
+	if len(bread.Records) != 5 || len(bread.Steps) != 5 {
+		HandleError(t, fmt.Errorf("unexpected bundle of %d records and %d steps", len(bread.Records), len(bread.Steps)))
+		t.FailNow()
+	}
+	origins, err := proof.Verify(&bread)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if len(origins) != 2 {
+		HandleError(t, fmt.Errorf("unexpected origins %v", origins))
+	}
+	if flour := getProof(t, stub, addressA, "0"); len(flour.Records) != 2 {
+		HandleError(t, fmt.Errorf("unexpected bundle of the remaining flour %+v", flour))
+	} else if _, err := proof.Verify(&flour); err != nil {
+		HandleError(t, err)
+	}
+
+	// a record must describe what its step produced
+	tampered := getProof(t, stub, addressB, "1")
+	lineage := TuxedoPopsStore.Lineage{}
+	proto.Unmarshal(tampered.Records[0].Lineage, &lineage)
+	lineage.Type = "Cake"
+	tampered.Records[0].Lineage, _ = proto.Marshal(&lineage)
+	if _, err := proof.Verify(&tampered); err == nil {
+		HandleError(t, fmt.Errorf("bundle with a changed record verified"))
+	}
+
+	if _, err := stub.MockQuery("proof", []string{"zz"}); err == nil {
+		HandleError(t, fmt.Errorf("proof of an invalid counter succeeded"))
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestCombineRatios(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	recipes := []client.Recipe{
+		// two units of flour for one loaf
+		{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{{Numerator: 2, Denominator: 1, Type: "Flour"}}},
+		// four sixths of a unit of flour per roll, left unreduced
+		{Name: "Roll", CreatedType: "Roll", Ingredients: []client.Ingredient{{Numerator: 4, Denominator: 6, Type: "Flour"}}},
+	}
+	for _, recipe := range recipes {
+		recipeHex, _ := c.Recipe(recipe, creator)
+		if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+	}
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 100, Type: "Flour"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 10, Type: "Salt"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	flour := func() int64 {
+		total := int64(0)
+		for _, output := range getBalance(t, stub, &keyInfo{address: address}).Outputs {
+			if output.Type == "Flour" {
+				total += output.Amount
+			}
+		}
+		return total
+	}
+
+	// 5 units of flour are not exactly 2 loaves
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 5}}, Amount: 2, Recipe: "Loaf"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine consuming more than the ratio was accepted"))
+	}
+	// 2 units of flour make exactly 3 rolls even though 2/4 truncates to 0
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 2}}, Amount: 3, Recipe: "Roll"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if flour() != 98 {
+		HandleError(t, fmt.Errorf("expected 98 units of flour, have %d", flour()))
+	}
+	// sources that are not ingredients are not silently consumed
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 4}, {Output: 1, Amount: 1}}, Amount: 2, Recipe: "Loaf"},
+		creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine consuming a type outside the recipe was accepted"))
+	}
+	// a negative source would hand units back to the output, and a zero one
+	// would name an output without spending it
+	for _, sources := range [][]client.Source{{{Output: 0, Amount: 6}, {Output: 0, Amount: -2}}, {{Output: 0, Amount: 4}, {Output: 1, Amount: 0}}} {
+		combineHex, _ = c.Combine(client.Combine{Sources: sources, Amount: 2, Recipe: "Loaf"}, creator, nil, popcode)
+		if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+			HandleError(t, fmt.Errorf("combine of sources %+v was accepted", sources))
+		}
+	}
+
+	// with change the 1 unit beyond 2 loaves stays on the source
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 5}}, Amount: 2, Recipe: "Loaf", ReturnChange: true},
+		creator, nil, popcode)
+	events, err := invokeWithEvents(stub, "combine", []string{combineHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if flour() != 94 {
+		HandleError(t, fmt.Errorf("expected 94 units of flour, have %d", flour()))
+	}
+	combineEvent := TxEvents.CombineEvent{}
+	proto.Unmarshal(events["combine"], &combineEvent)
+	if combineEvent.Change != 1 || combineEvent.Waste != 2 {
+		HandleError(t, fmt.Errorf("unexpected change (%d) or waste (%d)", combineEvent.Change, combineEvent.Waste))
+	}
+
+	// 2 rolls need 4/3 units of flour, rounded up to 2, leaving 3 of 5
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 5}}, Amount: 2, Recipe: "Roll"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine of an inexact ratio was accepted"))
+	}
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 5}}, Amount: 2, Recipe: "Roll", ReturnChange: true},
+		creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if flour() != 92 {
+		HandleError(t, fmt.Errorf("expected 92 units of flour, have %d", flour()))
+	}
+
+	// change never covers a shortfall
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 3}}, Amount: 2, Recipe: "Loaf", ReturnChange: true},
+		creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine short of the ratio was accepted"))
+	}
+
+	// a combine signed without change cannot be replayed with it
+	counter, _ := c.Counter(address)
+	combineArgs, _ := client.Combine{Sources: []client.Source{{Output: 0, Amount: 5}}, Amount: 2, Recipe: "Loaf"}.Sign(counter, creator, nil, popcode)
+	combineArgs.ReturnChange = true
+	changedHex, _ := client.Encode(combineArgs)
+	if _, err := stub.MockInvoke("1", "combine", []string{changedHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine with an unsigned change flag was accepted"))
+	}
+}
+
+func TestCombineNeedsSources(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	// a recipe without ingredients, or with an empty ratio, makes something
+	// out of nothing
+	for _, recipe := range []client.Recipe{
+		{Name: "Air", CreatedType: "Bread"},
+		{Name: "Free", CreatedType: "Bread", Ingredients: []client.Ingredient{{Numerator: 0, Denominator: 1, Type: "Flour"}}},
+		{Name: "Undefined", CreatedType: "Bread", Ingredients: []client.Ingredient{{Numerator: 1, Denominator: 0, Type: "Flour"}}},
+	} {
+		recipeHex, _ := c.Recipe(recipe, creator)
+		if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+			HandleError(t, fmt.Errorf("recipe %s was registered", recipe.Name))
+		}
+	}
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{
+		{Numerator: 2, Denominator: 1, Type: "Flour"}}}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Flour"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	// without sources neither the owners nor the popcode sign anything
+	combineHex, _ := c.Combine(client.Combine{Amount: 2, Recipe: "Loaf"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine without sources was accepted"))
+	}
+	multiCombineHex, _ := c.MultiCombine(client.MultiCombine{Destination: address, Amount: 2, Recipe: "Loaf"}, creator, nil, nil)
+	if _, err := stub.MockInvoke("1", "multiCombine", []string{multiCombineHex}); err == nil {
+		HandleError(t, fmt.Errorf("multiCombine without sources was accepted"))
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+)
+
+// Version 1 of a recipe is stored under Recipe:<name>, where recipes were
+// stored before they were versioned, and records the latest version. Later
+// versions are stored under RecipeVersion:<version>:<name>.
+func recipeKey(name string, version int) string {
+	if version <= 1 {
+		return "Recipe:" + name
+	}
+	return fmt.Sprintf("RecipeVersion:%d:%s", version, name)
+}
+
+// getRecipe returns a version of a recipe or nil if it is not registered.
+func getRecipe(stub shim.ChaincodeStubInterface, name string, version int) (*TuxedoPopsStore.Recipe, error) {
+	recipeBytes, err := stub.GetState(recipeKey(name, version))
+	if err != nil {
+		fmt.Println("Could not get Recipe State")
+		return nil, errors.New("Could not get Recipe State")
+	}
+	if len(recipeBytes) == 0 {
+		return nil, nil
+	}
+	recipe := TuxedoPopsStore.Recipe{}
+	err = proto.Unmarshal(recipeBytes, &recipe)
+	if err != nil {
+		return nil, fmt.Errorf("Could not deserialize Recipe %s", name)
+	}
+	// recipes registered before versioning are version 1 and the latest
+	if recipe.Version == 0 {
+		recipe.Version = 1
+	}
+	if recipe.Version == 1 && recipe.Latest == 0 {
+		recipe.Latest = 1
+	}
+	return &recipe, nil
+}
+
+func putRecipe(stub shim.ChaincodeStubInterface, name string, recipe *TuxedoPopsStore.Recipe) error {
+	recipeBytes, err := proto.Marshal(recipe)
+	if err != nil {
+		fmt.Printf("Recipe Store Serialization error\n")
+		return fmt.Errorf("Recipe Store Serialization Error\n")
+	}
+	err = stub.PutState(recipeKey(name, int(recipe.Version)), recipeBytes)
+	if err != nil {
+		fmt.Printf("error putting recipe state to ledger: (%s)\n", err.Error())
+		return fmt.Errorf("error putting recipe state to ledger: (%s)\n", err.Error())
+	}
+	return nil
+}
+
+// combineRecipe returns the version of a recipe a combine runs against. A
+// pinned version can be used until it is revoked, otherwise the latest active
+// version is used.
+func combineRecipe(stub shim.ChaincodeStubInterface, name string, version int) (*TuxedoPopsStore.Recipe, error) {
+	first, err := getRecipe(stub, name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		fmt.Printf("Recipe %s not registered", name)
+		return nil, fmt.Errorf("Recipe %s is not registered", name)
+	}
+	if version > 0 {
+		recipe := first
+		if version > 1 {
+			recipe, err = getRecipe(stub, name, version)
+			if err != nil {
+				return nil, err
+			}
+			if recipe == nil {
+				return nil, fmt.Errorf("Recipe %s has no version %d", name, version)
+			}
+		}
+		if recipe.Status == Pop.RecipeRevoked {
+			return nil, fmt.Errorf("Recipe %s version %d has been revoked", name, version)
+		}
+		return recipe, nil
+	}
+	for v := int(first.Latest); v > 1; v-- {
+		recipe, err := getRecipe(stub, name, v)
+		if err != nil {
+			return nil, err
+		}
+		if recipe != nil && recipe.Status == Pop.RecipeActive {
+			return recipe, nil
+		}
+	}
+	if first.Status == Pop.RecipeActive {
+		return first, nil
+	}
+	return nil, fmt.Errorf("Recipe %s has no active version", name)
+}
+
+// verifyCreatorSig checks that sigBytes is the signature of message by the
+// key in pubKeyBytes.
+func verifyCreatorSig(pubKeyBytes []byte, sigBytes []byte, message []byte) (*btcec.PublicKey, error) {
+	creatorPubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("Could not deserialize Creator Pub Key (%v)", pubKeyBytes)
+	}
+	creatorSig, err := btcec.ParseDERSignature(sigBytes, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("Could not deserialize Creator Signature (%v)", sigBytes)
+	}
+	messageBytes := sha256.Sum256(message)
+	if !creatorSig.Verify(messageBytes[:], creatorPubKey) {
+		return nil, fmt.Errorf("Invalid Creator Signature (%+v)\n", creatorSig)
+	}
+	return creatorPubKey, nil
+}
+
+// isCreator reports whether pubKey is the creator stored with recipe.
+func isCreator(recipe *TuxedoPopsStore.Recipe, pubKey *btcec.PublicKey) bool {
+	creator, err := btcec.ParsePubKey(recipe.Creator, btcec.S256())
+	if err != nil {
+		return false
+	}
+	return creator.IsEqual(pubKey)
+}
+
+func (t *tuxedoPopsChaincode) recipeStatus(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	statusArgs := TuxedoPopsTX.RecipeStatus{}
+	err := proto.Unmarshal(argsBytes, &statusArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected RecipeStatus protocol buffer")
+		return fmt.Errorf("Invalid argument expected RecipeStatus protocol buffer %s", err.Error())
+	}
+	err = Pop.CheckCanonical(int(statusArgs.Version))
+	if err != nil {
+		return err
+	}
+	status := int(statusArgs.Status)
+	if status != Pop.RecipeDeprecated && status != Pop.RecipeRevoked {
+		return fmt.Errorf("Invalid recipe status %d", status)
+	}
+	version := int(statusArgs.RecipeVersion)
+	if version < 0 {
+		return fmt.Errorf("Invalid version %d of Recipe (%s)", version, statusArgs.RecipeName)
+	}
+	if version == 0 {
+		version = 1
+	}
+	recipe, err := getRecipe(stub, statusArgs.RecipeName, version)
+	if err != nil {
+		return err
+	}
+	if recipe == nil {
+		return fmt.Errorf("Recipe (%s) version %d is not registered", statusArgs.RecipeName, version)
+	}
+
+	message := Pop.RecipeStatusMessage(int(statusArgs.Version), statusArgs.RecipeName, int(statusArgs.RecipeVersion), status)
+	creatorPubKey, err := verifyCreatorSig(statusArgs.CreatorPubKey, statusArgs.CreatorSig, message)
+	if err != nil {
+		return err
+	}
+	if !isCreator(recipe, creatorPubKey) {
+		return fmt.Errorf("Only the creator of recipe (%s) can change its status", statusArgs.RecipeName)
+	}
+	// a version can be deprecated and then revoked but never reinstated
+	if int(recipe.Status) >= status {
+		return fmt.Errorf("Recipe (%s) version %d is already %s", statusArgs.RecipeName, version, Pop.RecipeStatusName(int(recipe.Status)))
+	}
+	recipe.Status = int32(status)
+	err = putRecipe(stub, statusArgs.RecipeName, recipe)
+	if err != nil {
+		return err
+	}
+
+	statusEvent := TxEvents.RecipeStatusEvent{}
+	statusEvent.RecipeName = statusArgs.RecipeName
+	statusEvent.RecipeVersion = int32(version)
+	statusEvent.Status = int32(status)
+	statusEvent.CreatorPubKey = statusArgs.CreatorPubKey
+	statusEventBytes, err := proto.Marshal(&statusEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("recipeStatus", statusEventBytes)
+	return nil
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type recipeJSON struct {
+	CreatedType string
+	Version     int
+	Status      string
+	Latest      int
+}
+
+func getRecipeJSON(t *testing.T, stub *shim.MockStub, args ...string) recipeJSON {
+	recipe := recipeJSON{}
+	bytes, err := stub.MockQuery("recipe", args)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	err = json.Unmarshal(bytes, &recipe)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	return recipe
+}
+
+func TestRecipeVersions(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	other, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 20, Type: "Water"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	v1 := client.Recipe{Name: "Brew", CreatedType: "Coffee", Ingredients: []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Water"}}}
+	recipeHex, _ := c.Recipe(v1, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("recipe was registered twice"))
+	}
+
+	// a second version fixes the ratio
+	v2 := client.Recipe{Name: "Brew", RecipeVersion: 2, CreatedType: "Coffee", Ingredients: []client.Ingredient{{Numerator: 2, Denominator: 1, Type: "Water"}}}
+	recipeHex, _ = c.Recipe(v2, other)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("new version by someone other than the creator was accepted"))
+	}
+	v3 := v2
+	v3.RecipeVersion = 3
+	recipeHex, _ = c.Recipe(v3, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("out of order version was accepted"))
+	}
+	recipeHex, _ = c.Recipe(v2, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if recipe := getRecipeJSON(t, stub, "Brew"); recipe.Version != 2 || recipe.Latest != 2 || recipe.Status != "active" {
+		HandleError(t, fmt.Errorf("unexpected latest recipe %v", recipe))
+	}
+	if recipe := getRecipeJSON(t, stub, "Brew", "1"); recipe.Version != 1 || recipe.Latest != 2 {
+		HandleError(t, fmt.Errorf("unexpected first recipe %v", recipe))
+	}
+
+	combine := func(amount int, source int, recipeVersion int) (TxEvents.CombineEvent, error) {
+		combineEvent := TxEvents.CombineEvent{}
+		tx := client.Combine{Sources: []client.Source{{Output: 0, Amount: source}}, Amount: amount, Recipe: "Brew", RecipeVersion: recipeVersion}
+		combineHex, _ := c.Combine(tx, creator, nil, popcode)
+		events, err := invokeWithEvents(stub, "combine", []string{combineHex})
+		if err != nil {
+			return combineEvent, err
+		}
+		err = proto.Unmarshal(events["combine"], &combineEvent)
+		return combineEvent, err
+	}
+
+	// unpinned combines use the latest version
+	if event, err := combine(1, 2, 0); err != nil || event.RecipeVersion != 2 {
+		HandleError(t, fmt.Errorf("unpinned combine did not use version 2: %v %v", err, event))
+	}
+	if event, err := combine(1, 1, 1); err != nil || event.RecipeVersion != 1 {
+		HandleError(t, fmt.Errorf("combine pinned to version 1 failed: %v %v", err, event))
+	}
+
+	// only the creator can change the status of a version
+	statusHex, _ := c.RecipeStatus(client.RecipeStatus{Name: "Brew", RecipeVersion: 1, Status: Pop.RecipeRevoked}, other)
+	if _, err := stub.MockInvoke("1", "recipeStatus", []string{statusHex}); err == nil {
+		HandleError(t, fmt.Errorf("revoke by someone other than the creator was accepted"))
+	}
+
+	// deprecated versions are skipped unless pinned
+	statusHex, _ = c.RecipeStatus(client.RecipeStatus{Name: "Brew", RecipeVersion: 2, Status: Pop.RecipeDeprecated}, creator)
+	if _, err := stub.MockInvoke("1", "recipeStatus", []string{statusHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if event, err := combine(1, 1, 0); err != nil || event.RecipeVersion != 1 {
+		HandleError(t, fmt.Errorf("unpinned combine did not fall back to version 1: %v %v", err, event))
+	}
+	if _, err := combine(1, 2, 2); err != nil {
+		HandleError(t, fmt.Errorf("combine pinned to a deprecated version failed: %v", err))
+	}
+
+	// revoked versions can not be used at all
+	statusHex, _ = c.RecipeStatus(client.RecipeStatus{Name: "Brew", RecipeVersion: 1, Status: Pop.RecipeRevoked}, creator)
+	if _, err := stub.MockInvoke("1", "recipeStatus", []string{statusHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if _, err := stub.MockInvoke("1", "recipeStatus", []string{statusHex}); err == nil {
+		HandleError(t, fmt.Errorf("recipe was revoked twice"))
+	}
+	if _, err := combine(1, 1, 1); err == nil {
+		HandleError(t, fmt.Errorf("combine pinned to a revoked version was accepted"))
+	}
+	if _, err := combine(1, 2, 0); err == nil {
+		HandleError(t, fmt.Errorf("unpinned combine without an active version was accepted"))
+	}
+	if recipe := getRecipeJSON(t, stub, "Brew", "1"); recipe.Status != "revoked" {
+		HandleError(t, fmt.Errorf("unexpected revoked recipe %v", recipe))
+	}
+
+	// outputs made under the revoked version are kept
+	balance := getBalance(t, stub, &keyInfo{address: address})
+	coffee := int64(0)
+	for _, output := range balance.Outputs {
+		if output.Type == "Coffee" {
+			coffee += output.Amount
+		}
+	}
+	if coffee != 4 {
+		HandleError(t, fmt.Errorf("unexpected balance after revocation %v", balance))
+	}
+}
+
+func TestRecipeNegativeVersion(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	other, _ := btcec.NewPrivateKey(btcec.S256())
+	ingredients := []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Water"}}
+
+	// a negative version of a name nobody registered has no first version
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Tea", RecipeVersion: -1, CreatedType: "Tea", Ingredients: ingredients}, other)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("recipe with a negative version was accepted"))
+	}
+
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Brew", CreatedType: "Coffee", Ingredients: ingredients}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	// a negative version was stored as the first version, replacing its creator
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Brew", RecipeVersion: -1, CreatedType: "Poison", Ingredients: ingredients}, other)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("recipe overwritten with a negative version"))
+	}
+	statusHex, _ := c.RecipeStatus(client.RecipeStatus{Name: "Brew", RecipeVersion: -1, Status: Pop.RecipeRevoked}, creator)
+	if _, err := stub.MockInvoke("1", "recipeStatus", []string{statusHex}); err == nil {
+		HandleError(t, fmt.Errorf("status of a negative version was accepted"))
+	}
+	if recipe := getRecipeJSON(t, stub, "Brew"); recipe.CreatedType != "Coffee" || recipe.Latest != 1 || recipe.Status != "active" {
+		HandleError(t, fmt.Errorf("unexpected recipe %+v", recipe))
+	}
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Brew", RecipeVersion: 2, CreatedType: "Coffee", Ingredients: ingredients}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, fmt.Errorf("creator could not register version 2: %v", err))
+	}
+}
+
+func TestRecipeByproducts(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Wheat"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	// milling 10 wheat gives 7 flour, 2 bran and 1 unit of waste
+	mill := client.Recipe{Name: "Mill", CreatedType: "Flour",
+		Ingredients: []client.Ingredient{{Numerator: 10, Denominator: 7, Type: "Wheat"}},
+		Byproducts:  []client.Byproduct{{Numerator: 0, Denominator: 7, Type: "Bran"}}}
+	recipeHex, _ := c.Recipe(mill, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("byproduct with a zero ratio was accepted"))
+	}
+	mill.Byproducts[0].Numerator = 2
+	recipeHex, _ = c.Recipe(mill, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 10}}, Amount: 7, Recipe: "Mill"}, creator, nil, popcode)
+	events, err := invokeWithEvents(stub, "combine", []string{combineHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	balance := getBalance(t, stub, &keyInfo{address: address})
+	if len(balance.Outputs) != 2 || balance.Outputs[0].Type != "Flour" || balance.Outputs[0].Amount != 7 ||
+		balance.Outputs[1].Type != "Bran" || balance.Outputs[1].Amount != 2 {
+		HandleError(t, fmt.Errorf("unexpected balance after milling %v", balance))
+		t.FailNow()
+	}
+
+	combineEvent := TxEvents.CombineEvent{}
+	proto.Unmarshal(events["combine"], &combineEvent)
+	if combineEvent.Waste != 1 || len(combineEvent.Products) != 2 ||
+		combineEvent.Products[0].Type != "Flour" || combineEvent.Products[1].Amount != 2 {
+		HandleError(t, fmt.Errorf("unexpected combine event %v", combineEvent))
+		t.FailNow()
+	}
+	for i, product := range combineEvent.Products {
+		if fmt.Sprintf("%x", product.DestCounter) != balance.Outputs[i].PrevCounter {
+			HandleError(t, fmt.Errorf("combine event counter of %s does not match its output", product.Type))
+		}
+	}
+
+	// 10 flour would make 100 billion units of dust, more than an output holds
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 10, Type: "Flour"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	sift := client.Recipe{Name: "Sift", CreatedType: "Flour",
+		Ingredients: []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Flour"}},
+		Byproducts:  []client.Byproduct{{Numerator: 1000000000, Denominator: 1, Type: "Dust"}}}
+	recipeHex, _ = c.Recipe(sift, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 2, Amount: 10}}, Amount: 10, Recipe: "Sift"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine minting a byproduct beyond an int32 was accepted"))
+	}
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 2, Amount: 2}}, Amount: 2, Recipe: "Sift"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+	}
+}
+
+func TestRecipeManufacturers(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	brandOwner, _ := btcec.NewPrivateKey(btcec.S256())
+	bottlerA, _ := btcec.NewPrivateKey(btcec.S256())
+	bottlerB, _ := btcec.NewPrivateKey(btcec.S256())
+	outsider, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+	bottlers := []*btcec.PublicKey{bottlerA.PubKey(), bottlerB.PubKey()}
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Syrup"}, brandOwner)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	ingredients := []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Syrup"}}
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Soda", CreatedType: "BrandedSoda", Ingredients: ingredients,
+		Manufacturers: bottlers, Threshold: 3}, brandOwner)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("threshold above the number of manufacturers was accepted"))
+	}
+	// only licensed bottlers can make BrandedSoda
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Soda", CreatedType: "BrandedSoda", Ingredients: ingredients,
+		Manufacturers: bottlers}, brandOwner)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	soda := client.Combine{Sources: []client.Source{{Output: 0, Amount: 1}}, Amount: 1, Recipe: "Soda"}
+	combineHex, _ := c.Combine(soda, outsider, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("combine by an unlicensed creator was accepted"))
+	}
+	combineHex, _ = c.Combine(soda, bottlerB, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+	}
+
+	// a special edition needs both bottlers
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Reserve", CreatedType: "ReserveSoda", Ingredients: ingredients,
+		Manufacturers: bottlers, Threshold: 2}, brandOwner)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	reserve := client.Combine{Sources: []client.Source{{Output: 0, Amount: 1}}, Amount: 1, Recipe: "Reserve"}
+	combine := func(approvers ...*btcec.PrivateKey) error {
+		counter, _ := c.Counter(address)
+		msg, _ := reserve.Sign(counter, bottlerA, nil, popcode)
+		for _, approver := range approvers {
+			approval, _ := reserve.Approve(counter, approver)
+			msg.ApprovalSigs = append(msg.ApprovalSigs, approval)
+		}
+		combineHex, _ := client.Encode(msg)
+		_, err := stub.MockInvoke("1", "combine", []string{combineHex})
+		return err
+	}
+	if err := combine(); err == nil {
+		HandleError(t, fmt.Errorf("combine by a single manufacturer was accepted"))
+	}
+	if err := combine(bottlerA); err == nil {
+		HandleError(t, fmt.Errorf("creator approving its own combine was counted twice"))
+	}
+	if err := combine(outsider); err == nil {
+		HandleError(t, fmt.Errorf("approval by an unlisted key was counted"))
+	}
+	if err := combine(outsider, bottlerB); err != nil {
+		HandleError(t, err)
+	}
+}
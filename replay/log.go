@@ -0,0 +1,186 @@
+package replay
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Event is a chaincode event as delivered to event consumers, with the id of
+// the transaction that emitted it when it is known.
+type Event struct {
+	Name    string
+	Payload []byte
+	TxID    string
+}
+
+// LogReader reads an event log: a stream of JSON objects with the Name of each
+// event, its Payload in hex and optionally its TxID, in the order the events
+// were emitted. Events are read as they are written, so the log can be a pipe.
+type LogReader struct {
+	decoder *json.Decoder
+	read    int
+}
+
+// NewLogReader returns a LogReader reading from r.
+func NewLogReader(r io.Reader) *LogReader {
+	return &LogReader{decoder: json.NewDecoder(r)}
+}
+
+// Next returns the next event of the log, or io.EOF after the last one.
+func (l *LogReader) Next() (Event, error) {
+	entry := jsonEvent{}
+	err := l.decoder.Decode(&entry)
+	if err == io.EOF {
+		return Event{}, err
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("Invalid event %d: %s", l.read, err.Error())
+	}
+	payload, err := hex.DecodeString(entry.Payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("Invalid payload of event %d (%s)", l.read, entry.Name)
+	}
+	l.read++
+	return Event{Name: entry.Name, Payload: payload, TxID: entry.TxID}, nil
+}
+
+// ReadLog reads all of the events of a log.
+func ReadLog(r io.Reader) ([]Event, error) {
+	events := []Event{}
+	log := NewLogReader(r)
+	for {
+		event, err := log.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+// WriteEvent writes event to w in the form read by LogReader.
+func WriteEvent(w io.Writer, event Event) error {
+	return json.NewEncoder(w).Encode(jsonEvent{Name: event.Name, Payload: hex.EncodeToString(event.Payload), TxID: event.TxID})
+}
+
+type jsonEvent struct {
+	Name    string
+	Payload string
+	TxID    string `json:",omitempty"`
+}
+
+// Replay applies events to a new state in order.
+func Replay(events []Event) (*State, error) {
+	s := NewState()
+	for i, event := range events {
+		err := s.Apply(event.Name, event.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("Event %d (%s): %s", i, event.Name, err.Error())
+		}
+	}
+	return s, nil
+}
+
+// Popcode is a popcode as returned by the balance query, whose Outputs are
+// themselves JSON.
+type Popcode struct {
+	Address string
+	Counter string
+	Outputs []string
+}
+
+// ReadSnapshot reads a ledger snapshot: a stream of balance query results,
+// keyed by address.
+func ReadSnapshot(r io.Reader) (map[string]Popcode, error) {
+	snapshot := make(map[string]Popcode)
+	decoder := json.NewDecoder(r)
+	for {
+		popcode := Popcode{}
+		err := decoder.Decode(&popcode)
+		if err == io.EOF {
+			return snapshot, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid popcode %d in snapshot: %s", len(snapshot), err.Error())
+		}
+		snapshot[popcode.Address] = popcode
+	}
+}
+
+// Balances returns the replayed popcodes in the form of the balance query,
+// ordered by address.
+func (s *State) Balances() []Popcode {
+	addresses := []string{}
+	for address := range s.Popcodes {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	popcodes := []Popcode{}
+	for _, address := range addresses {
+		popcode := Popcode{}
+		json.Unmarshal(s.Popcodes[address].ToJSON(), &popcode)
+		popcodes = append(popcodes, popcode)
+	}
+	return popcodes
+}
+
+// Divergence is a difference between the replayed state of a popcode and a
+// ledger snapshot.
+type Divergence struct {
+	Address string
+	Reason  string
+}
+
+func (d Divergence) String() string {
+	return d.Address + ": " + d.Reason
+}
+
+// Diff compares the replayed popcodes of s with snapshot, ordered by address.
+func (s *State) Diff(snapshot map[string]Popcode) []Divergence {
+	divergences := []Divergence{}
+	replayed := make(map[string]bool)
+	for _, popcode := range s.Balances() {
+		replayed[popcode.Address] = true
+		ledger, ok := snapshot[popcode.Address]
+		if !ok {
+			divergences = append(divergences, Divergence{popcode.Address, "not in the ledger snapshot"})
+			continue
+		}
+		divergences = append(divergences, diffPopcode(popcode, ledger)...)
+	}
+	missing := []string{}
+	for address := range snapshot {
+		if !replayed[address] {
+			missing = append(missing, address)
+		}
+	}
+	sort.Strings(missing)
+	for _, address := range missing {
+		divergences = append(divergences, Divergence{address, "not in the event log"})
+	}
+	return divergences
+}
+
+func diffPopcode(replayed Popcode, ledger Popcode) []Divergence {
+	divergences := []Divergence{}
+	if replayed.Counter != ledger.Counter {
+		divergences = append(divergences, Divergence{replayed.Address,
+			fmt.Sprintf("counter is %s, ledger has %s", replayed.Counter, ledger.Counter)})
+	}
+	if len(replayed.Outputs) != len(ledger.Outputs) {
+		divergences = append(divergences, Divergence{replayed.Address,
+			fmt.Sprintf("%d outputs, ledger has %d", len(replayed.Outputs), len(ledger.Outputs))})
+	}
+	for i := 0; i < len(replayed.Outputs) && i < len(ledger.Outputs); i++ {
+		if replayed.Outputs[i] != ledger.Outputs[i] {
+			divergences = append(divergences, Divergence{replayed.Address,
+				fmt.Sprintf("output %d is %s, ledger has %s", i, replayed.Outputs[i], ledger.Outputs[i])})
+		}
+	}
+	return divergences
+}
@@ -0,0 +1,645 @@
+// Package replay rebuilds the popcodes of a TuxedoPops ledger from the events
+// its chaincode emitted, for recovery and for auditing the ledger against an
+// independent copy of its state.
+//
+// Events are not signed and are applied without checking signatures, ratios
+// or locks. What is checked is that the sequence number in the envelope of
+// every event follows the last one, and that every event spends the outputs
+// and counters the replayed state has at that point, so a log that is
+// incomplete, out of order or inconsistent with itself fails at the first
+// event that does not fit.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/OTX"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+)
+
+// EnvelopeVersion is the version of TxEvents.Envelope understood.
+const EnvelopeVersion = 1
+
+// State is the popcodes rebuilt so far, keyed by address, and the sequence
+// number of the last event applied.
+type State struct {
+	Popcodes map[string]*Pop.Pop
+	Sequence uint64
+}
+
+// NewState returns the state of an empty ledger.
+func NewState() *State {
+	return &State{Popcodes: make(map[string]*Pop.Pop)}
+}
+
+// Unwrap decodes the envelope of the event name, as passed to SetEvent by the
+// chaincode.
+func Unwrap(name string, payload []byte) (*TxEvents.Envelope, error) {
+	envelope := &TxEvents.Envelope{}
+	err := proto.Unmarshal(payload, envelope)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid envelope of %s event: %s", name, err.Error())
+	}
+	if envelope.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("Unsupported envelope version %d of %s event", envelope.Version, name)
+	}
+	if envelope.Function != name {
+		return nil, fmt.Errorf("Envelope of %s event is from function %s", name, envelope.Function)
+	}
+	return envelope, nil
+}
+
+// Follows checks that envelope is the next event after those applied to s.
+func (s *State) Follows(envelope *TxEvents.Envelope) error {
+	if envelope.Sequence != s.Sequence+1 {
+		return fmt.Errorf("Event %d does not follow event %d", envelope.Sequence, s.Sequence)
+	}
+	return nil
+}
+
+// Apply applies the event name with payload, as passed to SetEvent by the
+// chaincode, to s. Events must be applied in the order they were emitted,
+// starting from the first. s is left partly updated when Apply fails.
+func (s *State) Apply(name string, payload []byte) error {
+	envelope, err := Unwrap(name, payload)
+	if err != nil {
+		return err
+	}
+	err = s.Follows(envelope)
+	if err != nil {
+		return err
+	}
+	err = s.ApplyEvent(name, envelope.Payload)
+	if err != nil {
+		return err
+	}
+	s.Sequence = envelope.Sequence
+	return nil
+}
+
+// ApplyEvent applies the event name with the payload of its envelope to s.
+func (s *State) ApplyEvent(name string, payload []byte) error {
+	event, apply := s.handler(name)
+	if event == nil {
+		return fmt.Errorf("Unknown event %s", name)
+	}
+	err := proto.Unmarshal(payload, event)
+	if err != nil {
+		return fmt.Errorf("Invalid %s event: %s", name, err.Error())
+	}
+	return apply()
+}
+
+// handler returns the event message for name and the function applying it
+// once it has been decoded.
+func (s *State) handler(name string) (proto.Message, func() error) {
+	switch name {
+	case "create":
+		event := &TxEvents.CreateEvent{}
+		return event, func() error { return s.create(event) }
+	case "transfer":
+		event := &TxEvents.TransferEvent{}
+		return event, func() error { return s.transfer(event) }
+	case "unitize":
+		event := &TxEvents.UnitizeEvent{}
+		return event, func() error { return s.unitize(event) }
+	case "multiUnitize":
+		event := &TxEvents.MultiUnitizeEvent{}
+		return event, func() error { return s.multiUnitize(event) }
+	case "combine":
+		event := &TxEvents.CombineEvent{}
+		return event, func() error { return s.combine(event) }
+	case "multiCombine":
+		event := &TxEvents.MultiCombineEvent{}
+		return event, func() error { return s.multiCombine(event) }
+	case "burn":
+		event := &TxEvents.BurnEvent{}
+		return event, func() error { return s.burn(event) }
+	case "swap":
+		event := &TxEvents.SwapEvent{}
+		return event, func() error { return s.swap(event) }
+	case "hashLock":
+		event := &TxEvents.HashLockEvent{}
+		return event, func() error { return s.hashLock(event) }
+	case "claim":
+		event := &TxEvents.ClaimEvent{}
+		return event, func() error { return s.claim(event) }
+	case "refund":
+		event := &TxEvents.RefundEvent{}
+		return event, func() error { return s.refund(event) }
+	case "timeLock":
+		event := &TxEvents.TimeLockEvent{}
+		return event, func() error { return s.timeLock(event) }
+	case "batch":
+		event := &TxEvents.BatchEvent{}
+		return event, func() error { return s.batch(event) }
+	// registrations do not change any popcode
+	case "recipe":
+		return &TxEvents.RecipeEvent{}, func() error { return nil }
+	case "recipeStatus":
+		return &TxEvents.RecipeStatusEvent{}, func() error { return nil }
+	case "registerType":
+		return &TxEvents.RegisterTypeEvent{}, func() error { return nil }
+	}
+	return nil, nil
+}
+
+func (s *State) create(event *TxEvents.CreateEvent) error {
+	p, ok := s.Popcodes[event.Address]
+	if !ok {
+		// the counter of a new popcode comes from the chaincode's counter seed
+		p = &Pop.Pop{Address: event.Address, Counter: event.SourceCounter}
+	} else if !bytes.Equal(p.Counter, event.SourceCounter) {
+		return fmt.Errorf("Create on %s at counter %x, replayed counter is %x", p.Address, event.SourceCounter, p.Counter)
+	}
+	creator, err := btcec.ParsePubKey(event.CreatorPubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Creator key")
+	}
+	counter := next(p.Counter)
+	err = checkCounter(p, "Created output", event.DestCounter, counter)
+	if err != nil {
+		return err
+	}
+	s.Popcodes[p.Address] = p
+	p.Outputs = append(p.Outputs, *OTX.New(creator, int(event.Amount), event.Type, event.Data, counter))
+	p.Counter = next(counter)
+	return nil
+}
+
+func (s *State) transfer(event *TxEvents.TransferEvent) error {
+	p, output, err := s.spend(event.Address, event.Output, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(p, "Transferred output", event.DestCounter, p.Counter)
+	if err != nil {
+		return err
+	}
+	owners, err := parseKeys(event.Owners)
+	if err != nil {
+		return err
+	}
+	output.Owners = owners
+	output.Data = event.Data
+	output.Threshold = int(event.Threshold)
+	if output.Threshold <= 0 {
+		output.Threshold = len(owners)
+	}
+	moveCounter(p, output)
+	return nil
+}
+
+func (s *State) unitize(event *TxEvents.UnitizeEvent) error {
+	source, _, err := s.spend(event.SourceAddress, event.SourceOutput, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	if len(event.DestAmounts) != len(event.DestCounters) {
+		return fmt.Errorf("Unitize of %s has %d amounts and %d counters", source.Address, len(event.DestAmounts), len(event.DestCounters))
+	}
+	dest, err := s.dest(event.DestAddress, source.Counter)
+	if err != nil {
+		return err
+	}
+	// the destinations of a unitize event are listed last output first
+	for i := len(event.DestAmounts) - 1; i >= 0; i-- {
+		err = checkCounter(dest, "Unitized output", event.DestCounters[i], dest.Counter)
+		if err != nil {
+			return err
+		}
+		moveOutput(source, int(event.SourceOutput), int(event.DestAmounts[i]), dest, event.Data)
+	}
+	source.Counter = next(source.Counter)
+	return nil
+}
+
+func (s *State) multiUnitize(event *TxEvents.MultiUnitizeEvent) error {
+	source, output, err := s.spend(event.SourceAddress, event.SourceOutput, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	spent := *output
+	total := 0
+	for _, destination := range event.Destinations {
+		if len(destination.DestAmounts) != len(destination.DestCounters) {
+			return fmt.Errorf("Unitize to %s has %d amounts and %d counters", destination.DestAddress,
+				len(destination.DestAmounts), len(destination.DestCounters))
+		}
+		dest, err := s.dest(destination.DestAddress, source.Counter)
+		if err != nil {
+			return err
+		}
+		for i, amount := range destination.DestAmounts {
+			err = checkCounter(dest, "Unitized output", destination.DestCounters[i], dest.Counter)
+			if err != nil {
+				return err
+			}
+			destOut := spent
+			destOut.TimeLock = nil
+			destOut.Data = event.Data
+			destOut.Amount = int(amount)
+			moveCounter(dest, &destOut)
+			dest.Outputs = append(dest.Outputs, destOut)
+			total += int(amount)
+		}
+	}
+	if total > spent.Amount {
+		return fmt.Errorf("Unitize of %d units from output %d of %s holding %d", total, event.SourceOutput, source.Address, spent.Amount)
+	}
+	if total == spent.Amount {
+		source.Outputs = append(source.Outputs[:event.SourceOutput], source.Outputs[event.SourceOutput+1:]...)
+		source.Counter = next(source.Counter)
+		return nil
+	}
+	err = checkCounter(source, "Change", event.ChangeCounter, source.Counter)
+	if err != nil {
+		return err
+	}
+	output.Amount -= total
+	moveCounter(source, output)
+	return nil
+}
+
+func (s *State) combine(event *TxEvents.CombineEvent) error {
+	p, ok := s.Popcodes[event.Address]
+	if !ok {
+		return fmt.Errorf("Popcode %s is not in the log", event.Address)
+	}
+	if len(event.SourceCounters) != len(event.Sources) {
+		return fmt.Errorf("Combine on %s has %d sources and %d source counters", p.Address, len(event.Sources), len(event.SourceCounters))
+	}
+	sources := []source{}
+	for i, evSource := range event.Sources {
+		_, _, err := s.spend(p.Address, evSource.SourceOutput, event.SourceCounters[i])
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source{idx: int(evSource.SourceOutput), amount: int(evSource.SourceAmount)})
+	}
+	err := takeSources(p, sources)
+	if err != nil {
+		return err
+	}
+	returnChange(p, sources, returnedByType(event.Returned))
+	dropSpent(p)
+	return mintProducts(p, event.Products, event.CreatorPubKey, event.Data)
+}
+
+func (s *State) multiCombine(event *TxEvents.MultiCombineEvent) error {
+	// sources are grouped by popcode in the order the popcodes first appear
+	signers := []*Pop.Pop{}
+	sources := make(map[string][]source)
+	for _, evSource := range event.Sources {
+		p, _, err := s.spend(evSource.Address, evSource.Output, evSource.SourceCounter)
+		if err != nil {
+			return err
+		}
+		if _, ok := sources[p.Address]; !ok {
+			signers = append(signers, p)
+		}
+		sources[p.Address] = append(sources[p.Address], source{idx: int(evSource.Output), amount: int(evSource.Amount)})
+	}
+	if len(signers) == 0 || len(event.Products) == 0 {
+		return fmt.Errorf("Combine into %s has no sources or products", event.Destination)
+	}
+	dest, ok := s.Popcodes[event.Destination]
+	if !ok {
+		// a new destination is seeded from the chaincode's counter seed
+		dest = &Pop.Pop{Address: event.Destination, Counter: event.Products[0].DestCounter}
+		s.Popcodes[dest.Address] = dest
+	}
+
+	for _, p := range signers {
+		err := takeSources(p, sources[p.Address])
+		if err != nil {
+			return err
+		}
+		if p != dest {
+			p.Counter = next(p.Counter)
+		}
+	}
+	// change goes back to the last sources first
+	change := returnedByType(event.Returned)
+	for i := len(signers) - 1; i >= 0; i-- {
+		returnChange(signers[i], sources[signers[i].Address], change)
+		dropSpent(signers[i])
+	}
+	return mintProducts(dest, event.Products, event.CreatorPubKey, event.Data)
+}
+
+func (s *State) burn(event *TxEvents.BurnEvent) error {
+	p, output, err := s.spend(event.Address, event.Output, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	if int(event.Amount) > output.Amount {
+		return fmt.Errorf("Burn of %d units from output %d of %s holding %d", event.Amount, event.Output, p.Address, output.Amount)
+	}
+	if int(event.Amount) == output.Amount {
+		p.Outputs = append(p.Outputs[:event.Output], p.Outputs[event.Output+1:]...)
+		p.Counter = next(p.Counter)
+		return nil
+	}
+	err = checkCounter(p, "Remainder", event.DestCounter, p.Counter)
+	if err != nil {
+		return err
+	}
+	output.Amount -= int(event.Amount)
+	moveCounter(p, output)
+	return nil
+}
+
+func (s *State) swap(event *TxEvents.SwapEvent) error {
+	if event.A == nil || event.B == nil {
+		return fmt.Errorf("Swap event without two sides")
+	}
+	a, _, err := s.spend(event.A.Address, event.A.Output, event.A.SourceCounter)
+	if err != nil {
+		return err
+	}
+	b, _, err := s.spend(event.B.Address, event.B.Output, event.B.SourceCounter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(b, "Swapped output", event.A.DestCounter, b.Counter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(a, "Swapped output", event.B.DestCounter, a.Counter)
+	if err != nil {
+		return err
+	}
+	moveOutput(a, int(event.A.Output), int(event.A.Amount), b, event.Data)
+	moveOutput(b, int(event.B.Output), int(event.B.Amount), a, event.Data)
+	return nil
+}
+
+func (s *State) hashLock(event *TxEvents.HashLockEvent) error {
+	p, output, err := s.spend(event.Address, event.Output, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(p, "Locked output", event.DestCounter, p.Counter)
+	if err != nil {
+		return err
+	}
+	recipient, err := btcec.ParsePubKey(event.Recipient, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Recipient key")
+	}
+	lock := OTX.HashLock{Hash: event.Hash, Expiry: event.Expiry, Recipient: recipient}
+	locked, err := splitOutput(p, output, int(event.Amount))
+	if err != nil {
+		return err
+	}
+	if locked != output {
+		locked.TimeLock = nil
+	}
+	locked.HashLock = &lock
+	moveCounter(p, locked)
+	return nil
+}
+
+func (s *State) claim(event *TxEvents.ClaimEvent) error {
+	p, output, err := s.spend(event.Address, event.Output, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(p, "Claimed output", event.DestCounter, p.Counter)
+	if err != nil {
+		return err
+	}
+	recipient, err := btcec.ParsePubKey(event.Recipient, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Recipient key")
+	}
+	output.Owners = []btcec.PublicKey{*recipient}
+	output.Threshold = 1
+	output.HashLock = nil
+	moveCounter(p, output)
+	return nil
+}
+
+func (s *State) refund(event *TxEvents.RefundEvent) error {
+	p, output, err := s.spend(event.Address, event.Output, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(p, "Refunded output", event.DestCounter, p.Counter)
+	if err != nil {
+		return err
+	}
+	output.HashLock = nil
+	moveCounter(p, output)
+	return nil
+}
+
+func (s *State) timeLock(event *TxEvents.TimeLockEvent) error {
+	p, output, err := s.spend(event.Address, event.Output, event.SourceCounter)
+	if err != nil {
+		return err
+	}
+	err = checkCounter(p, "Locked output", event.DestCounter, p.Counter)
+	if err != nil {
+		return err
+	}
+	locked, err := splitOutput(p, output, int(event.Amount))
+	if err != nil {
+		return err
+	}
+	locked.TimeLock = &OTX.TimeLock{Start: event.Start, End: event.End, Period: event.Period, Total: int(event.Amount)}
+	moveCounter(p, locked)
+	return nil
+}
+
+// batch applies the events of the steps of a batch in order.
+func (s *State) batch(event *TxEvents.BatchEvent) error {
+	for i, step := range event.Steps {
+		if step.Name == "batch" {
+			return fmt.Errorf("Batch step %d: batches can not be nested", i)
+		}
+		err := s.ApplyEvent(step.Name, step.Payload)
+		if err != nil {
+			return fmt.Errorf("Batch step %d (%s): %s", i, step.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// spend returns the popcode at address and its output idx after checking
+// that the output is at counter.
+func (s *State) spend(address string, idx int32, counter []byte) (*Pop.Pop, *OTX.SecP256k1Output, error) {
+	p, ok := s.Popcodes[address]
+	if !ok {
+		return nil, nil, fmt.Errorf("Popcode %s is not in the log", address)
+	}
+	if idx < 0 || int(idx) >= len(p.Outputs) {
+		return nil, nil, fmt.Errorf("Popcode %s has no output %d", address, idx)
+	}
+	output := &p.Outputs[idx]
+	if !bytes.Equal(output.PrevCounter, counter) {
+		return nil, nil, fmt.Errorf("Output %d of %s is at counter %x, the event spends %x", idx, address, output.PrevCounter, counter)
+	}
+	return p, output, nil
+}
+
+// dest returns the popcode at address, which is created with a counter
+// derived from seed the first time it receives outputs.
+func (s *State) dest(address string, seed []byte) (*Pop.Pop, error) {
+	if p, ok := s.Popcodes[address]; ok {
+		return p, nil
+	}
+	addressBytes, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid address %s", address)
+	}
+	hasher := sha256.New()
+	hasher.Write(seed)
+	hasher.Write(addressBytes)
+	p := &Pop.Pop{Address: address, Counter: hasher.Sum(nil)}
+	s.Popcodes[address] = p
+	return p, nil
+}
+
+// source is an amount taken from one output by a combine.
+type source struct {
+	idx    int
+	amount int
+}
+
+// takeSources takes the amounts of sources out of the outputs of p.
+func takeSources(p *Pop.Pop, sources []source) error {
+	for _, src := range sources {
+		p.Outputs[src.idx].Amount -= src.amount
+	}
+	for idx := range p.Outputs {
+		if p.Outputs[idx].Amount < 0 {
+			return fmt.Errorf("Combine takes more than output %d of %s holds", idx, p.Address)
+		}
+	}
+	return nil
+}
+
+// returnChange mirrors Pop: change is given back to the outputs it was taken
+// from, starting with the last source of each type.
+func returnChange(p *Pop.Pop, sources []source, change map[string]int) {
+	for i := len(sources) - 1; i >= 0; i-- {
+		output := &p.Outputs[sources[i].idx]
+		amount := change[output.Type]
+		if amount == 0 {
+			continue
+		}
+		if amount > sources[i].amount {
+			amount = sources[i].amount
+		}
+		output.Amount += amount
+		change[output.Type] -= amount
+	}
+}
+
+func returnedByType(returned []*TxEvents.ReturnedChange) map[string]int {
+	change := make(map[string]int)
+	for _, r := range returned {
+		change[r.Type] += int(r.Amount)
+	}
+	return change
+}
+
+// dropSpent removes the outputs of p that have been spent entirely.
+func dropSpent(p *Pop.Pop) {
+	outputs := []OTX.SecP256k1Output{}
+	for _, output := range p.Outputs {
+		if output.Amount != 0 {
+			outputs = append(outputs, output)
+		}
+	}
+	p.Outputs = outputs
+}
+
+// mintProducts appends the products of a combine to p, each at the next
+// counter of p.
+func mintProducts(p *Pop.Pop, products []*TxEvents.CombineProduct, creatorKey []byte, data string) error {
+	creator, err := btcec.ParsePubKey(creatorKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("Invalid Creator key")
+	}
+	for _, product := range products {
+		err = checkCounter(p, "Product", product.DestCounter, p.Counter)
+		if err != nil {
+			return err
+		}
+		p.Outputs = append(p.Outputs, *OTX.New(creator, int(product.Amount), product.Type, data, p.Counter))
+		p.Counter = next(p.Counter)
+	}
+	return nil
+}
+
+// moveOutput mirrors Pop: amount units of output idx of p move to a new
+// output on dest at dest's next counter, and the source output is removed
+// once it is empty.
+func moveOutput(p *Pop.Pop, idx int, amount int, dest *Pop.Pop, data string) {
+	destOut := p.Outputs[idx]
+	destOut.TimeLock = nil
+	destOut.Data = data
+	destOut.Amount = amount
+	moveCounter(dest, &destOut)
+	p.Outputs[idx].Amount -= amount
+	if p.Outputs[idx].Amount == 0 {
+		p.Outputs = append(p.Outputs[:idx], p.Outputs[idx+1:]...)
+	}
+	dest.Outputs = append(dest.Outputs, destOut)
+}
+
+// splitOutput returns output itself when amount is all of it, and otherwise
+// moves amount units of it into a new last output of p and returns that.
+func splitOutput(p *Pop.Pop, output *OTX.SecP256k1Output, amount int) (*OTX.SecP256k1Output, error) {
+	if amount <= 0 || amount > output.Amount {
+		return nil, fmt.Errorf("Lock of %d units from an output holding %d", amount, output.Amount)
+	}
+	if amount == output.Amount {
+		return output, nil
+	}
+	split := *output
+	split.Amount = amount
+	output.Amount -= amount
+	p.Outputs = append(p.Outputs, split)
+	return &p.Outputs[len(p.Outputs)-1], nil
+}
+
+// moveCounter gives output the current counter of p and advances it.
+func moveCounter(p *Pop.Pop, output *OTX.SecP256k1Output) {
+	output.PrevCounter = make([]byte, len(p.Counter))
+	copy(output.PrevCounter, p.Counter)
+	p.Counter = next(p.Counter)
+}
+
+func next(counter []byte) []byte {
+	digest := sha256.Sum256(counter)
+	return digest[:]
+}
+
+func checkCounter(p *Pop.Pop, what string, got []byte, want []byte) error {
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%s on %s is at counter %x in the event, replayed counter is %x", what, p.Address, got, want)
+	}
+	return nil
+}
+
+func parseKeys(keys [][]byte) ([]btcec.PublicKey, error) {
+	parsed := make([]btcec.PublicKey, len(keys))
+	for i, keyBytes := range keys {
+		key, err := btcec.ParsePubKey(keyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Owner key %x", keyBytes)
+		}
+		parsed[i] = *key
+	}
+	return parsed, nil
+}
@@ -0,0 +1,88 @@
+package replay_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/replay"
+	"github.com/skuchain/TuxedoPops/replay/replaytest"
+)
+
+func TestReplay(t *testing.T) {
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	events, _ := replaytest.Log(t, creator.PubKey(), owner.PubKey())
+
+	s, err := replay.Replay(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := s.Popcodes[replaytest.AddressA], s.Popcodes[replaytest.AddressB]
+	if s.Sequence != 3 || a == nil || b == nil {
+		t.Fatalf("unexpected state %+v", s)
+	}
+	if len(a.Outputs) != 1 || a.Outputs[0].Amount != 6 || len(a.Outputs[0].Owners) != 1 || a.Outputs[0].Threshold != 1 {
+		t.Errorf("unexpected outputs of A %+v", a.Outputs)
+	}
+	if len(b.Outputs) != 1 || b.Outputs[0].Amount != 4 || b.Outputs[0].Type != "Flour" {
+		t.Errorf("unexpected outputs of B %+v", b.Outputs)
+	}
+	// the unitize moved the counter of its source on
+	counter := sha256.Sum256(a.Outputs[0].PrevCounter)
+	counter = sha256.Sum256(counter[:])
+	if !bytes.Equal(a.Counter, counter[:]) {
+		t.Errorf("counter of A is %x", a.Counter)
+	}
+
+	log := &bytes.Buffer{}
+	for _, event := range events {
+		replay.WriteEvent(log, event)
+	}
+	read, err := replay.ReadLog(log)
+	if err != nil || len(read) != 3 || read[2].Name != "unitize" || !bytes.Equal(read[2].Payload, events[2].Payload) {
+		t.Errorf("read back %d events: %v", len(read), err)
+	}
+
+	snapshot := make(map[string]replay.Popcode)
+	for _, popcode := range s.Balances() {
+		snapshot[popcode.Address] = popcode
+	}
+	if divergences := s.Diff(snapshot); len(divergences) != 0 {
+		t.Errorf("unexpected divergences %v", divergences)
+	}
+	ledgerA := snapshot[replaytest.AddressA]
+	ledgerA.Counter = "00"
+	snapshot[replaytest.AddressA] = ledgerA
+	delete(snapshot, replaytest.AddressB)
+	if divergences := s.Diff(snapshot); len(divergences) != 2 {
+		t.Errorf("expected 2 divergences, got %v", divergences)
+	}
+}
+
+func TestReplayRejects(t *testing.T) {
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	events, _ := replaytest.Log(t, creator.PubKey(), owner.PubKey())
+
+	wrongVersion := TxEvents.Envelope{Version: replay.EnvelopeVersion + 1, Function: "create", Sequence: 1}
+	wrongVersionBytes, _ := proto.Marshal(&wrongVersion)
+	wrongCounter := replaytest.Wrap(t, 2, "transfer", &TxEvents.TransferEvent{SourceCounter: []byte{1}, Address: replaytest.AddressA})
+
+	for name, log := range map[string][]replay.Event{
+		"out of order":    {events[1]},
+		"replayed":        {events[0], events[1], events[2], events[2]},
+		"skipped":         {events[0], events[2]},
+		"wrong version":   {{Name: "create", Payload: wrongVersionBytes}},
+		"wrong function":  {{Name: "burn", Payload: events[0].Payload}},
+		"wrong counter":   {events[0], wrongCounter},
+		"unknown popcode": {replaytest.Wrap(t, 1, "transfer", &TxEvents.TransferEvent{Address: replaytest.AddressB})},
+	} {
+		if _, err := replay.Replay(log); err == nil {
+			t.Errorf("%s log was replayed", name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package replaytest builds event logs for the tests of the replay package
+// and the packages built on it.
+package replaytest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+// The popcodes of the log returned by Log.
+const (
+	AddressA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	AddressB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// Wrap returns event in the envelope the chaincode emits it in, with the
+// transaction id "a" plus sequence.
+func Wrap(t *testing.T, sequence uint64, name string, event proto.Message) replay.Event {
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := TxEvents.Envelope{Version: replay.EnvelopeVersion, Function: name, Sequence: sequence,
+		TxID: string('a' + rune(sequence)), Payload: payload}
+	envelopeBytes, err := proto.Marshal(&envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return replay.Event{Name: name, Payload: envelopeBytes}
+}
+
+func hash(b []byte) []byte {
+	digest := sha256.Sum256(b)
+	return digest[:]
+}
+
+// Log returns the events of a create of 10 Flour at AddressA, a transfer of
+// it to owner and a unitize of 4 of it to AddressB, and the counter of the
+// output at AddressB.
+func Log(t *testing.T, creator *btcec.PublicKey, owner *btcec.PublicKey) ([]replay.Event, []byte) {
+	seed := hash([]byte("seed"))
+	created := hash(seed)
+	transferred := hash(created)
+	addressB, _ := hex.DecodeString(AddressB)
+	unitized := hash(append(hash(transferred), addressB...))
+	return []replay.Event{
+		Wrap(t, 1, "create", &TxEvents.CreateEvent{SourceCounter: seed, DestCounter: created, Address: AddressA,
+			Amount: 10, Type: "Flour", CreatorPubKey: creator.SerializeCompressed()}),
+		Wrap(t, 2, "transfer", &TxEvents.TransferEvent{SourceCounter: created, DestCounter: transferred, Address: AddressA,
+			Type: "Flour", Owners: [][]byte{owner.SerializeCompressed()}}),
+		Wrap(t, 3, "unitize", &TxEvents.UnitizeEvent{SourceCounter: transferred, SourceAddress: AddressA,
+			DestAddress: AddressB, DestAmounts: []int32{4}, DestCounters: [][]byte{unitized}}),
+	}, unitized
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+	"github.com/skuchain/TuxedoPops/replay"
+)
+
+// eventLog invokes transactions and keeps the events they emit in order, in
+// the form read by replay.ReadLog.
+type eventLog struct {
+	t      *testing.T
+	stub   *shim.MockStub
+	events bytes.Buffer
+}
+
+func (l *eventLog) invoke(function string, arg string) {
+	events, err := invokeWithEnvelopes(l.stub, function, []string{arg})
+	if err != nil {
+		HandleError(l.t, fmt.Errorf("%s failed: %s", function, err.Error()))
+		l.t.FailNow()
+	}
+	for name, payload := range events {
+		replay.WriteEvent(&l.events, replay.Event{Name: name, Payload: payload})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+	now := int64(1000)
+	defer setTxTime(&now)()
+	log := &eventLog{t: t, stub: stub}
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	recipient, _ := btcec.NewPrivateKey(btcec.S256())
+	keys := make([]*btcec.PrivateKey, 6)
+	addresses := make([]string, 6)
+	for i := range keys {
+		keys[i], _ = btcec.NewPrivateKey(btcec.S256())
+		addresses[i] = client.Address(keys[i].PubKey())
+	}
+	a, b, cc, d, e, f := 0, 1, 2, 3, 4, 5
+	owners := []*btcec.PrivateKey{owner}
+
+	createHex, _ := c.Create(client.Create{Address: addresses[a], Amount: 10, Type: "Flour"}, creator)
+	log.invoke("create", createHex)
+	createHex, _ = c.Create(client.Create{Address: addresses[a], Amount: 6, Type: "Water"}, creator)
+	log.invoke("create", createHex)
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}}, nil, keys[a])
+	log.invoke("transfer", transferHex)
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: addresses[b], DestAmounts: []int{3, 2}}, owners, keys[a])
+	log.invoke("unitize", unitizeHex)
+	multiUnitizeHex, _ := c.MultiUnitize(client.MultiUnitize{SourceOutput: 1, KeepChange: true,
+		Destinations: []client.Destination{{Address: addresses[cc], Amounts: []int{2}}, {Address: addresses[d], Amounts: []int{1}}}}, nil, keys[a])
+	log.invoke("multiUnitize", multiUnitizeHex)
+
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Loaf", CreatedType: "Bread", Ingredients: []client.Ingredient{
+		{Numerator: 2, Denominator: 1, Type: "Flour"}, {Numerator: 1, Denominator: 1, Type: "Water"}}}, creator)
+	log.invoke("recipe", recipeHex)
+	// both combines take more than they need and get change back
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 5}, {Output: 1, Amount: 3}},
+		Amount: 2, Recipe: "Loaf", ReturnChange: true}, creator, owners, keys[a])
+	log.invoke("combine", combineHex)
+	multiCombineHex, _ := c.MultiCombine(client.MultiCombine{Sources: []client.CombineSource{{Address: addresses[b], Output: 0, Amount: 3},
+		{Address: addresses[cc], Output: 0, Amount: 2}}, Destination: addresses[e], Amount: 1, Recipe: "Loaf", ReturnChange: true},
+		creator, [][]*btcec.PrivateKey{owners, nil}, []*btcec.PrivateKey{keys[b], keys[cc]})
+	log.invoke("multiCombine", multiCombineHex)
+
+	burnHex, _ := c.Burn(client.Burn{Output: 2, Amount: 1}, nil, keys[a])
+	log.invoke("burn", burnHex)
+	burnHex, _ = c.Burn(client.Burn{Output: 0, Amount: 1}, nil, keys[d])
+	log.invoke("burn", burnHex)
+	swapHex, _ := c.Swap(client.Swap{A: client.SwapSide{Address: addresses[a], Output: 0, Amount: 1},
+		B: client.SwapSide{Address: addresses[cc], Output: 0, Amount: 1}}, owners, keys[a], nil, keys[cc])
+	log.invoke("swap", swapHex)
+
+	createHex, _ = c.Create(client.Create{Address: addresses[d], Amount: 10, Type: "Gold"}, creator)
+	log.invoke("create", createHex)
+	timeLockHex, _ := c.TimeLock(client.TimeLock{Output: 0, Amount: 4, Start: now, End: now + 100}, nil, keys[d])
+	log.invoke("timeLock", timeLockHex)
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	hashLockHex, _ := c.HashLock(client.HashLock{Output: 0, Amount: 3, Hash: hash[:], Expiry: now + 100, Recipient: recipient.PubKey()}, nil, keys[d])
+	log.invoke("hashLock", hashLockHex)
+	claimHex, _ := c.Claim(client.Claim{Address: addresses[d], Output: 2, Preimage: preimage}, recipient)
+	log.invoke("claim", claimHex)
+
+	counter, _ := c.Counter(addresses[f])
+	create, _ := client.Create{Address: addresses[f], Amount: 7, Type: "Salt"}.Sign(counter, creator)
+	transfer, _ := client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}}.Sign(client.NextCounter(client.NextCounter(counter)), nil, keys[f])
+	batch := client.Batch{}
+	batch.Add("create", create)
+	batch.Add("transfer", transfer)
+	batchHex, _ := batch.Encode()
+	log.invoke("batch", batchHex)
+
+	snapshot := bytes.Buffer{}
+	for _, address := range addresses {
+		balance, _ := stub.MockQuery("balance", []string{address})
+		snapshot.Write(balance)
+	}
+	ledger, err := replay.ReadSnapshot(&snapshot)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	logBytes := log.events.Bytes()
+	events, err := replay.ReadLog(bytes.NewReader(logBytes))
+	if err != nil || len(events) != 16 {
+		HandleError(t, fmt.Errorf("unexpected log of %d events: %v", len(events), err))
+		t.FailNow()
+	}
+	state, err := replay.Replay(events)
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if divergences := state.Diff(ledger); len(divergences) != 0 {
+		HandleError(t, fmt.Errorf("replay diverges from the ledger %v", divergences))
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+)
+
+// The units of each type minted and burned since supply tracking began are
+// kept under Supply:<type>. Units that already existed are not counted.
+func getSupply(stub shim.ChaincodeStubInterface, assetType string) (*TuxedoPopsStore.Supply, error) {
+	supplyBytes, err := stub.GetState("Supply:" + assetType)
+	if err != nil {
+		fmt.Println("Could not get Supply State")
+		return nil, errors.New("Could not get Supply State")
+	}
+	supply := TuxedoPopsStore.Supply{}
+	err = proto.Unmarshal(supplyBytes, &supply)
+	if err != nil {
+		return nil, fmt.Errorf("Could not deserialize Supply %s", assetType)
+	}
+	return &supply, nil
+}
+
+func putSupply(stub shim.ChaincodeStubInterface, assetType string, supply *TuxedoPopsStore.Supply) error {
+	supplyBytes, err := proto.Marshal(supply)
+	if err != nil {
+		fmt.Printf("Supply Store Serialization error\n")
+		return fmt.Errorf("Supply Store Serialization Error\n")
+	}
+	err = stub.PutState("Supply:"+assetType, supplyBytes)
+	if err != nil {
+		fmt.Printf("error putting supply state to ledger: (%s)\n", err.Error())
+		return fmt.Errorf("error putting supply state to ledger: (%s)\n", err.Error())
+	}
+	return nil
+}
+
+// recordMinted adds amount to the units of assetType minted, failing if that
+// takes them past the cap of a registered type.
+func recordMinted(stub shim.ChaincodeStubInterface, assetType string, amount int64) error {
+	supply, err := getSupply(stub, assetType)
+	if err != nil {
+		return err
+	}
+	registration, err := getAssetType(stub, assetType)
+	if err != nil {
+		return err
+	}
+	if amount < 0 {
+		return fmt.Errorf("Can not mint %d %s", amount, assetType)
+	}
+	if registration != nil && registration.Cap > 0 && supply.Minted+amount > registration.Cap {
+		return fmt.Errorf("Minting %d %s would exceed its cap of %d, %d already minted", amount, assetType, registration.Cap, supply.Minted)
+	}
+	supply.Minted += amount
+	return putSupply(stub, assetType, supply)
+}
+
+// recordBurned adds amount to the units of assetType burned.
+func recordBurned(stub shim.ChaincodeStubInterface, assetType string, amount int64) error {
+	supply, err := getSupply(stub, assetType)
+	if err != nil {
+		return err
+	}
+	supply.Burned += amount
+	return putSupply(stub, assetType, supply)
+}
+
+// unitsByType is the number of units of each type held in the outputs of
+// pops.
+func unitsByType(pops ...*Pop.Pop) map[string]int64 {
+	units := make(map[string]int64)
+	for _, p := range pops {
+		for _, output := range p.Outputs {
+			units[output.Type] += int64(output.Amount)
+		}
+	}
+	return units
+}
+
+// recordCombine records the products of a combine as minted and the
+// ingredients it consumed as burned, given the units held by its popcodes
+// before and after, and returns the number of units consumed.
+func recordCombine(stub shim.ChaincodeStubInterface, before map[string]int64, after map[string]int64,
+	products []*TxEvents.CombineProduct) (int64, error) {
+	minted := make(map[string]int64)
+	for _, product := range products {
+		minted[product.Type] += int64(product.Amount)
+	}
+	consumed := int64(0)
+	for assetType, units := range before {
+		amount := units - after[assetType] + minted[assetType]
+		if amount <= 0 {
+			continue
+		}
+		err := recordBurned(stub, assetType, amount)
+		if err != nil {
+			return 0, err
+		}
+		consumed += amount
+	}
+	for _, product := range products {
+		err := recordMinted(stub, product.Type, int64(product.Amount))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return consumed, nil
+}
+
+// returnedChange lists by type the units taken from the sources of a combine
+// that were given back as change rather than consumed, given the units held
+// by its popcodes before and after.
+func returnedChange(taken map[string]int64, before map[string]int64, after map[string]int64,
+	products []*TxEvents.CombineProduct) []*TxEvents.ReturnedChange {
+	minted := make(map[string]int64)
+	for _, product := range products {
+		minted[product.Type] += int64(product.Amount)
+	}
+	types := []string{}
+	for assetType := range taken {
+		types = append(types, assetType)
+	}
+	sort.Strings(types)
+	returned := []*TxEvents.ReturnedChange{}
+	for _, assetType := range types {
+		consumed := before[assetType] - after[assetType] + minted[assetType]
+		if taken[assetType] > consumed {
+			returned = append(returned, &TxEvents.ReturnedChange{Type: assetType, Amount: int32(taken[assetType] - consumed)})
+		}
+	}
+	return returned
+}
+
+func supplyToJSON(assetType string, supply *TuxedoPopsStore.Supply, registration *TuxedoPopsStore.AssetType) ([]byte, error) {
+	type JSONSupply struct {
+		Type        string
+		Minted      int64
+		Burned      int64
+		Outstanding int64
+		Cap         int64
+	}
+	jsonSupply := JSONSupply{}
+	jsonSupply.Type = assetType
+	jsonSupply.Minted = supply.Minted
+	jsonSupply.Burned = supply.Burned
+	jsonSupply.Outstanding = supply.Minted - supply.Burned
+	if registration != nil {
+		jsonSupply.Cap = registration.Cap
+	}
+	return json.Marshal(jsonSupply)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+type supplyResult struct {
+	Minted      int64
+	Burned      int64
+	Outstanding int64
+	Cap         int64
+}
+
+func getSupplyResult(t *testing.T, stub *shim.MockStub, assetType string) supplyResult {
+	supplyBytes, err := stub.MockQuery("supply", []string{assetType})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	supply := supplyResult{}
+	json.Unmarshal(supplyBytes, &supply)
+	return supply
+}
+
+func TestSupply(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Grapes"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Wine", CreatedType: "Wine", Ingredients: []client.Ingredient{{Numerator: 3, Denominator: 1, Type: "Grapes"}},
+		Byproducts: []client.Byproduct{{Numerator: 1, Denominator: 1, Type: "Pomace"}}}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 6}}, Amount: 2, Recipe: "Wine"}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	burnHex, _ := c.Burn(client.Burn{Output: 0, Amount: 1}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	grapes := getSupplyResult(t, stub, "Grapes")
+	if grapes.Minted != 10 || grapes.Burned != 7 || grapes.Outstanding != 3 {
+		HandleError(t, fmt.Errorf("unexpected grape supply %+v", grapes))
+	}
+	wine := getSupplyResult(t, stub, "Wine")
+	if wine.Minted != 2 || wine.Burned != 0 || wine.Outstanding != 2 {
+		HandleError(t, fmt.Errorf("unexpected wine supply %+v", wine))
+	}
+	pomace := getSupplyResult(t, stub, "Pomace")
+	if pomace.Minted != 2 || pomace.Outstanding != 2 {
+		HandleError(t, fmt.Errorf("unexpected pomace supply %+v", pomace))
+	}
+	if none := getSupplyResult(t, stub, "Beer"); none.Minted != 0 || none.Outstanding != 0 {
+		HandleError(t, fmt.Errorf("unexpected supply of a type never minted %+v", none))
+	}
+}
+
+func TestSupplyCap(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	issuer, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	registerHex, _ := c.RegisterType(client.RegisterType{Name: "Ticket", Issuers: []*btcec.PublicKey{issuer.PubKey()}, Revision: 1, Cap: 10}, issuer)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 6, Type: "Ticket"}, issuer)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 5, Type: "Ticket"}, issuer)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create past the cap was accepted"))
+	}
+	// a negative create would lower the units minted so far
+	for _, amount := range []int{-5, 0} {
+		createHex, _ := c.Create(client.Create{Address: address, Amount: amount, Type: "Ticket"}, issuer)
+		if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+			HandleError(t, fmt.Errorf("create of %d was accepted", amount))
+		}
+	}
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create past the cap was accepted after a negative create"))
+	}
+
+	// burning does not free up issuance
+	burnHex, _ := c.Burn(client.Burn{Output: 0, Amount: 6}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create past the cap was accepted after a burn"))
+	}
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 4, Type: "Ticket"}, issuer)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	supply := getSupplyResult(t, stub, "Ticket")
+	if supply.Minted != 10 || supply.Burned != 6 || supply.Outstanding != 4 || supply.Cap != 10 {
+		HandleError(t, fmt.Errorf("unexpected ticket supply %+v", supply))
+	}
+
+	registerHex, _ = c.RegisterType(client.RegisterType{Name: "Ticket", Issuers: []*btcec.PublicKey{issuer.PubKey()}, Revision: 2, Cap: 8}, issuer)
+	if _, err := stub.MockInvoke("1", "registerType", []string{registerHex}); err == nil {
+		HandleError(t, fmt.Errorf("cap below the minted supply was accepted"))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/TxEvents"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestSwap(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	ownerA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeA, _ := btcec.NewPrivateKey(btcec.S256())
+	popcodeB, _ := btcec.NewPrivateKey(btcec.S256())
+	addressA := client.Address(popcodeA.PubKey())
+	addressB := client.Address(popcodeB.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: addressA, Amount: 10, Type: "Coffee"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{ownerA.PubKey()}}, nil, popcodeA)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	createHex, _ = c.Create(client.Create{Address: addressB, Amount: 50, Type: "Credit"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	beforeA := getBalance(t, stub, &keyInfo{address: addressA})
+	beforeB := getBalance(t, stub, &keyInfo{address: addressB})
+
+	swap := client.Swap{
+		A:    client.SwapSide{Address: addressA, Output: 0, Amount: 10},
+		B:    client.SwapSide{Address: addressB, Output: 0, Amount: 50},
+		Data: "coffee for credit",
+	}
+
+	// the owner of A's output must sign
+	swapHex, _ := c.Swap(swap, nil, popcodeA, nil, popcodeB)
+	if _, err := stub.MockInvoke("1", "swap", []string{swapHex}); err == nil {
+		HandleError(t, fmt.Errorf("swap without owner signature was accepted"))
+	}
+
+	// B can not change the terms A signed
+	counterA, _ := c.Counter(addressA)
+	counterB, _ := c.Counter(addressB)
+	sideA, _ := swap.SignSide(counterA, counterB, []*btcec.PrivateKey{ownerA}, popcodeA)
+	cheaper := swap
+	cheaper.B.Amount = 5
+	sideB, _ := cheaper.SignSide(counterA, counterB, nil, popcodeB)
+	sideB.Amount = 5
+	cheaperHex, _ := client.Encode(cheaper.Build(sideA, sideB))
+	if _, err := stub.MockInvoke("1", "swap", []string{cheaperHex}); err == nil {
+		HandleError(t, fmt.Errorf("swap with mismatched terms was accepted"))
+	}
+
+	sideB, _ = swap.SignSide(counterA, counterB, nil, popcodeB)
+	swapHex, _ = client.Encode(swap.Build(sideA, sideB))
+	events, err := invokeWithEvents(stub, "swap", []string{swapHex})
+	if err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	afterA := getBalance(t, stub, &keyInfo{address: addressA})
+	afterB := getBalance(t, stub, &keyInfo{address: addressB})
+	if len(afterA.Outputs) != 1 || afterA.Outputs[0].Type != "Credit" || afterA.Outputs[0].Amount != 50 {
+		HandleError(t, fmt.Errorf("unexpected balance of A after swap %v", afterA))
+	}
+	if len(afterB.Outputs) != 1 || afterB.Outputs[0].Type != "Coffee" || afterB.Outputs[0].Amount != 10 {
+		HandleError(t, fmt.Errorf("unexpected balance of B after swap %v", afterB))
+	}
+	if afterA.Counter == beforeA.Counter || afterB.Counter == beforeB.Counter {
+		HandleError(t, fmt.Errorf("swap did not advance both counters"))
+	}
+
+	swapEvent := TxEvents.SwapEvent{}
+	if err := proto.Unmarshal(events["swap"], &swapEvent); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	if swapEvent.A.Type != "Coffee" || swapEvent.A.Amount != 10 || swapEvent.B.Type != "Credit" || swapEvent.B.Amount != 50 {
+		HandleError(t, fmt.Errorf("unexpected swap event %v", swapEvent))
+	}
+	if fmt.Sprintf("%x", swapEvent.A.DestCounter) != afterB.Outputs[0].PrevCounter ||
+		fmt.Sprintf("%x", swapEvent.B.DestCounter) != afterA.Outputs[0].PrevCounter {
+		HandleError(t, fmt.Errorf("swap event counters do not match the new outputs %v", swapEvent))
+	}
+
+	// replaying the swap fails because both counters moved
+	if _, err := stub.MockInvoke("1", "swap", []string{swapHex}); err == nil {
+		HandleError(t, fmt.Errorf("replayed swap was accepted"))
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestTimeLock(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	owner, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	dest, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+	destAddress := client.Address(dest.PubKey())
+	owners := []*btcec.PrivateKey{owner}
+
+	now := int64(1000)
+	defer setTxTime(&now)()
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 100, Type: "Rebate"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	transferHex, _ := c.Transfer(client.Transfer{Output: 0, Owners: []*btcec.PublicKey{owner.PubKey()}}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	// 80 of the 100 units vest quarterly over the next 400 seconds
+	lockHex, _ := c.TimeLock(client.TimeLock{Output: 0, Amount: 80, Start: 1000, End: 1400, Period: 100}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "timeLock", []string{lockHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	balance := getBalance(t, stub, &keyInfo{address: address})
+	if len(balance.Outputs) != 2 || balance.Outputs[0].Amount != 20 || balance.Outputs[0].TimeLock != nil ||
+		balance.Outputs[1].Amount != 80 || balance.Outputs[1].TimeLock == nil || balance.Outputs[1].TimeLock.Total != 80 {
+		HandleError(t, fmt.Errorf("unexpected balance after time lock %v", balance))
+	}
+
+	// nothing has vested yet
+	burnHex, _ := c.Burn(client.Burn{Output: 1, Amount: 1}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err == nil {
+		HandleError(t, fmt.Errorf("burn of an unvested output was accepted"))
+	}
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 1, DestAddress: destAddress, DestAmounts: []int{1}}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err == nil {
+		HandleError(t, fmt.Errorf("unitize of an unvested output was accepted"))
+	}
+
+	// the first quarter vests 20 units
+	now = 1150
+	unitizeHex, _ = c.Unitize(client.Unitize{SourceOutput: 1, DestAddress: destAddress, DestAmounts: []int{21}}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err == nil {
+		HandleError(t, fmt.Errorf("unitize of more than the vested amount was accepted"))
+	}
+	unitizeHex, _ = c.Unitize(client.Unitize{SourceOutput: 1, DestAddress: destAddress, DestAmounts: []int{20}}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	destBalance := getBalance(t, stub, &keyInfo{address: destAddress})
+	if len(destBalance.Outputs) != 1 || destBalance.Outputs[0].Amount != 20 || destBalance.Outputs[0].TimeLock != nil {
+		HandleError(t, fmt.Errorf("vested units were not moved unlocked %v", destBalance))
+	}
+	transferHex, _ = c.Transfer(client.Transfer{Output: 1, Owners: []*btcec.PublicKey{dest.PubKey()}}, owners, popcode)
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err == nil {
+		HandleError(t, fmt.Errorf("transfer of a partly vested output was accepted"))
+	}
+
+	// everything has vested at the end
+	now = 1400
+	if _, err := stub.MockInvoke("1", "transfer", []string{transferHex}); err != nil {
+		HandleError(t, err)
+	}
+}
+
+func TestTimeLockEmbargo(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	dest, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	now := int64(1000)
+	restore := setTxTime(&now)
+
+	// creating and locking in one batch means the output is never spendable
+	// before the release date
+	counter, _ := c.Counter(address)
+	create, _ := client.Create{Address: address, Amount: 5, Type: "Album"}.Sign(counter, creator)
+	lock, _ := client.TimeLock{Output: 0, Amount: 5, Start: 5000, End: 5000}.Sign(
+		client.NextCounter(client.NextCounter(counter)), nil, popcode)
+	batch := client.Batch{}
+	batch.Add("create", create)
+	batch.Add("timeLock", lock)
+	batchHex, _ := batch.Encode()
+	if _, err := stub.MockInvoke("1", "batch", []string{batchHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	unitizeHex, _ := c.Unitize(client.Unitize{SourceOutput: 0, DestAddress: client.Address(dest.PubKey()), DestAmounts: []int{5}}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err == nil {
+		HandleError(t, fmt.Errorf("unitize before the release date was accepted"))
+	}
+
+	// without a transaction timestamp time locks never release
+	restore()
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err == nil {
+		HandleError(t, fmt.Errorf("unitize without a timestamp was accepted"))
+	}
+	now = 5000
+	defer setTxTime(&now)()
+	if _, err := stub.MockInvoke("1", "unitize", []string{unitizeHex}); err != nil {
+		HandleError(t, err)
+	}
+}
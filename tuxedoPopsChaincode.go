@@ -17,6 +17,7 @@ import (
 	"crypto/sha256"
 
 	"errors"
+	"math"
 
 	"strconv"
 
@@ -24,7 +25,6 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/skuchain/TuxedoPops/Pop"
-	txcache "github.com/skuchain/TuxedoPops/TXCache"
 	"github.com/skuchain/TuxedoPops/TuxedoPopsStore"
 	"github.com/skuchain/TuxedoPops/TuxedoPopsTX"
 	"github.com/skuchain/TuxedoPops/TxEvents"
@@ -51,9 +51,42 @@ func (t *tuxedoPopsChaincode) Init(stub shim.ChaincodeStubInterface, function st
 		return nil, fmt.Errorf("Error initializing CounterSeed (%s)\n", args[0])
 	}
 
+	// The optional second argument is the oldest signing version still accepted.
+	// Deploying with 1 ends the migration window for legacy signatures.
+	minSigVersion := Pop.LegacyEncoding
+	if len(args) > 1 {
+		minSigVersion, err = strconv.Atoi(args[1])
+		if err != nil || Pop.CheckVersion(minSigVersion) != nil {
+			return nil, fmt.Errorf("Invalid minimum signing version (%s)\n", args[1])
+		}
+	}
+	err = stub.PutState("MinSigVersion", []byte(strconv.Itoa(minSigVersion)))
+	if err != nil {
+		fmt.Printf("Error initializing MinSigVersion\n")
+		return nil, fmt.Errorf("Error initializing MinSigVersion\n")
+	}
+
 	return nil, nil
 }
 
+func checkSigVersion(stub shim.ChaincodeStubInterface, version int32) error {
+	minSigVersionBytes, err := stub.GetState("MinSigVersion")
+	if err != nil {
+		return fmt.Errorf("error getting MinSigVersion state\n")
+	}
+	minSigVersion := Pop.LegacyEncoding
+	if len(minSigVersionBytes) > 0 {
+		minSigVersion, err = strconv.Atoi(string(minSigVersionBytes))
+		if err != nil {
+			return fmt.Errorf("Invalid MinSigVersion state (%s)\n", minSigVersionBytes)
+		}
+	}
+	if int(version) < minSigVersion {
+		return fmt.Errorf("Signing version %d is no longer accepted, minimum is %d", version, minSigVersion)
+	}
+	return Pop.CheckVersion(int(version))
+}
+
 func (t *tuxedoPopsChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	if len(args) == 0 {
 		fmt.Println("Insufficient arguments found")
@@ -71,469 +104,1519 @@ func (t *tuxedoPopsChaincode) Invoke(stub shim.ChaincodeStubInterface, function
 		fmt.Printf("error getting counterseed state\n")
 		return nil, fmt.Errorf("error getting counterseed state\n")
 	}
-	txCache := txcache.TXCache{}
-	txCacheBytes, err := stub.GetState("TxCache")
-
+	st := invokeState{counterseed: counterseed}
+	es := &envelopeStub{ChaincodeStubInterface: stub}
+	err = t.dispatch(es, function, argsBytes, &st)
 	if err != nil {
-		fmt.Println(err)
-		return nil, fmt.Errorf("error getting TxCache state\n")
+		return nil, err
 	}
-
-	if len(txCacheBytes) > 0 {
-		proto.Unmarshal(txCacheBytes, &txCache)
-	} else {
-		txCache.Cache = make(map[string]bool)
+	err = emitEnvelope(stub, function, es)
+	if err != nil {
+		return nil, err
 	}
+	return nil, nil
+}
 
+// invokeState holds the ledger wide values loaded once per Invoke.
+type invokeState struct {
+	counterseed []byte
+}
+
+func (t *tuxedoPopsChaincode) dispatch(stub shim.ChaincodeStubInterface, function string, argsBytes []byte, st *invokeState) error {
 	switch function {
 	case "create":
+		return t.create(stub, argsBytes, st)
+	case "transfer":
+		return t.transfer(stub, argsBytes, st)
+	case "unitize":
+		return t.unitize(stub, argsBytes, st)
+	case "combine":
+		return t.combine(stub, argsBytes, st)
+	case "multiCombine":
+		return t.multiCombine(stub, argsBytes, st)
+	case "multiUnitize":
+		return t.multiUnitize(stub, argsBytes, st)
+	case "burn":
+		return t.burn(stub, argsBytes, st)
+	case "recipe":
+		return t.registerRecipe(stub, argsBytes, st)
+	case "recipeStatus":
+		return t.recipeStatus(stub, argsBytes, st)
+	case "registerType":
+		return t.registerType(stub, argsBytes, st)
+	case "swap":
+		return t.swap(stub, argsBytes, st)
+	case "hashLock":
+		return t.hashLock(stub, argsBytes, st)
+	case "claim":
+		return t.claim(stub, argsBytes, st)
+	case "refund":
+		return t.refund(stub, argsBytes, st)
+	case "timeLock":
+		return t.timeLock(stub, argsBytes, st)
+	case "batch":
+		return t.batch(stub, argsBytes, st)
+	default:
+		fmt.Printf("Invalid function type (%s)", function)
+		return fmt.Errorf("Invalid function type (%s)", function)
+	}
+}
+
+func (t *tuxedoPopsChaincode) create(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	createEvent := TxEvents.CreateEvent{}
+
+	createArgs := TuxedoPopsTX.CreateTX{}
+	err := proto.Unmarshal(argsBytes, &createArgs)
+	if err != nil {
+		fmt.Printf("Invalid argument expected CreateTX protocol buffer ERR:(%s)\n", err.Error())
+		return fmt.Errorf("Invalid argument expected CreateTX protocol buffer ERR:(%s)\n", err.Error())
+	}
+	err = checkSigVersion(stub, createArgs.Version)
+	if err != nil {
+		return err
+	}
 
-		createEvent := TxEvents.CreateEvent{}
+	createEvent.Address = createArgs.Address
+	createEvent.Amount = createArgs.Amount
+	createEvent.CreatorPubKey = createArgs.CreatorPubKey
+	createEvent.Data = createArgs.Data
+	createEvent.Type = createArgs.Type
 
-		createArgs := TuxedoPopsTX.CreateTX{}
-		err = proto.Unmarshal(argsBytes, &createArgs)
+	assetType, err := getAssetType(stub, createArgs.Type)
+	if err != nil {
+		return err
+	}
+	issuers := [][]byte{}
+	if assetType != nil {
+		issuers = assetType.Issuers
+	}
+
+	popcodebytes, err := stub.GetState("Popcode:" + createArgs.Address)
+
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+	popcode := Pop.Pop{}
+
+	if len(popcodebytes) == 0 {
+		addrBytes, err := hex.DecodeString(createArgs.Address)
+		if err != nil {
+			return fmt.Errorf("Invalid popcode address %s ", createArgs.Address)
+		}
+		hasher := sha256.New()
+		hasher.Write(st.counterseed)
+		hasher.Write(addrBytes)
+		hashedCounterSeed := []byte{}
+		hashedCounterSeed = hasher.Sum(hashedCounterSeed)
+		popcode.Counter = hashedCounterSeed[:]
+		createEvent.SourceCounter = hashedCounterSeed[:]
+		popcode.Address = hex.EncodeToString(addrBytes)
+
+		err = popcode.CreateOutput(int(createArgs.Amount), createArgs.Type, createArgs.Data, createArgs.CreatorPubKey, createArgs.CreatorSig, issuers, int(createArgs.Version))
+		if err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+		createEvent.DestCounter = popcode.Outputs[len(popcode.Outputs)-1].PrevCounter
+
+	} else {
+		err := popcode.FromBytes(popcodebytes)
 		if err != nil {
-			fmt.Printf("Invalid argument expected CreateTX protocol buffer ERR:(%s)\n", err.Error())
-			return nil, fmt.Errorf("Invalid argument expected CreateTX protocol buffer ERR:(%s)\n", err.Error())
+			fmt.Println("Popcode Deserialization error")
+			return errors.New("Popcode Deserialization Failure")
 		}
+		createEvent.SourceCounter = popcode.Counter
+		err = popcode.CreateOutput(int(createArgs.Amount), createArgs.Type, createArgs.Data, createArgs.CreatorPubKey, createArgs.CreatorSig, issuers, int(createArgs.Version))
+		if err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+		createEvent.DestCounter = popcode.Outputs[len(popcode.Outputs)-1].PrevCounter
+
+	}
 
-		createEvent.Address = createArgs.Address
-		createEvent.Amount = createArgs.Amount
-		createEvent.CreatorPubKey = createArgs.CreatorPubKey
-		createEvent.Data = createArgs.Data
-		createEvent.Type = createArgs.Type
+	err = recordMinted(stub, createArgs.Type, int64(createArgs.Amount))
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	step := newStep(stub, "create", argsBytes, &TuxedoPopsStore.ProofPopcode{Address: popcode.Address, Counter: createEvent.SourceCounter})
+	stepHash, err := putStep(stub, step, &createEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Operation: "create", Address: createArgs.Address, Type: createArgs.Type},
+		createEvent.DestCounter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, createArgs.Address, &popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	createEventBytes, err := proto.Marshal(&createEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("create", createEventBytes)
+	return nil
+}
+
+func (t *tuxedoPopsChaincode) transfer(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	transferEvent := TxEvents.TransferEvent{}
 
-		popcodebytes, err := stub.GetState("Popcode:" + createArgs.Address)
+	transferArgs := TuxedoPopsTX.TransferOwners{}
+	err := proto.Unmarshal(argsBytes, &transferArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected TransferOwners protocol buffer")
+		return fmt.Errorf("Invalid argument expected TransferOwners protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, transferArgs.Version)
+	if err != nil {
+		return err
+	}
+
+	transferEvent.Address = transferArgs.Address
+	transferEvent.Data = transferArgs.Data
+	transferEvent.Owners = transferArgs.Owners
+	if len(transferArgs.Owners) < int(transferArgs.Threshold) {
+		return fmt.Errorf("threshold value (%d) is larger than number of owners (%d) for popcode output on address (%s)",
+			transferArgs.Threshold, len(transferArgs.Owners), transferArgs.Address)
+	}
+	transferEvent.Threshold = transferArgs.Threshold
 
+	popcodeKeyDigest := sha256.Sum256(transferArgs.PopcodePubKey)
+	transferAddress := hex.EncodeToString(popcodeKeyDigest[:20])
+
+	if transferAddress != transferArgs.Address {
+		return fmt.Errorf("Public key %s does not derive address of %s", transferArgs.PopcodePubKey, transferArgs.Address)
+	}
+
+	popcodebytes, err := stub.GetState("Popcode:" + transferArgs.Address)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+	if len(popcodebytes) == 0 {
+		fmt.Println("No value found in popcode")
+		return errors.New("No value found in popcode")
+	}
+	popcode := Pop.Pop{}
+	popcode.FromBytes(popcodebytes)
+
+	if transferArgs.Output < 0 || int(transferArgs.Output) >= len(popcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", transferArgs.Output, popcode.ToJSON())
+	}
+	transferEvent.SourceCounter = popcode.Outputs[transferArgs.Output].PrevCounter
+
+	step := newStep(stub, "transfer", argsBytes, proofPopcode(&popcode, int(transferArgs.Output)))
+	err = popcode.SetOwner(int(transferArgs.Output), int(transferArgs.Threshold), transferArgs.Data, transferArgs.Owners, transferArgs.PrevOwnerSigs, transferArgs.PopcodePubKey, transferArgs.PopcodeSig, spendTime(stub), int(transferArgs.Version))
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	transferEvent.DestCounter = popcode.Outputs[transferArgs.Output].PrevCounter
+	transferEvent.Amount = int32(popcode.Outputs[transferArgs.Output].Amount)
+	transferEvent.Type = popcode.Outputs[transferArgs.Output].Type
+
+	stepHash, err := putStep(stub, step, &transferEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{transferEvent.SourceCounter}, Operation: "transfer",
+		Address: transferArgs.Address, Type: transferEvent.Type}, transferEvent.DestCounter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, transferArgs.Address, &popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	transferEventBytes, err := proto.Marshal(&transferEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("transfer", transferEventBytes)
+	return nil
+}
+
+func (t *tuxedoPopsChaincode) unitize(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	unitizeEvent := TxEvents.UnitizeEvent{}
+	unitizeArgs := TuxedoPopsTX.Unitize{}
+	err := proto.Unmarshal(argsBytes, &unitizeArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Unitize protocol buffer")
+		return fmt.Errorf("Invalid argument expected Unitize protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, unitizeArgs.Version)
+	if err != nil {
+		return err
+	}
+
+	if unitizeArgs.SourceAddress == unitizeArgs.DestAddress {
+		return fmt.Errorf("The source address %s must be different from dest address %s", unitizeArgs.SourceAddress, unitizeArgs.DestAddress)
+	}
+	fmt.Printf("\n\n\nOWNERSIGS for UNITIZE: (%v)\n\n", unitizeArgs.OwnerSigs)
+
+	unitizeEvent.Data = unitizeArgs.Data
+	unitizeEvent.DestAddress = unitizeArgs.DestAddress
+	unitizeEvent.PopcodePubKey = unitizeArgs.PopcodePubKey
+	unitizeEvent.SourceAddress = unitizeArgs.SourceAddress
+	unitizeEvent.SourceOutput = unitizeArgs.SourceOutput
+
+	popcodeKeyDigest := sha256.Sum256(unitizeArgs.PopcodePubKey)
+	sourceAddress := hex.EncodeToString(popcodeKeyDigest[:20])
+	if unitizeArgs.SourceAddress != sourceAddress {
+		return fmt.Errorf("Public key %s does not derive address of %s", unitizeArgs.PopcodePubKey, unitizeArgs.SourceAddress)
+	}
+	sourcePopcodeBytes, err := stub.GetState("Popcode:" + sourceAddress)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+	if len(sourcePopcodeBytes) == 0 {
+		fmt.Println("No value found in popcode")
+		return errors.New("No value found in popcode")
+	}
+	sourcePopcode := Pop.Pop{}
+	err = sourcePopcode.FromBytes(sourcePopcodeBytes)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+
+	if unitizeArgs.SourceOutput < 0 || int(unitizeArgs.SourceOutput) >= len(sourcePopcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", unitizeArgs.SourceOutput, sourcePopcode.ToJSON())
+	}
+	unitizeEvent.SourceCounter = sourcePopcode.Outputs[unitizeArgs.SourceOutput].PrevCounter
+	destAddress := unitizeArgs.DestAddress
+	destPopcode, err := getDestPopcode(stub, destAddress, sourcePopcode.Counter)
+	if err != nil {
+		return err
+	}
+	convertedAmounts := make([]int, len(unitizeArgs.DestAmounts))
+	for i, destAmount := range unitizeArgs.DestAmounts {
+		convertedAmounts[i] = int(destAmount)
+	}
+	step := newStep(stub, "unitize", argsBytes, proofPopcode(&sourcePopcode, int(unitizeArgs.SourceOutput)), proofPopcode(destPopcode))
+	err = sourcePopcode.UnitizeOutput(int(unitizeArgs.SourceOutput), convertedAmounts, unitizeArgs.Data,
+		destPopcode, unitizeArgs.OwnerSigs, unitizeArgs.PopcodePubKey, unitizeArgs.PopcodeSig, spendTime(stub), int(unitizeArgs.Version))
+	if err != nil {
+		fmt.Printf("Unitize error: %s", err.Error())
+		return fmt.Errorf("Unitize error: %s", err.Error())
+	}
+
+	// The idea here is to harvest the created Counter values for the destinations via revserse interation through the number of events coordinated
+	for index := len(destPopcode.Outputs) - 1; index > len(destPopcode.Outputs)-1-len(unitizeArgs.DestAmounts); index-- {
+		fmt.Printf("\x1b[32m\n\n\ndestPopcode.Address: (%s)\ndestpopcode.Outputs: (%v)\nindex: (%d)\nnumber of destination amounts: (%d)\nstopping condition: index <= (%d)\n\n\x1b[0m",
+			destPopcode.Address, destPopcode.Outputs, index, len(unitizeArgs.DestAmounts), len(destPopcode.Outputs)-1-len(unitizeArgs.DestAmounts))
+
+		unitizeEvent.DestCounters = append(unitizeEvent.DestCounters, destPopcode.Outputs[index].PrevCounter)
+		unitizeEvent.DestAmounts = append(unitizeEvent.DestAmounts, int32(destPopcode.Outputs[index].Amount))
+	}
+
+	stepHash, err := putStep(stub, step, &unitizeEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{unitizeEvent.SourceCounter}, Operation: "unitize",
+		Address: destAddress, Type: destPopcode.Outputs[len(destPopcode.Outputs)-1].Type}, unitizeEvent.DestCounters...)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, sourceAddress, &sourcePopcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, destAddress, destPopcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	unitizeEventBytes, err := proto.Marshal(&unitizeEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("unitize", unitizeEventBytes)
+	return nil
+}
+
+func (t *tuxedoPopsChaincode) combine(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	combineEvent := TxEvents.CombineEvent{}
+	combineArgs := TuxedoPopsTX.Combine{}
+
+	err := proto.Unmarshal(argsBytes, &combineArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Combine protocol buffer")
+		return fmt.Errorf("Invalid argument expected Combine protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, combineArgs.Version)
+	if err != nil {
+		return err
+	}
+	combineEvent.Address = combineArgs.Address
+	combineEvent.Amount = combineArgs.Amount
+	combineEvent.CreatorPubKey = combineArgs.CreatorPubKey
+	combineEvent.Data = combineArgs.Data
+	combineEvent.Recipe = combineArgs.Recipe
+
+	for _, source := range combineArgs.Sources {
+		evSource := TxEvents.CombineSources{}
+		evSource.SourceAmount = source.SourceAmount
+		evSource.SourceOutput = source.SourceOutput
+		combineEvent.Sources = append(combineEvent.Sources, &evSource)
+	}
+
+	popcodeKeyDigest := sha256.Sum256(combineArgs.PopcodePubKey)
+	combineAddress := hex.EncodeToString(popcodeKeyDigest[:20])
+	if combineAddress != combineArgs.Address {
+		return fmt.Errorf("Public key %s does not derive address of %s", combineArgs.PopcodePubKey, combineArgs.Address)
+	}
+
+	popcode := Pop.Pop{}
+	popcodeBytes, err := stub.GetState("Popcode:" + combineAddress)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+	if len(popcodeBytes) == 0 {
+		fmt.Println("No value found in popcode")
+		return errors.New("No value found in popcode")
+	}
+	popcode.FromBytes(popcodeBytes)
+
+	recipe, err := combineRecipe(stub, combineArgs.Recipe, int(combineArgs.RecipeVersion))
+	if err != nil {
+		return err
+	}
+	combineEvent.RecipeVersion = recipe.Version
+	// the creator of the combine mints its products, whose types may have
+	// been registered after the recipe
+	err = checkProductIssuers(stub, recipe, combineArgs.CreatorPubKey)
+	if err != nil {
+		return err
+	}
+
+	sources := make([]Pop.SourceOutput, len(combineArgs.Sources))
+	taken := make(map[string]int64)
+
+	for i, v := range combineArgs.Sources {
+		sources[i] = v
+
+		if v.Idx() < len(popcode.Outputs) {
+			combineEvent.SourceCounters = append(combineEvent.SourceCounters, popcode.Outputs[v.Idx()].PrevCounter)
+			taken[popcode.Outputs[v.Idx()].Type] += int64(v.Amount())
+		} else {
+			return fmt.Errorf("Invalid output index in combine %d", v.Idx())
+		}
+
+	}
+
+	indices := make([]int, len(sources))
+	for i, source := range sources {
+		indices[i] = source.Idx()
+	}
+	step := newStep(stub, "combine", argsBytes, proofPopcode(&popcode, indices...))
+	step.Recipe = recipe
+	unitsBefore := unitsByType(&popcode)
+	err = popcode.CombineOutputs(sources, combineArgs.OwnerSigs, combineArgs.PopcodePubKey, combineArgs.PopcodeSig,
+		int(combineArgs.Amount), combineArgs.Recipe, int(combineArgs.RecipeVersion), *recipe, combineArgs.Data, combineArgs.CreatorPubKey, combineArgs.CreatorSig, combineArgs.ApprovalSigs, combineArgs.ReturnChange, spendTime(stub), int(combineArgs.Version))
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	products, minted := combineProducts(recipe, int(combineArgs.Amount), &popcode)
+	combineEvent.Products = products
+	combineEvent.DestCounter = products[0].DestCounter
+	unitsAfter := unitsByType(&popcode)
+	consumed, err := recordCombine(stub, unitsBefore, unitsAfter, products)
+	if err != nil {
+		return err
+	}
+	combineEvent.Returned = returnedChange(taken, unitsBefore, unitsAfter, products)
+	// whatever was consumed but not turned into a product is waste
+	combineEvent.Waste = consumed - minted
+	combineEvent.Change = -consumed
+	for _, source := range combineArgs.Sources {
+		combineEvent.Change += int64(source.SourceAmount)
+	}
+
+	stepHash, err := putStep(stub, step, &combineEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	for _, product := range products {
+		err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: combineEvent.SourceCounters, Operation: "combine", Address: combineAddress,
+			Type: product.Type, Recipe: combineArgs.Recipe, RecipeVersion: recipe.Version}, product.DestCounter)
 		if err != nil {
-			fmt.Println("Could not get Popcode State")
-			return nil, errors.New("Could not get Popcode State")
+			fmt.Println(err.Error())
+			return err
 		}
-		popcode := Pop.Pop{}
+	}
 
-		if len(popcodebytes) == 0 {
-			addrBytes, err := hex.DecodeString(createArgs.Address)
-			if err != nil {
-				return nil, fmt.Errorf("Invalid popcode address %s ", createArgs.Address)
-			}
-			hasher := sha256.New()
-			hasher.Write(counterseed)
-			hasher.Write(addrBytes)
-			hashedCounterSeed := []byte{}
-			hashedCounterSeed = hasher.Sum(hashedCounterSeed)
-			popcode.Counter = hashedCounterSeed[:]
-			createEvent.SourceCounter = hashedCounterSeed[:]
-			popcode.Address = hex.EncodeToString(addrBytes)
+	err = putPopcode(stub, combineAddress, &popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	combineEventBytes, err := proto.Marshal(&combineEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("combine", combineEventBytes)
+	return nil
+}
 
-			err = popcode.CreateOutput(int(createArgs.Amount), createArgs.Type, createArgs.Data, createArgs.CreatorPubKey, createArgs.CreatorSig)
-			createEvent.DestCounter = popcode.Outputs[len(popcode.Outputs)-1].PrevCounter
-			if err != nil {
-				fmt.Printf(err.Error())
-				return nil, err
-			}
+// combineProducts describes the products of a combine, which are the last
+// outputs of dest, and returns them with the number of units minted.
+func combineProducts(recipe *TuxedoPopsStore.Recipe, createdAmount int, dest *Pop.Pop) ([]*TxEvents.CombineProduct, int64) {
+	products := Pop.RecipeProducts(*recipe, createdAmount)
+	created := dest.Outputs[len(dest.Outputs)-len(products):]
+	evProducts := []*TxEvents.CombineProduct{}
+	minted := int64(0)
+	for i, product := range products {
+		evProduct := TxEvents.CombineProduct{}
+		evProduct.Type = product.Type
+		evProduct.Amount = int32(product.Amount)
+		evProduct.DestCounter = created[i].PrevCounter
+		evProducts = append(evProducts, &evProduct)
+		minted += int64(product.Amount)
+	}
+	return evProducts, minted
+}
 
-			antiReplayDigest := sha256.Sum256(createArgs.CreatorSig) // WARNING Assumes the Creator sig is not malleable without private key. Need to check if all maleability vectors are checked
+func (t *tuxedoPopsChaincode) multiCombine(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	combineEvent := TxEvents.MultiCombineEvent{}
+	combineArgs := TuxedoPopsTX.MultiCombine{}
+	err := proto.Unmarshal(argsBytes, &combineArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected MultiCombine protocol buffer")
+		return fmt.Errorf("Invalid argument expected MultiCombine protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, combineArgs.Version)
+	if err != nil {
+		return err
+	}
 
-			if txCache.Cache[string(antiReplayDigest[:])] {
-				fmt.Printf("Already recieved transaction")
-				return nil, fmt.Errorf("Already recieved transaction")
-			}
-			if len(txCache.Cache) > 100 {
-				nextseed := sha256.Sum256(counterseed)
-				counterseed = nextseed[:]
-				txCache.Cache = make(map[string]bool)
-			}
+	combineEvent.Destination = combineArgs.Destination
+	combineEvent.Amount = combineArgs.Amount
+	combineEvent.Recipe = combineArgs.Recipe
+	combineEvent.CreatorPubKey = combineArgs.CreatorPubKey
+	combineEvent.Data = combineArgs.Data
 
-		} else {
-			err := popcode.FromBytes(popcodebytes)
-			if err != nil {
-				fmt.Println("Popcode Deserialization error")
-				return nil, errors.New("Popcode Deserialization Failure")
+	signerArgs := make(map[string]*TuxedoPopsTX.CombineSigner)
+	for _, signer := range combineArgs.Signers {
+		signerArgs[signer.Address] = signer
+	}
+
+	// sources are grouped by popcode in the order the popcodes first appear
+	signers := []Pop.CombineSigner{}
+	signerIdx := make(map[string]int)
+	for _, source := range combineArgs.Sources {
+		i, ok := signerIdx[source.Address]
+		if !ok {
+			signer, ok := signerArgs[source.Address]
+			if !ok {
+				return fmt.Errorf("No signatures for source popcode %s", source.Address)
 			}
-			createEvent.SourceCounter = popcode.Counter
-			err = popcode.CreateOutput(int(createArgs.Amount), createArgs.Type, createArgs.Data, createArgs.CreatorPubKey, createArgs.CreatorSig)
+			popcode, err := getPopcode(stub, source.Address, signer.PopcodePubKey)
 			if err != nil {
-				fmt.Printf(err.Error())
-				return nil, err
+				return err
 			}
-			createEvent.DestCounter = popcode.Outputs[len(popcode.Outputs)-1].PrevCounter
-
+			i = len(signers)
+			signerIdx[source.Address] = i
+			signers = append(signers, Pop.CombineSigner{Pop: popcode, OwnerSigs: signer.OwnerSigs, PopPubKey: signer.PopcodePubKey, PopSig: signer.PopcodeSig})
 		}
+		popcode := signers[i].Pop
+		if source.Output < 0 || int(source.Output) >= len(popcode.Outputs) {
+			return fmt.Errorf("Invalid output index in combine %d", source.Output)
+		}
+		signers[i].Sources = append(signers[i].Sources, &TuxedoPopsTX.CombineSources{SourceOutput: source.Output, SourceAmount: source.Amount})
+		combineEvent.Sources = append(combineEvent.Sources, &TxEvents.MultiCombineSource{
+			SourceCounter: popcode.Outputs[source.Output].PrevCounter,
+			Address:       source.Address,
+			Output:        source.Output,
+			Amount:        source.Amount,
+			Type:          popcode.Outputs[source.Output].Type,
+		})
+	}
+	if len(signers) != len(combineArgs.Signers) {
+		return fmt.Errorf("Every signer of a combine must have sources")
+	}
 
-		sigHash := sha256.Sum256(createArgs.CreatorSig[:])
-		cacheIndex := hex.EncodeToString(sigHash[:])
-		txCache.Cache[cacheIndex] = true
-		err = stub.PutState("Popcode:"+createArgs.Address, popcode.ToBytes())
+	var dest *Pop.Pop
+	if i, ok := signerIdx[combineArgs.Destination]; ok {
+		dest = signers[i].Pop
+	} else {
+		dest, err = getDestPopcode(stub, combineArgs.Destination, st.counterseed)
 		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+			return err
 		}
-		createEventBytes, err := proto.Marshal(&createEvent)
-		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+	}
+
+	recipe, err := combineRecipe(stub, combineArgs.Recipe, int(combineArgs.RecipeVersion))
+	if err != nil {
+		return err
+	}
+	combineEvent.RecipeVersion = recipe.Version
+	// the creator of the combine mints its products, whose types may have
+	// been registered after the recipe
+	err = checkProductIssuers(stub, recipe, combineArgs.CreatorPubKey)
+	if err != nil {
+		return err
+	}
+
+	pops := []*Pop.Pop{dest}
+	for _, signer := range signers {
+		if signer.Pop != dest {
+			pops = append(pops, signer.Pop)
+		}
+	}
+	step := newStep(stub, "multiCombine", argsBytes)
+	for _, signer := range signers {
+		indices := []int{}
+		for _, source := range signer.Sources {
+			indices = append(indices, source.Idx())
 		}
-		stub.SetEvent("create", createEventBytes)
+		step.Popcodes = append(step.Popcodes, proofPopcode(signer.Pop, indices...))
+	}
+	if len(pops) > len(signers) {
+		step.Popcodes = append(step.Popcodes, proofPopcode(dest))
+	}
+	step.Recipe = recipe
+	unitsBefore := unitsByType(pops...)
+	err = dest.CombineAcross(signers, int(combineArgs.Amount), combineArgs.Recipe, int(combineArgs.RecipeVersion), *recipe,
+		combineArgs.Data, combineArgs.CreatorPubKey, combineArgs.CreatorSig, combineArgs.ApprovalSigs, combineArgs.ReturnChange, spendTime(stub), int(combineArgs.Version))
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
 
-	case "transfer":
-		transferEvent := TxEvents.TransferEvent{}
+	products, minted := combineProducts(recipe, int(combineArgs.Amount), dest)
+	combineEvent.Products = products
+	unitsAfter := unitsByType(pops...)
+	consumed, err := recordCombine(stub, unitsBefore, unitsAfter, products)
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]int64)
+	for _, source := range combineEvent.Sources {
+		taken[source.Type] += int64(source.Amount)
+	}
+	combineEvent.Returned = returnedChange(taken, unitsBefore, unitsAfter, products)
+	combineEvent.Waste = consumed - minted
+	combineEvent.Change = -consumed
+	for _, source := range combineArgs.Sources {
+		combineEvent.Change += int64(source.Amount)
+	}
 
-		transferArgs := TuxedoPopsTX.TransferOwners{}
-		err = proto.Unmarshal(argsBytes, &transferArgs)
+	parents := [][]byte{}
+	for _, source := range combineEvent.Sources {
+		parents = append(parents, source.SourceCounter)
+	}
+	stepHash, err := putStep(stub, step, &combineEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	for _, product := range products {
+		err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: parents, Operation: "multiCombine", Address: dest.Address,
+			Type: product.Type, Recipe: combineArgs.Recipe, RecipeVersion: recipe.Version}, product.DestCounter)
 		if err != nil {
-			fmt.Println("Invalid argument expected TransferOwners protocol buffer")
-			return nil, fmt.Errorf("Invalid argument expected TransferOwners protocol buffer %s", err.Error())
+			fmt.Println(err.Error())
+			return err
 		}
+	}
 
-		transferEvent.Address = transferArgs.Address
-		transferEvent.Data = transferArgs.Data
-		transferEvent.Owners = transferArgs.Owners
-		if len(transferArgs.Owners) < int(transferArgs.Threshold) {
-			return nil, fmt.Errorf("threshold value (%d) is larger than number of owners (%d) for popcode output on address (%s)",
-				transferArgs.Threshold, len(transferArgs.Owners), transferArgs.Address)
+	for _, signer := range signers {
+		if signer.Pop == dest {
+			continue
+		}
+		err = putPopcode(stub, signer.Pop.Address, signer.Pop)
+		if err != nil {
+			fmt.Println(err.Error())
+			return err
 		}
-		transferEvent.Threshold = transferArgs.Threshold
+	}
+	err = putPopcode(stub, dest.Address, dest)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	combineEventBytes, err := proto.Marshal(&combineEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("multiCombine", combineEventBytes)
+	return nil
+}
+
+func (t *tuxedoPopsChaincode) multiUnitize(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	multiUnitizeEvent := TxEvents.MultiUnitizeEvent{}
+	multiUnitizeArgs := TuxedoPopsTX.MultiUnitize{}
+	err := proto.Unmarshal(argsBytes, &multiUnitizeArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected MultiUnitize protocol buffer")
+		return fmt.Errorf("Invalid argument expected MultiUnitize protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, multiUnitizeArgs.Version)
+	if err != nil {
+		return err
+	}
 
-		popcodeKeyDigest := sha256.Sum256(transferArgs.PopcodePubKey)
-		transferAddress := hex.EncodeToString(popcodeKeyDigest[:20])
+	multiUnitizeEvent.Data = multiUnitizeArgs.Data
+	multiUnitizeEvent.PopcodePubKey = multiUnitizeArgs.PopcodePubKey
+	multiUnitizeEvent.SourceAddress = multiUnitizeArgs.SourceAddress
+	multiUnitizeEvent.SourceOutput = multiUnitizeArgs.SourceOutput
 
-		if transferAddress != transferArgs.Address {
-			return nil, fmt.Errorf("Public key %s does not derive address of %s", transferArgs.PopcodePubKey, transferArgs.Address)
-		}
+	popcodeKeyDigest := sha256.Sum256(multiUnitizeArgs.PopcodePubKey)
+	sourceAddress := hex.EncodeToString(popcodeKeyDigest[:20])
+	if multiUnitizeArgs.SourceAddress != sourceAddress {
+		return fmt.Errorf("Public key %s does not derive address of %s", multiUnitizeArgs.PopcodePubKey, multiUnitizeArgs.SourceAddress)
+	}
+	sourcePopcodeBytes, err := stub.GetState("Popcode:" + sourceAddress)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+	if len(sourcePopcodeBytes) == 0 {
+		fmt.Println("No value found in popcode")
+		return errors.New("No value found in popcode")
+	}
+	sourcePopcode := Pop.Pop{}
+	err = sourcePopcode.FromBytes(sourcePopcodeBytes)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
 
-		popcodebytes, err := stub.GetState("Popcode:" + transferArgs.Address)
+	if multiUnitizeArgs.SourceOutput < 0 || int(multiUnitizeArgs.SourceOutput) >= len(sourcePopcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", multiUnitizeArgs.SourceOutput, sourcePopcode.ToJSON())
+	}
+	multiUnitizeEvent.SourceCounter = sourcePopcode.Outputs[multiUnitizeArgs.SourceOutput].PrevCounter
+	multiUnitizeEvent.Type = sourcePopcode.Outputs[multiUnitizeArgs.SourceOutput].Type
+	changeCounter := sourcePopcode.Counter
+	sourceAmount := sourcePopcode.Outputs[multiUnitizeArgs.SourceOutput].Amount
+	distributedAmount := 0
+
+	destPopcodes := make([]*Pop.Pop, len(multiUnitizeArgs.Destinations))
+	destAmounts := make([][]int, len(multiUnitizeArgs.Destinations))
+	for i, destination := range multiUnitizeArgs.Destinations {
+		destPopcodes[i], err = getDestPopcode(stub, destination.DestAddress, sourcePopcode.Counter)
 		if err != nil {
-			fmt.Println("Could not get Popcode State")
-			return nil, errors.New("Could not get Popcode State")
+			return err
 		}
-		if len(popcodebytes) == 0 {
-			fmt.Println("No value found in popcode")
-			return nil, errors.New("No value found in popcode")
+		destAmounts[i] = make([]int, len(destination.DestAmounts))
+		for j, destAmount := range destination.DestAmounts {
+			destAmounts[i][j] = int(destAmount)
+			distributedAmount += int(destAmount)
 		}
-		popcode := Pop.Pop{}
-		popcode.FromBytes(popcodebytes)
+	}
 
-		if transferArgs.Output < 0 || int(transferArgs.Output) >= len(popcode.Outputs) {
-			return nil, fmt.Errorf("Invalid Output index %d %s", transferArgs.Output, popcode.ToJSON())
+	step := newStep(stub, "multiUnitize", argsBytes, proofPopcode(&sourcePopcode, int(multiUnitizeArgs.SourceOutput)))
+	for _, destPopcode := range destPopcodes {
+		step.Popcodes = append(step.Popcodes, proofPopcode(destPopcode))
+	}
+	err = sourcePopcode.MultiUnitizeOutput(int(multiUnitizeArgs.SourceOutput), destPopcodes, destAmounts, multiUnitizeArgs.KeepChange,
+		multiUnitizeArgs.Data, multiUnitizeArgs.OwnerSigs, multiUnitizeArgs.PopcodePubKey, multiUnitizeArgs.PopcodeSig, spendTime(stub), int(multiUnitizeArgs.Version))
+	if err != nil {
+		fmt.Printf("MultiUnitize error: %s", err.Error())
+		return fmt.Errorf("MultiUnitize error: %s", err.Error())
+	}
+
+	for i, destPopcode := range destPopcodes {
+		eventDest := TxEvents.UnitizeDestination{}
+		eventDest.DestAddress = destPopcode.Address
+		first := len(destPopcode.Outputs) - len(destAmounts[i])
+		for _, output := range destPopcode.Outputs[first:] {
+			eventDest.DestCounters = append(eventDest.DestCounters, output.PrevCounter)
+			eventDest.DestAmounts = append(eventDest.DestAmounts, int32(output.Amount))
 		}
-		transferEvent.SourceCounter = popcode.Outputs[transferArgs.Output].PrevCounter
+		multiUnitizeEvent.Destinations = append(multiUnitizeEvent.Destinations, &eventDest)
 
-		err = popcode.SetOwner(int(transferArgs.Output), int(transferArgs.Threshold), transferArgs.Data, transferArgs.Owners, transferArgs.PrevOwnerSigs, transferArgs.PopcodePubKey, transferArgs.PopcodeSig)
+		err = putPopcode(stub, destPopcode.Address, destPopcode)
 		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+			fmt.Println(err.Error())
+			return err
 		}
+	}
+	if sourceAmount > distributedAmount {
+		multiUnitizeEvent.ChangeCounter = changeCounter
+		multiUnitizeEvent.ChangeAmount = int32(sourceAmount - distributedAmount)
+	}
 
-		transferEvent.DestCounter = popcode.Outputs[transferArgs.Output].PrevCounter
-		transferEvent.Amount = int32(popcode.Outputs[transferArgs.Output].Amount)
-		transferEvent.Type = popcode.Outputs[transferArgs.Output].Type
-
-		err = stub.PutState("Popcode:"+transferArgs.Address, popcode.ToBytes())
+	stepHash, err := putStep(stub, step, &multiUnitizeEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	for _, eventDest := range multiUnitizeEvent.Destinations {
+		err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{multiUnitizeEvent.SourceCounter}, Operation: "multiUnitize",
+			Address: eventDest.DestAddress, Type: multiUnitizeEvent.Type}, eventDest.DestCounters...)
 		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+			fmt.Println(err.Error())
+			return err
 		}
-		transferEventBytes, err := proto.Marshal(&transferEvent)
+	}
+	if multiUnitizeEvent.ChangeCounter != nil {
+		err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{multiUnitizeEvent.SourceCounter}, Operation: "multiUnitize",
+			Address: sourceAddress, Type: multiUnitizeEvent.Type}, multiUnitizeEvent.ChangeCounter)
 		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+			fmt.Println(err.Error())
+			return err
 		}
-		stub.SetEvent("transfer", transferEventBytes)
+	}
 
-	case "unitize":
-		unitizeEvent := TxEvents.UnitizeEvent{}
-		unitizeArgs := TuxedoPopsTX.Unitize{}
-		err = proto.Unmarshal(argsBytes, &unitizeArgs)
-		if err != nil {
-			fmt.Println("Invalid argument expected Unitize protocol buffer")
-			return nil, fmt.Errorf("Invalid argument expected Unitize protocol buffer %s", err.Error())
-		}
+	err = putPopcode(stub, sourceAddress, &sourcePopcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	multiUnitizeEventBytes, err := proto.Marshal(&multiUnitizeEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("multiUnitize", multiUnitizeEventBytes)
+	return nil
+}
 
-		if unitizeArgs.SourceAddress == unitizeArgs.DestAddress {
-			return nil, fmt.Errorf("The source address %s must be different from dest address %s", unitizeArgs.SourceAddress, unitizeArgs.DestAddress)
-		}
-		fmt.Printf("\n\n\nOWNERSIGS for UNITIZE: (%v)\n\n", unitizeArgs.OwnerSigs)
+func (t *tuxedoPopsChaincode) burn(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	burnEvent := TxEvents.BurnEvent{}
+	burnArgs := TuxedoPopsTX.Burn{}
+	err := proto.Unmarshal(argsBytes, &burnArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Burn protocol buffer")
+		return fmt.Errorf("Invalid argument expected Burn protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, burnArgs.Version)
+	if err != nil {
+		return err
+	}
 
-		unitizeEvent.Data = unitizeArgs.Data
-		unitizeEvent.DestAddress = unitizeArgs.DestAddress
-		unitizeEvent.PopcodePubKey = unitizeArgs.PopcodePubKey
-		unitizeEvent.SourceAddress = unitizeArgs.SourceAddress
-		unitizeEvent.SourceOutput = unitizeArgs.SourceOutput
+	burnEvent.Address = burnArgs.Address
+	burnEvent.Output = burnArgs.Output
+	burnEvent.Amount = burnArgs.Amount
+	burnEvent.Redemption = burnArgs.Redemption
+	burnEvent.PopcodePubKey = burnArgs.PopcodePubKey
 
-		popcodeKeyDigest := sha256.Sum256(unitizeArgs.PopcodePubKey)
-		sourceAddress := hex.EncodeToString(popcodeKeyDigest[:20])
-		if unitizeArgs.SourceAddress != sourceAddress {
-			return nil, fmt.Errorf("Public key %s does not derive address of %s", unitizeArgs.PopcodePubKey, unitizeArgs.SourceAddress)
-		}
-		sourcePopcodeBytes, err := stub.GetState("Popcode:" + sourceAddress)
+	popcodeKeyDigest := sha256.Sum256(burnArgs.PopcodePubKey)
+	burnAddress := hex.EncodeToString(popcodeKeyDigest[:20])
+	if burnAddress != burnArgs.Address {
+		return fmt.Errorf("Public key %s does not derive address of %s", burnArgs.PopcodePubKey, burnArgs.Address)
+	}
+
+	popcodeBytes, err := stub.GetState("Popcode:" + burnAddress)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return errors.New("Could not get Popcode State")
+	}
+	if len(popcodeBytes) == 0 {
+		fmt.Println("No value found in popcode")
+		return errors.New("No value found in popcode")
+	}
+	popcode := Pop.Pop{}
+	err = popcode.FromBytes(popcodeBytes)
+	if err != nil {
+		fmt.Println("Popcode Deserialization error")
+		return errors.New("Popcode Deserialization Failure")
+	}
+
+	if burnArgs.Output < 0 || int(burnArgs.Output) >= len(popcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", burnArgs.Output, popcode.ToJSON())
+	}
+	burnEvent.SourceCounter = popcode.Outputs[burnArgs.Output].PrevCounter
+	burnEvent.Type = popcode.Outputs[burnArgs.Output].Type
+	burnEvent.DestCounter = popcode.Counter
+	sourceAmount := popcode.Outputs[burnArgs.Output].Amount
+
+	step := newStep(stub, "burn", argsBytes, proofPopcode(&popcode, int(burnArgs.Output)))
+	err = popcode.BurnOutput(int(burnArgs.Output), int(burnArgs.Amount), burnArgs.Redemption, burnArgs.OwnerSigs,
+		burnArgs.PopcodePubKey, burnArgs.PopcodeSig, spendTime(stub), int(burnArgs.Version))
+	if err != nil {
+		fmt.Printf("Burn error: %s", err.Error())
+		return fmt.Errorf("Burn error: %s", err.Error())
+	}
+	err = recordBurned(stub, burnEvent.Type, int64(burnArgs.Amount))
+	if err != nil {
+		return err
+	}
+
+	// what is left of a partly burnt output moves to a new counter
+	if sourceAmount > int(burnArgs.Amount) {
+		stepHash, err := putStep(stub, step, &burnEvent)
 		if err != nil {
-			fmt.Println("Could not get Popcode State")
-			return nil, errors.New("Could not get Popcode State")
+			fmt.Println(err.Error())
+			return err
 		}
-		if len(sourcePopcodeBytes) == 0 {
-			fmt.Println("No value found in popcode")
-			return nil, errors.New("No value found in popcode")
-		}
-		sourcePopcode := Pop.Pop{}
-		err = sourcePopcode.FromBytes(sourcePopcodeBytes)
+		err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{burnEvent.SourceCounter}, Operation: "burn",
+			Address: burnAddress, Type: burnEvent.Type}, burnEvent.DestCounter)
 		if err != nil {
-			fmt.Println("Could not get Popcode State")
-			return nil, errors.New("Could not get Popcode State")
+			fmt.Println(err.Error())
+			return err
 		}
+	}
 
-		if unitizeArgs.SourceOutput < 0 || int(unitizeArgs.SourceOutput) >= len(sourcePopcode.Outputs) {
-			return nil, fmt.Errorf("Invalid Output index %d %s", unitizeArgs.SourceOutput, sourcePopcode.ToJSON())
-		}
-		unitizeEvent.SourceCounter = sourcePopcode.Outputs[unitizeArgs.SourceOutput].PrevCounter
-		destAddress := unitizeArgs.DestAddress
-		destPopcodeBytes, err := stub.GetState("Popcode:" + destAddress)
+	err = putPopcode(stub, burnAddress, &popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	burnEventBytes, err := proto.Marshal(&burnEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("burn", burnEventBytes)
+	return nil
+}
+
+func (t *tuxedoPopsChaincode) registerRecipe(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	recipeArgs := TuxedoPopsTX.Recipe{}
+	err := proto.Unmarshal(argsBytes, &recipeArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Recipe protocol buffer")
+		return fmt.Errorf("Invalid argument expected Recipe protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, recipeArgs.Version)
+	if err != nil {
+		return err
+	}
+	recipeVersion := int(recipeArgs.RecipeVersion)
+	if recipeVersion < 0 {
+		return fmt.Errorf("Invalid version %d of Recipe (%s)\n", recipeVersion, recipeArgs.RecipeName)
+	}
+	if recipeVersion != 0 || len(recipeArgs.Byproducts) > 0 || len(recipeArgs.Manufacturers) > 0 {
+		err = Pop.CheckCanonical(int(recipeArgs.Version))
 		if err != nil {
-			return nil, errors.New("Could not get Popcode State")
-		}
-		destPopcode := Pop.Pop{}
-		if len(destPopcodeBytes) == 0 {
-			destAddressBytes, err := hex.DecodeString(destAddress)
-			if err != nil {
-				return nil, fmt.Errorf("Invalid address %s", destAddress)
-			}
-			hasher := sha256.New()
-			hasher.Write(sourcePopcode.Counter)
-			hasher.Write(destAddressBytes)
-			hashedCounterSeed := []byte{}
-			hashedCounterSeed = hasher.Sum(hashedCounterSeed)
-			destPopcode.Address = unitizeArgs.DestAddress
-			destPopcode.Counter = hashedCounterSeed[:]
-		} else {
-			err = destPopcode.FromBytes(destPopcodeBytes)
-			if err != nil {
-				fmt.Println("Dest Popcode Deserialization error")
-				return nil, errors.New("Dest Popcode Deserialization Failure")
-			}
+			return err
 		}
-		convertedAmounts := make([]int, len(unitizeArgs.DestAmounts))
-		for i, destAmount := range unitizeArgs.DestAmounts {
-			convertedAmounts[i] = int(destAmount)
+	}
+	if recipeVersion == 0 {
+		recipeVersion = 1
+	}
+	first, err := getRecipe(stub, recipeArgs.RecipeName, 1)
+	if err != nil {
+		fmt.Println("Could not get Recipe State")
+		return fmt.Errorf("Could not get Recipe (%s) state\n", recipeArgs.RecipeName)
+	}
+
+	//if recipe already exists
+	if recipeVersion == 1 && first != nil {
+		fmt.Printf("Recipe (%s) already registered\n", recipeArgs.RecipeName)
+		return fmt.Errorf("Recipe (%s) already registered\n", recipeArgs.RecipeName)
+	}
+	if recipeVersion > 1 {
+		if first == nil {
+			return fmt.Errorf("Recipe (%s) is not registered\n", recipeArgs.RecipeName)
 		}
-		err = sourcePopcode.UnitizeOutput(int(unitizeArgs.SourceOutput), convertedAmounts, unitizeArgs.Data,
-			&destPopcode, unitizeArgs.OwnerSigs, unitizeArgs.PopcodePubKey, unitizeArgs.PopcodeSig)
-		if err != nil {
-			fmt.Printf("Unitize error: %s", err.Error())
-			return nil, fmt.Errorf("Unitize error: %s", err.Error())
+		if recipeVersion != int(first.Latest)+1 {
+			return fmt.Errorf("Recipe (%s) version %d must follow version %d\n", recipeArgs.RecipeName, recipeVersion, first.Latest)
 		}
+	}
 
-		// The idea here is to harvest the created Counter values for the destinations via revserse interation through the number of events coordinated
-		for index := len(destPopcode.Outputs) - 1; index > len(destPopcode.Outputs)-1-len(unitizeArgs.DestAmounts); index-- {
-			fmt.Printf("\x1b[32m\n\n\ndestPopcode.Address: (%s)\ndestpopcode.Outputs: (%v)\nindex: (%d)\nnumber of destination amounts: (%d)\nstopping condition: index <= (%d)\n\n\x1b[0m",
-				destPopcode.Address, destPopcode.Outputs, index, len(unitizeArgs.DestAmounts), len(destPopcode.Outputs)-1-len(unitizeArgs.DestAmounts))
+	message := Pop.RecipeMessage(int(recipeArgs.Version), recipeArgs.RecipeName, int(recipeArgs.RecipeVersion), recipeArgs.CreatedType, recipeArgs.Ingredients, recipeArgs.Byproducts,
+		recipeArgs.Manufacturers, int(recipeArgs.Threshold))
+	creatorPubKey, err := verifyCreatorSig(recipeArgs.CreatorPubKey, recipeArgs.CreatorSig, message)
+	if err != nil {
+		return err
+	}
+	if recipeVersion > 1 && !isCreator(first, creatorPubKey) {
+		return fmt.Errorf("Only the creator of recipe (%s) can register new versions\n", recipeArgs.RecipeName)
+	}
 
-			unitizeEvent.DestCounters = append(unitizeEvent.DestCounters, destPopcode.Outputs[index].PrevCounter)
-			unitizeEvent.DestAmounts = append(unitizeEvent.DestAmounts, int32(destPopcode.Outputs[index].Amount))
+	recStore := TuxedoPopsStore.Recipe{}
+	recStore.CreatedType = recipeArgs.CreatedType
+	recStore.Creator = recipeArgs.CreatorPubKey
+	recStore.Version = int32(recipeVersion)
+	if len(recipeArgs.Ingredients) == 0 {
+		return fmt.Errorf("Recipe (%s) needs at least one ingredient\n", recipeArgs.RecipeName)
+	}
+	for _, ingredient := range recipeArgs.Ingredients {
+		if ingredient.Numerator <= 0 || ingredient.Denominator <= 0 {
+			return fmt.Errorf("Invalid ratio %d/%d for ingredient %s\n", ingredient.Numerator, ingredient.Denominator, ingredient.Type)
 		}
-
-		err = stub.PutState("Popcode:"+sourceAddress, sourcePopcode.ToBytes())
-		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+		ingredientStore := TuxedoPopsStore.Ingredient{}
+		ingredientStore.Numerator = int64(ingredient.Numerator)
+		ingredientStore.Denominator = int64(ingredient.Denominator)
+		ingredientStore.Type = ingredient.Type
+		recStore.Ingredients = append(recStore.Ingredients, &ingredientStore)
+	}
+	for _, byproduct := range recipeArgs.Byproducts {
+		if byproduct.Numerator <= 0 || byproduct.Denominator <= 0 {
+			return fmt.Errorf("Invalid ratio %d/%d for byproduct %s\n", byproduct.Numerator, byproduct.Denominator, byproduct.Type)
 		}
-		err = stub.PutState("Popcode:"+destAddress, destPopcode.ToBytes())
+		productStore := TuxedoPopsStore.Product{}
+		productStore.Numerator = int64(byproduct.Numerator)
+		productStore.Denominator = int64(byproduct.Denominator)
+		productStore.Type = byproduct.Type
+		recStore.Byproducts = append(recStore.Byproducts, &productStore)
+	}
+	err = checkProductIssuers(stub, &recStore, recipeArgs.CreatorPubKey)
+	if err != nil {
+		return err
+	}
+	if recipeArgs.Threshold < 0 || int(recipeArgs.Threshold) > len(recipeArgs.Manufacturers) {
+		return fmt.Errorf("Invalid threshold %d for %d manufacturers\n", recipeArgs.Threshold, len(recipeArgs.Manufacturers))
+	}
+	for _, manufacturer := range recipeArgs.Manufacturers {
+		_, err = btcec.ParsePubKey(manufacturer, btcec.S256())
 		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+			return fmt.Errorf("Could not deserialize Manufacturer Pub Key (%v)\n", manufacturer)
 		}
-		unitizeEventBytes, err := proto.Marshal(&unitizeEvent)
+	}
+	recStore.Manufacturers = recipeArgs.Manufacturers
+	recStore.Threshold = recipeArgs.Threshold
+	if recipeVersion == 1 {
+		recStore.Latest = 1
+	} else {
+		first.Latest = int32(recipeVersion)
+		err = putRecipe(stub, recipeArgs.RecipeName, first)
 		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
+			return err
 		}
-		stub.SetEvent("unitize", unitizeEventBytes)
+	}
+	fmt.Printf("PUTTING RECIPE (%s) VERSION %d TO LEDGER\n", recipeArgs.RecipeName, recipeVersion)
+	err = putRecipe(stub, recipeArgs.RecipeName, &recStore)
+	if err != nil {
+		return err
+	}
 
-	case "combine":
+	recipeEvent := TxEvents.RecipeEvent{}
+	recipeEvent.RecipeName = recipeArgs.RecipeName
+	recipeEvent.RecipeVersion = int32(recipeVersion)
+	recipeEvent.CreatedType = recipeArgs.CreatedType
+	for _, ingredient := range recipeArgs.Ingredients {
+		recipeEvent.Ingredients = append(recipeEvent.Ingredients, &TxEvents.RecipeComponent{Type: ingredient.Type,
+			Numerator: ingredient.Numerator, Denominator: ingredient.Denominator})
+	}
+	for _, byproduct := range recipeArgs.Byproducts {
+		recipeEvent.Byproducts = append(recipeEvent.Byproducts, &TxEvents.RecipeComponent{Type: byproduct.Type,
+			Numerator: byproduct.Numerator, Denominator: byproduct.Denominator})
+	}
+	recipeEvent.Manufacturers = recipeArgs.Manufacturers
+	recipeEvent.Threshold = recipeArgs.Threshold
+	recipeEvent.CreatorPubKey = recipeArgs.CreatorPubKey
+	recipeEventBytes, err := proto.Marshal(&recipeEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("recipe", recipeEventBytes)
+	return nil
+}
 
-		combineEvent := TxEvents.CombineEvent{}
-		combineArgs := TuxedoPopsTX.Combine{}
+func (t *tuxedoPopsChaincode) swap(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	swapEvent := TxEvents.SwapEvent{}
+	swapArgs := TuxedoPopsTX.Swap{}
+	err := proto.Unmarshal(argsBytes, &swapArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Swap protocol buffer")
+		return fmt.Errorf("Invalid argument expected Swap protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, swapArgs.Version)
+	if err != nil {
+		return err
+	}
+	a := swapArgs.GetA()
+	b := swapArgs.GetB()
+	if a == nil || b == nil {
+		return fmt.Errorf("A swap needs two sides")
+	}
+	if a.Address == b.Address {
+		return fmt.Errorf("The popcodes of a swap must be different, got %s twice", a.Address)
+	}
 
-		err = proto.Unmarshal(argsBytes, &combineArgs)
-		if err != nil {
-			fmt.Println("Invalid argument expected Combine protocol buffer")
-			return nil, fmt.Errorf("Invalid argument expected Combine protocol buffer %s", err.Error())
-		}
-		combineEvent.Address = combineArgs.Address
-		combineEvent.Amount = combineArgs.Amount
-		combineEvent.CreatorPubKey = combineArgs.CreatorPubKey
-		combineEvent.Data = combineArgs.Data
-		combineEvent.Recipe = combineArgs.Recipe
+	popcodeA, err := getPopcode(stub, a.Address, a.PopcodePubKey)
+	if err != nil {
+		return err
+	}
+	popcodeB, err := getPopcode(stub, b.Address, b.PopcodePubKey)
+	if err != nil {
+		return err
+	}
+	if a.Output < 0 || int(a.Output) >= len(popcodeA.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", a.Output, popcodeA.ToJSON())
+	}
+	if b.Output < 0 || int(b.Output) >= len(popcodeB.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", b.Output, popcodeB.ToJSON())
+	}
 
-		for _, source := range combineArgs.Sources {
-			evSource := TxEvents.CombineSources{}
-			evSource.SourceAmount = source.SourceAmount
-			evSource.SourceOutput = source.SourceOutput
-			combineEvent.Sources = append(combineEvent.Sources, &evSource)
-		}
+	swapEvent.Data = swapArgs.Data
+	swapEvent.A = &TxEvents.SwapSide{
+		SourceCounter: popcodeA.Outputs[a.Output].PrevCounter,
+		DestCounter:   popcodeB.Counter,
+		Address:       a.Address,
+		Output:        a.Output,
+		Amount:        a.Amount,
+		Type:          popcodeA.Outputs[a.Output].Type,
+		PopcodePubKey: a.PopcodePubKey,
+	}
+	swapEvent.B = &TxEvents.SwapSide{
+		SourceCounter: popcodeB.Outputs[b.Output].PrevCounter,
+		DestCounter:   popcodeA.Counter,
+		Address:       b.Address,
+		Output:        b.Output,
+		Amount:        b.Amount,
+		Type:          popcodeB.Outputs[b.Output].Type,
+		PopcodePubKey: b.PopcodePubKey,
+	}
 
-		popcodeKeyDigest := sha256.Sum256(combineArgs.PopcodePubKey)
-		combineAddress := hex.EncodeToString(popcodeKeyDigest[:20])
-		if combineAddress != combineArgs.Address {
-			return nil, fmt.Errorf("Public key %s does not derive address of %s", combineArgs.PopcodePubKey, combineArgs.Address)
-		}
+	step := newStep(stub, "swap", argsBytes, proofPopcode(popcodeA, int(a.Output)), proofPopcode(popcodeB, int(b.Output)))
+	err = popcodeA.SwapOutputs(int(a.Output), int(a.Amount), a.OwnerSigs, a.PopcodePubKey, a.PopcodeSig,
+		popcodeB, int(b.Output), int(b.Amount), b.OwnerSigs, b.PopcodePubKey, b.PopcodeSig,
+		swapArgs.Data, spendTime(stub), int(swapArgs.Version))
+	if err != nil {
+		fmt.Printf("Swap error: %s", err.Error())
+		return fmt.Errorf("Swap error: %s", err.Error())
+	}
 
-		popcode := Pop.Pop{}
-		popcodeBytes, err := stub.GetState("Popcode:" + combineAddress)
-		if err != nil {
-			fmt.Println("Could not get Popcode State")
-			return nil, errors.New("Could not get Popcode State")
+	stepHash, err := putStep(stub, step, &swapEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	for _, side := range []*TxEvents.SwapSide{swapEvent.A, swapEvent.B} {
+		// each side's units arrive on the other popcode
+		address := swapEvent.B.Address
+		if side == swapEvent.B {
+			address = swapEvent.A.Address
 		}
-		if len(popcodeBytes) == 0 {
-			fmt.Println("No value found in popcode")
-			return nil, errors.New("No value found in popcode")
+		err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{side.SourceCounter}, Operation: "swap",
+			Address: address, Type: side.Type}, side.DestCounter)
+		if err != nil {
+			fmt.Println(err.Error())
+			return err
 		}
-		popcode.FromBytes(popcodeBytes)
+	}
 
-		recipeBytes, err := stub.GetState("Recipe:" + combineArgs.Recipe)
+	err = putPopcode(stub, popcodeA.Address, popcodeA)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, popcodeB.Address, popcodeB)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	swapEventBytes, err := proto.Marshal(&swapEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("swap", swapEventBytes)
+	return nil
+}
 
-		if err != nil {
-			fmt.Println("Could not get Recipe State")
-			return nil, errors.New("Could not get Recipe State")
-		}
-		if len(recipeBytes) == 0 {
-			fmt.Printf("Recipe %s not registered", combineArgs.Recipe)
-			return nil, fmt.Errorf("Recipe %s is not registered", combineArgs.Recipe)
-		}
-		recipe := TuxedoPopsStore.Recipe{}
-		err = proto.Unmarshal(recipeBytes, &recipe)
-		if err != nil {
-			return nil, fmt.Errorf("Could not deserialize Recipe %s", combineArgs.Recipe)
-		}
+// txTime returns the transaction timestamp in unix seconds. It is a variable
+// so that tests can supply the time MockStub does not.
+var txTime = func(stub shim.ChaincodeStubInterface) (int64, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		fmt.Println("Could not get transaction timestamp")
+		return 0, errors.New("Could not get transaction timestamp")
+	}
+	if timestamp == nil {
+		fmt.Println("No transaction timestamp")
+		return 0, errors.New("No transaction timestamp")
+	}
+	return timestamp.Seconds, nil
+}
 
-		sources := make([]Pop.SourceOutput, len(combineArgs.Sources))
+// spendTime is the time against which time locked outputs are checked.
+// Without a transaction timestamp every time lock is treated as unvested.
+func spendTime(stub shim.ChaincodeStubInterface) int64 {
+	now, err := txTime(stub)
+	if err != nil {
+		return math.MinInt64
+	}
+	return now
+}
 
-		for i, v := range combineArgs.Sources {
-			sources[i] = v
+func (t *tuxedoPopsChaincode) timeLock(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	timeLockEvent := TxEvents.TimeLockEvent{}
+	timeLockArgs := TuxedoPopsTX.TimeLock{}
+	err := proto.Unmarshal(argsBytes, &timeLockArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected TimeLock protocol buffer")
+		return fmt.Errorf("Invalid argument expected TimeLock protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, timeLockArgs.Version)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
 
-			if v.Idx() < len(popcode.Outputs) {
-				combineEvent.SourceCounters = append(combineEvent.SourceCounters, popcode.Outputs[v.Idx()].PrevCounter)
-			} else {
-				return nil, fmt.Errorf("Invalid output index in combine %d", v.Idx())
-			}
+	popcode, err := getPopcode(stub, timeLockArgs.Address, timeLockArgs.PopcodePubKey)
+	if err != nil {
+		return err
+	}
+	if timeLockArgs.Output < 0 || int(timeLockArgs.Output) >= len(popcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", timeLockArgs.Output, popcode.ToJSON())
+	}
+	timeLockEvent.SourceCounter = popcode.Outputs[timeLockArgs.Output].PrevCounter
+	timeLockEvent.DestCounter = popcode.Counter
+	timeLockEvent.Address = timeLockArgs.Address
+	timeLockEvent.Output = timeLockArgs.Output
+	timeLockEvent.Amount = timeLockArgs.Amount
+	timeLockEvent.Type = popcode.Outputs[timeLockArgs.Output].Type
+	timeLockEvent.Start = timeLockArgs.Start
+	timeLockEvent.End = timeLockArgs.End
+	timeLockEvent.Period = timeLockArgs.Period
+	timeLockEvent.PopcodePubKey = timeLockArgs.PopcodePubKey
+
+	step := newStep(stub, "timeLock", argsBytes, proofPopcode(popcode, int(timeLockArgs.Output)))
+	err = popcode.TimeLockOutput(int(timeLockArgs.Output), int(timeLockArgs.Amount), timeLockArgs.Start, timeLockArgs.End, timeLockArgs.Period,
+		timeLockArgs.OwnerSigs, timeLockArgs.PopcodePubKey, timeLockArgs.PopcodeSig, now, int(timeLockArgs.Version))
+	if err != nil {
+		fmt.Printf("TimeLock error: %s", err.Error())
+		return fmt.Errorf("TimeLock error: %s", err.Error())
+	}
+	// a partial lock is split off into a new output at the end
+	timeLockEvent.LockedOutput = timeLockArgs.Output
+	if popcode.Outputs[timeLockArgs.Output].TimeLock == nil {
+		timeLockEvent.LockedOutput = int32(len(popcode.Outputs) - 1)
+	}
 
-		}
+	stepHash, err := putStep(stub, step, &timeLockEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{timeLockEvent.SourceCounter}, Operation: "timeLock",
+		Address: popcode.Address, Type: timeLockEvent.Type}, timeLockEvent.DestCounter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, popcode.Address, popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	timeLockEventBytes, err := proto.Marshal(&timeLockEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("timeLock", timeLockEventBytes)
+	return nil
+}
 
-		err = popcode.CombineOutputs(sources, combineArgs.OwnerSigs, combineArgs.PopcodePubKey, combineArgs.PopcodeSig,
-			int(combineArgs.Amount), combineArgs.Recipe, recipe, combineArgs.Data, combineArgs.CreatorPubKey, combineArgs.CreatorSig)
-		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
-		}
-		combineEvent.DestCounter = popcode.Outputs[len(popcode.Outputs)-1].PrevCounter
+func (t *tuxedoPopsChaincode) hashLock(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	hashLockEvent := TxEvents.HashLockEvent{}
+	hashLockArgs := TuxedoPopsTX.HashLock{}
+	err := proto.Unmarshal(argsBytes, &hashLockArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected HashLock protocol buffer")
+		return fmt.Errorf("Invalid argument expected HashLock protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, hashLockArgs.Version)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
 
-		err = stub.PutState("Popcode:"+combineAddress, popcode.ToBytes())
-		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
-		}
-		combineEventBytes, err := proto.Marshal(&combineEvent)
-		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, err
-		}
-		stub.SetEvent("combine", combineEventBytes)
+	popcode, err := getPopcode(stub, hashLockArgs.Address, hashLockArgs.PopcodePubKey)
+	if err != nil {
+		return err
+	}
+	if hashLockArgs.Output < 0 || int(hashLockArgs.Output) >= len(popcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", hashLockArgs.Output, popcode.ToJSON())
+	}
+	hashLockEvent.SourceCounter = popcode.Outputs[hashLockArgs.Output].PrevCounter
+	hashLockEvent.DestCounter = popcode.Counter
+	hashLockEvent.Address = hashLockArgs.Address
+	hashLockEvent.Output = hashLockArgs.Output
+	hashLockEvent.Amount = hashLockArgs.Amount
+	hashLockEvent.Type = popcode.Outputs[hashLockArgs.Output].Type
+	hashLockEvent.Hash = hashLockArgs.Hash
+	hashLockEvent.Expiry = hashLockArgs.Expiry
+	hashLockEvent.Recipient = hashLockArgs.Recipient
+	hashLockEvent.PopcodePubKey = hashLockArgs.PopcodePubKey
+
+	step := newStep(stub, "hashLock", argsBytes, proofPopcode(popcode, int(hashLockArgs.Output)))
+	err = popcode.LockOutput(int(hashLockArgs.Output), int(hashLockArgs.Amount), hashLockArgs.Hash, hashLockArgs.Expiry,
+		hashLockArgs.Recipient, hashLockArgs.OwnerSigs, hashLockArgs.PopcodePubKey, hashLockArgs.PopcodeSig, now, int(hashLockArgs.Version))
+	if err != nil {
+		fmt.Printf("HashLock error: %s", err.Error())
+		return fmt.Errorf("HashLock error: %s", err.Error())
+	}
+	// a partial lock is split off into a new output at the end
+	hashLockEvent.LockedOutput = hashLockArgs.Output
+	if popcode.Outputs[hashLockArgs.Output].HashLock == nil {
+		hashLockEvent.LockedOutput = int32(len(popcode.Outputs) - 1)
+	}
 
-	case "recipe":
-		recipeArgs := TuxedoPopsTX.Recipe{}
-		err = proto.Unmarshal(argsBytes, &recipeArgs)
-		if err != nil {
-			fmt.Println("Invalid argument expected Recipe protocol buffer")
-			return nil, fmt.Errorf("Invalid argument expected Recipe protocol buffer %s", err.Error())
-		}
-		recipeBytes, err := stub.GetState("Recipe:" + recipeArgs.RecipeName)
-		if err != nil {
-			fmt.Println("Could not get Recipe State")
-			return nil, fmt.Errorf("Could not get Recipe (%s) state\n", recipeArgs.RecipeName)
-		}
+	stepHash, err := putStep(stub, step, &hashLockEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{hashLockEvent.SourceCounter}, Operation: "hashLock",
+		Address: popcode.Address, Type: hashLockEvent.Type}, hashLockEvent.DestCounter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, popcode.Address, popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	hashLockEventBytes, err := proto.Marshal(&hashLockEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("hashLock", hashLockEventBytes)
+	return nil
+}
 
-		//if recipe already exists
-		if len(recipeBytes) != 0 {
-			fmt.Printf("Recipe (%s) already registered\n", recipeArgs.RecipeName)
-			return nil, fmt.Errorf("Recipe (%s) already registered\n", recipeArgs.RecipeName)
-		}
+func (t *tuxedoPopsChaincode) claim(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	claimEvent := TxEvents.ClaimEvent{}
+	claimArgs := TuxedoPopsTX.Claim{}
+	err := proto.Unmarshal(argsBytes, &claimArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Claim protocol buffer")
+		return fmt.Errorf("Invalid argument expected Claim protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, claimArgs.Version)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
 
-		creatorPubKey, err := btcec.ParsePubKey(recipeArgs.CreatorPubKey, btcec.S256())
-		if err != nil {
-			return nil, fmt.Errorf("Could not deserialize Creator Pub Key (%v)", recipeArgs.CreatorPubKey)
-		}
+	popcode, err := loadPopcode(stub, claimArgs.Address)
+	if err != nil {
+		return err
+	}
+	if claimArgs.Output < 0 || int(claimArgs.Output) >= len(popcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", claimArgs.Output, popcode.ToJSON())
+	}
+	output := popcode.Outputs[claimArgs.Output]
+	claimEvent.SourceCounter = output.PrevCounter
+	claimEvent.DestCounter = popcode.Counter
+	claimEvent.Address = claimArgs.Address
+	claimEvent.Output = claimArgs.Output
+	claimEvent.Amount = int32(output.Amount)
+	claimEvent.Type = output.Type
+	claimEvent.Preimage = claimArgs.Preimage
+	if output.HashLock != nil {
+		claimEvent.Recipient = output.HashLock.Recipient.SerializeCompressed()
+	}
 
-		creatorSig, err := btcec.ParseDERSignature(recipeArgs.CreatorSig, btcec.S256())
+	step := newStep(stub, "claim", argsBytes, proofPopcode(popcode, int(claimArgs.Output)))
+	err = popcode.ClaimOutput(int(claimArgs.Output), claimArgs.Preimage, claimArgs.RecipientSig, now, int(claimArgs.Version))
+	if err != nil {
+		fmt.Printf("Claim error: %s", err.Error())
+		return fmt.Errorf("Claim error: %s", err.Error())
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("Could not deserialize Creator Signature (%v)", recipeArgs.CreatorSig)
-		}
+	stepHash, err := putStep(stub, step, &claimEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{claimEvent.SourceCounter}, Operation: "claim",
+		Address: popcode.Address, Type: claimEvent.Type}, claimEvent.DestCounter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	err = putPopcode(stub, popcode.Address, popcode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	claimEventBytes, err := proto.Marshal(&claimEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("claim", claimEventBytes)
+	return nil
+}
 
-		message := recipeArgs.RecipeName + ":" + recipeArgs.CreatedType
-		for _, ingredient := range recipeArgs.Ingredients {
-			message += ":" + strconv.FormatInt(int64(ingredient.Numerator), 10) + ":" +
-				strconv.FormatInt(int64(ingredient.Denominator), 10) + ":" + ingredient.Type
-		}
-		messageBytes := sha256.Sum256([]byte(message))
-		success := creatorSig.Verify(messageBytes[:], creatorPubKey)
-		if !success {
-			// fmt.Printf("Invalid Creator Signature (%+v)\n", creatorSig)
-			return nil, fmt.Errorf("Invalid Creator Signature (%+v)\n", creatorSig)
-		}
+func (t *tuxedoPopsChaincode) refund(stub shim.ChaincodeStubInterface, argsBytes []byte, st *invokeState) error {
+	refundEvent := TxEvents.RefundEvent{}
+	refundArgs := TuxedoPopsTX.Refund{}
+	err := proto.Unmarshal(argsBytes, &refundArgs)
+	if err != nil {
+		fmt.Println("Invalid argument expected Refund protocol buffer")
+		return fmt.Errorf("Invalid argument expected Refund protocol buffer %s", err.Error())
+	}
+	err = checkSigVersion(stub, refundArgs.Version)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
 
-		recStore := TuxedoPopsStore.Recipe{}
-		recStore.CreatedType = recipeArgs.CreatedType
-		recStore.Creator = recipeArgs.CreatorPubKey
-		for _, ingredient := range recipeArgs.Ingredients {
-			ingredientStore := TuxedoPopsStore.Ingredient{}
-			ingredientStore.Numerator = int64(ingredient.Numerator)
-			ingredientStore.Denominator = int64(ingredient.Denominator)
-			ingredientStore.Type = ingredient.Type
-			recStore.Ingredients = append(recStore.Ingredients, &ingredientStore)
-		}
-		recStoreBytes, err := proto.Marshal(&recStore)
-		if err != nil {
-			fmt.Printf("Recipe Store Serialization error\n")
-			return nil, fmt.Errorf("Recipe Store Serialization Error\n")
-		}
-		fmt.Printf("PUTTING RECIPE (%s) TO LEDGER\n", recipeArgs.RecipeName)
-		err = stub.PutState("Recipe:"+recipeArgs.RecipeName, recStoreBytes)
-		if err != nil {
-			fmt.Printf("error putting recipe state to ledger: (%s)\n", err.Error())
-			return nil, fmt.Errorf("error putting recipe state to ledger: (%s)\n", err.Error())
-		}
-	default:
-		fmt.Printf("Invalid function type (%s)", function)
-		return nil, fmt.Errorf("Invalid function type (%s)", function)
+	popcode, err := getPopcode(stub, refundArgs.Address, refundArgs.PopcodePubKey)
+	if err != nil {
+		return err
+	}
+	if refundArgs.Output < 0 || int(refundArgs.Output) >= len(popcode.Outputs) {
+		return fmt.Errorf("Invalid Output index %d %s", refundArgs.Output, popcode.ToJSON())
 	}
-	txCacheBytes, err = proto.Marshal(&txCache)
+	output := popcode.Outputs[refundArgs.Output]
+	refundEvent.SourceCounter = output.PrevCounter
+	refundEvent.DestCounter = popcode.Counter
+	refundEvent.Address = refundArgs.Address
+	refundEvent.Output = refundArgs.Output
+	refundEvent.Amount = int32(output.Amount)
+	refundEvent.Type = output.Type
+	refundEvent.PopcodePubKey = refundArgs.PopcodePubKey
+
+	step := newStep(stub, "refund", argsBytes, proofPopcode(popcode, int(refundArgs.Output)))
+	err = popcode.RefundOutput(int(refundArgs.Output), refundArgs.OwnerSigs, refundArgs.PopcodePubKey, refundArgs.PopcodeSig, now, int(refundArgs.Version))
 	if err != nil {
-		fmt.Printf("error marshalling txCache in invoke: (%v)\n", err.Error())
-		return nil, fmt.Errorf("error marshalling txCache in invoke: (%v)\n", err.Error())
+		fmt.Printf("Refund error: %s", err.Error())
+		return fmt.Errorf("Refund error: %s", err.Error())
 	}
-	if len(txCacheBytes) > 0 {
-		err = stub.PutState("TxCache", txCacheBytes)
+
+	stepHash, err := putStep(stub, step, &refundEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
 	}
+	err = putLineage(stub, &TuxedoPopsStore.Lineage{Step: stepHash, Parents: [][]byte{refundEvent.SourceCounter}, Operation: "refund",
+		Address: popcode.Address, Type: refundEvent.Type}, refundEvent.DestCounter)
 	if err != nil {
-		fmt.Printf("error putting txCache to ledger in invoke: (%v)\n", err.Error())
-		return nil, fmt.Errorf("error putting txCache to ledger in invoke: (%v)\n", err.Error())
+		fmt.Println(err.Error())
+		return err
 	}
-	err = stub.PutState("CounterSeed", counterseed)
+	err = putPopcode(stub, popcode.Address, popcode)
 	if err != nil {
-		fmt.Printf("Error putting counterseed to ledger in invoke: (%v)\n", err.Error())
-		return nil, fmt.Errorf("Error putting counterseed to ledger in invoke: (%v)\n", err.Error())
+		fmt.Println(err.Error())
+		return err
 	}
-	return nil, nil
+	refundEventBytes, err := proto.Marshal(&refundEvent)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+	stub.SetEvent("refund", refundEventBytes)
+	return nil
+}
+
+// getPopcode loads the existing popcode at address after checking that
+// pubKey derives it.
+func getPopcode(stub shim.ChaincodeStubInterface, address string, pubKey []byte) (*Pop.Pop, error) {
+	popcodeKeyDigest := sha256.Sum256(pubKey)
+	if hex.EncodeToString(popcodeKeyDigest[:20]) != address {
+		return nil, fmt.Errorf("Public key %x does not derive address of %s", pubKey, address)
+	}
+	return loadPopcode(stub, address)
+}
+
+// loadPopcode loads the existing popcode at address.
+func loadPopcode(stub shim.ChaincodeStubInterface, address string) (*Pop.Pop, error) {
+	popcodeBytes, err := stub.GetState("Popcode:" + address)
+	if err != nil {
+		fmt.Println("Could not get Popcode State")
+		return nil, errors.New("Could not get Popcode State")
+	}
+	if len(popcodeBytes) == 0 {
+		fmt.Println("No value found in popcode")
+		return nil, errors.New("No value found in popcode")
+	}
+	popcode := Pop.Pop{}
+	err = popcode.FromBytes(popcodeBytes)
+	if err != nil {
+		fmt.Println("Popcode Deserialization error")
+		return nil, errors.New("Popcode Deserialization Failure")
+	}
+	return &popcode, nil
+}
+
+// getDestPopcode loads the popcode receiving outputs at address. A popcode
+// seen for the first time gets a counter derived from seed and its address:
+// unitize and multiUnitize pass the source popcode's counter and multiCombine,
+// whose destination need not be one of its sources, the CounterSeed.
+func getDestPopcode(stub shim.ChaincodeStubInterface, address string, seed []byte) (*Pop.Pop, error) {
+	destPopcodeBytes, err := stub.GetState("Popcode:" + address)
+	if err != nil {
+		return nil, errors.New("Could not get Popcode State")
+	}
+	destPopcode := Pop.Pop{}
+	if len(destPopcodeBytes) == 0 {
+		destAddressBytes, err := hex.DecodeString(address)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid address %s", address)
+		}
+		hasher := sha256.New()
+		hasher.Write(seed)
+		hasher.Write(destAddressBytes)
+		hashedCounterSeed := []byte{}
+		hashedCounterSeed = hasher.Sum(hashedCounterSeed)
+		destPopcode.Address = address
+		destPopcode.Counter = hashedCounterSeed[:]
+	} else {
+		err = destPopcode.FromBytes(destPopcodeBytes)
+		if err != nil {
+			fmt.Println("Dest Popcode Deserialization error")
+			return nil, errors.New("Dest Popcode Deserialization Failure")
+		}
+	}
+	return &destPopcode, nil
 }
 
-func recipeToJSON(createdType string, ingredients []*TuxedoPopsStore.Ingredient, creatorPubKey []byte) ([]byte, error) {
+func recipeToJSON(recipe *TuxedoPopsStore.Recipe, latest int) ([]byte, error) {
 	type JSONRecipe struct {
-		CreatedType string
-		Ingredients []*TuxedoPopsStore.Ingredient
-		Creator     string
+		CreatedType   string
+		Ingredients   []*TuxedoPopsStore.Ingredient
+		Byproducts    []*TuxedoPopsStore.Product
+		Creator       string
+		Manufacturers []string
+		Threshold     int
+		Version       int
+		Status        string
+		Latest        int
 	}
 	jsonRecipe := JSONRecipe{}
-	jsonRecipe.CreatedType = createdType
-	jsonRecipe.Ingredients = ingredients
-	jsonRecipe.Creator = hex.EncodeToString(creatorPubKey)
+	jsonRecipe.CreatedType = recipe.CreatedType
+	jsonRecipe.Ingredients = recipe.Ingredients
+	jsonRecipe.Byproducts = recipe.Byproducts
+	jsonRecipe.Creator = hex.EncodeToString(recipe.Creator)
+	for _, manufacturer := range recipe.Manufacturers {
+		jsonRecipe.Manufacturers = append(jsonRecipe.Manufacturers, hex.EncodeToString(manufacturer))
+	}
+	jsonRecipe.Threshold = int(recipe.Threshold)
+	jsonRecipe.Version = int(recipe.Version)
+	jsonRecipe.Status = Pop.RecipeStatusName(int(recipe.Status))
+	jsonRecipe.Latest = latest
 
 	jsonstring, err := json.Marshal(jsonRecipe)
 	if err != nil {
@@ -552,7 +1635,7 @@ func (t *tuxedoPopsChaincode) Query(stub shim.ChaincodeStubInterface, function s
 		counterseed, err := stub.GetState("CounterSeed")
 
 		if err != nil {
-			fmt.Printf(err.Error())
+			fmt.Println(err.Error())
 			return nil, err
 		}
 
@@ -560,7 +1643,7 @@ func (t *tuxedoPopsChaincode) Query(stub shim.ChaincodeStubInterface, function s
 		popcode := Pop.Pop{}
 		popcodeBytes, err := stub.GetState("Popcode:" + address)
 		if err != nil {
-			fmt.Printf(err.Error())
+			fmt.Println(err.Error())
 			return nil, err
 		}
 		if len(popcodeBytes) == 0 {
@@ -577,35 +1660,88 @@ func (t *tuxedoPopsChaincode) Query(stub shim.ChaincodeStubInterface, function s
 		popcode.FromBytes(popcodeBytes)
 		return popcode.ToJSON(), nil
 	case "recipe":
-		if len(args) != 1 {
+		if len(args) != 1 && len(args) != 2 {
 			return nil, fmt.Errorf("no argument specified\n")
 		}
 
-		recipe := TuxedoPopsStore.Recipe{}
 		recipeName := args[0]
-
-		recipeBytes, err := stub.GetState("Recipe:" + recipeName)
+		first, err := getRecipe(stub, recipeName, 1)
 		if err != nil {
-			fmt.Printf(err.Error())
+			fmt.Println(err.Error())
 			return nil, fmt.Errorf("ERR: (%v)\n", err.Error())
 		}
-
-		if len(recipeBytes) == 0 {
+		if first == nil {
 			return nil, fmt.Errorf("recipe (%s) does not exist\n", recipeName)
 		}
 
-		err = proto.Unmarshal(recipeBytes, &recipe)
-		if err != nil {
-			fmt.Printf(err.Error())
-			return nil, fmt.Errorf("ERR: (%v)", err.Error())
+		// without a version the latest one is returned
+		version := int(first.Latest)
+		if len(args) == 2 {
+			version, err = strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipe version (%s)\n", args[1])
+			}
+		}
+		recipe := first
+		if version != 1 {
+			recipe, err = getRecipe(stub, recipeName, version)
+			if err != nil {
+				fmt.Println(err.Error())
+				return nil, fmt.Errorf("ERR: (%v)\n", err.Error())
+			}
+			if recipe == nil {
+				return nil, fmt.Errorf("recipe (%s) version %d does not exist\n", recipeName, version)
+			}
 		}
 
-		jsonBytes, err := recipeToJSON(recipe.CreatedType, recipe.Ingredients, recipe.Creator)
+		jsonBytes, err := recipeToJSON(recipe, int(first.Latest))
 		if err != nil {
-			fmt.Printf(err.Error())
+			fmt.Println(err.Error())
 			return nil, err
 		}
 		return jsonBytes, nil
+	case "type":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("no argument specified\n")
+		}
+		assetType, err := getAssetType(stub, args[0])
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, fmt.Errorf("ERR: (%v)\n", err.Error())
+		}
+		if assetType == nil {
+			return nil, fmt.Errorf("type (%s) is not registered\n", args[0])
+		}
+		return assetTypeToJSON(args[0], assetType)
+	case "supply":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("no argument specified\n")
+		}
+		supply, err := getSupply(stub, args[0])
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, fmt.Errorf("ERR: (%v)\n", err.Error())
+		}
+		assetType, err := getAssetType(stub, args[0])
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, fmt.Errorf("ERR: (%v)\n", err.Error())
+		}
+		return supplyToJSON(args[0], supply, assetType)
+	case "listPopcodes":
+		return listPopcodes(stub, args)
+	case "listRecipes":
+		return listRecipes(stub, args)
+	case "holdings":
+		return holdings(stub, args)
+	case "outputsByType":
+		return outputsByType(stub, args)
+	case "outputsByCreator":
+		return outputsByCreator(stub, args)
+	case "trace":
+		return trace(stub, args)
+	case "proof":
+		return proofBundle(stub, args)
 	}
 	return nil, nil
 }
@@ -39,9 +39,9 @@ func testUnitize(t *testing.T, stub *shim.MockStub, popcodes *popcodes, users *u
 	output := 0
 	unitize(t, stub, popcodes.popcode1, popcodes.popcode2, owners, data, destAmounts, int32(output))
 	sourceBalance = getBalance(t, stub, popcodes.popcode1)
-	if sourcePrevCounter != sourceBalance.Counter {
-		HandleError(t, fmt.Errorf("counter of source popcode (address: %s) changed after call to unitize. Counter: (%s)",
-			sourceBalance.Address, sourceBalance.Counter))
+	if sourcePrevCounter == sourceBalance.Counter {
+		HandleError(t, fmt.Errorf("counter of source popcode (address: %s) "+
+			"did not change after call to unitize. Counter: (%s)", sourceBalance.Address, sourceBalance.Counter))
 	}
 	destBalance = getBalance(t, stub, popcodes.popcode2)
 	if destPrevCounter == destBalance.Counter {
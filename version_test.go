@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/skuchain/TuxedoPops/Pop"
+	"github.com/skuchain/TuxedoPops/client"
+)
+
+func TestSigningVersions(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	for _, version := range []int{client.Legacy, Pop.CanonicalEncoding} {
+		createHex, err := c.Create(client.Create{Address: address, Amount: 10, Type: "Water", Data: "a:b", Version: version}, creator)
+		if err != nil {
+			HandleError(t, err)
+			t.FailNow()
+		}
+		_, err = stub.MockInvoke("1", "create", []string{createHex})
+		if err != nil {
+			HandleError(t, fmt.Errorf("create with version %d failed: %v", version, err))
+		}
+	}
+
+	// a canonical signature presented as a legacy transaction must not verify
+	counter, _ := c.Counter(address)
+	createArgs, _ := client.Create{Address: address, Amount: 10, Type: "Water", Version: Pop.CanonicalEncoding}.Sign(counter, creator)
+	createArgs.Version = Pop.LegacyEncoding
+	createHex, _ := client.Encode(createArgs)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("create with mismatched version was accepted"))
+	}
+
+	if len(getBalance(t, stub, &keyInfo{address: address}).Outputs) != 2 {
+		HandleError(t, fmt.Errorf("expected 2 outputs on %s", address))
+	}
+}
+
+func TestMinimumSigningVersion(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World", "1"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Water", Version: client.Legacy}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy create accepted after migration window closed"))
+	}
+	// transactions are signed with the canonical encoding by default
+	createHex, _ = c.Create(client.Create{Address: address, Amount: 10, Type: "Water"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, fmt.Errorf("canonical create rejected: %v", err))
+	}
+
+	if _, err := stub.MockInit("1", "", []string{"Hello World", "7"}); err == nil {
+		HandleError(t, fmt.Errorf("unsupported minimum signing version accepted by Init"))
+	}
+}
+
+func TestCanonicalOnlyOperations(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Water"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Steam", CreatedType: "Steam", Ingredients: []client.Ingredient{
+		{Numerator: 1, Denominator: 1, Type: "Water"}}}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	// operations added after the canonical encoding have no legacy form
+	burnHex, _ := c.Burn(client.Burn{Output: 0, Amount: 1, Version: client.Legacy}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy burn was accepted"))
+	}
+	refundHex, _ := c.Refund(client.Refund{Output: 0, Version: client.Legacy}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "refund", []string{refundHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy refund was accepted"))
+	}
+	statusHex, _ := c.RecipeStatus(client.RecipeStatus{Name: "Steam", RecipeVersion: 1, Status: Pop.RecipeDeprecated,
+		Version: client.Legacy}, creator)
+	if _, err := stub.MockInvoke("1", "recipeStatus", []string{statusHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy recipe status was accepted"))
+	}
+
+	burnHex, _ = c.Burn(client.Burn{Output: 0, Amount: 1}, nil, popcode)
+	if _, err := stub.MockInvoke("1", "burn", []string{burnHex}); err != nil {
+		HandleError(t, fmt.Errorf("canonical burn rejected: %v", err))
+	}
+}
+
+func TestLegacyEncodingLimits(t *testing.T) {
+	bst := new(tuxedoPopsChaincode)
+	stub := shim.NewMockStub("tuxedoPops", bst)
+	checkInit(t, stub, []string{"Hello World"})
+	c := client.New(stub.MockQuery)
+
+	creator, _ := btcec.NewPrivateKey(btcec.S256())
+	popcode, _ := btcec.NewPrivateKey(btcec.S256())
+	address := client.Address(popcode.PubKey())
+	water := []client.Ingredient{{Numerator: 1, Denominator: 1, Type: "Water"}}
+
+	createHex, _ := c.Create(client.Create{Address: address, Amount: 10, Type: "Water"}, creator)
+	if _, err := stub.MockInvoke("1", "create", []string{createHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+	recipeHex, _ := c.Recipe(client.Recipe{Name: "Steam", CreatedType: "Steam", Ingredients: water, Version: client.Legacy}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err != nil {
+		HandleError(t, err)
+		t.FailNow()
+	}
+
+	// a legacy version suffix reads like one more field of the ingredients
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Steam", RecipeVersion: 2, CreatedType: "Steam", Ingredients: water,
+		Version: client.Legacy}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy recipe version was accepted"))
+	}
+	// as do byproducts, which an ingredient type containing ":byproduct:" could spell out
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Tea", CreatedType: "Tea", Ingredients: water,
+		Byproducts: []client.Byproduct{{Numerator: 1, Denominator: 1, Type: "Leaves"}}, Version: client.Legacy}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy recipe with byproducts was accepted"))
+	}
+	recipeHex, _ = c.Recipe(client.Recipe{Name: "Brew", CreatedType: "Coffee", Ingredients: water,
+		Manufacturers: []*btcec.PublicKey{creator.PubKey()}, Threshold: 1, Version: client.Legacy}, creator)
+	if _, err := stub.MockInvoke("1", "recipe", []string{recipeHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy recipe with manufacturers was accepted"))
+	}
+	// and a pinned version like part of the recipe name
+	combineHex, _ := c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 1}}, Amount: 1, Recipe: "Steam",
+		RecipeVersion: 1, Version: client.Legacy}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy combine pinning a recipe version was accepted"))
+	}
+	// and the change flag like the end of the data
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 1}}, Amount: 1, Recipe: "Steam",
+		ReturnChange: true, Version: client.Legacy}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err == nil {
+		HandleError(t, fmt.Errorf("legacy combine returning change was accepted"))
+	}
+	combineHex, _ = c.Combine(client.Combine{Sources: []client.Source{{Output: 0, Amount: 1}}, Amount: 1, Recipe: "Steam",
+		Version: client.Legacy}, creator, nil, popcode)
+	if _, err := stub.MockInvoke("1", "combine", []string{combineHex}); err != nil {
+		HandleError(t, err)
+	}
+}